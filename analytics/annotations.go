@@ -0,0 +1,158 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AnnotationRequest is a request of annotations of the SimpleJSON datasource
+type AnnotationRequest struct {
+	Annotation map[string]interface{} `json:"annotation"`
+	Range      RangeDoc               `json:"range"`
+}
+
+// TagRequest is a request of tag values of a key
+type TagRequest struct {
+	Key string `json:"key"`
+}
+
+// Annotation is an event shown on dashboard panels
+type Annotation struct {
+	Annotation interface{} `json:"annotation"`
+	Tags       []string    `json:"tags"`
+	Text       string      `json:"text"`
+	Time       int64       `json:"time"`
+	Title      string      `json:"title"`
+}
+
+func (m *Metrics) annotations(w http.ResponseWriter, r *http.Request) {
+	m.RLock()
+	defer m.RUnlock()
+	list := []Annotation{}
+	var ar AnnotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&ar); err != nil {
+		json.NewEncoder(w).Encode(list)
+		return
+	}
+	query, _ := ar.Annotation["query"].(string) // tags, e.g. restart,election
+	tags := map[string]bool{}
+	for _, tag := range strings.FieldsFunc(query, func(r rune) bool { return r == ',' || r == ' ' }) {
+		tags[tag] = true
+	}
+	for _, annotation := range m.getAnnotations() {
+		tm := time.Unix(0, annotation.Time*int64(time.Millisecond))
+		if tm.Before(ar.Range.From) || tm.After(ar.Range.To) || hasAnyTag(annotation.Tags, tags) == false {
+			continue
+		}
+		annotation.Annotation = ar.Annotation
+		list = append(list, annotation)
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// hasAnyTag returns true if no tags to match or any tag matches
+func hasAnyTag(list []string, tags map[string]bool) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, tag := range list {
+		if tags[tag] == true {
+			return true
+		}
+	}
+	return false
+}
+
+// getAnnotations returns annotations of events and incidents of all hosts in the order of time
+func (m *Metrics) getAnnotations() []Annotation {
+	list := []Annotation{}
+	if len(m.hosts) == 0 { // from processed FTDC data
+		list = append(list, getEventAnnotations(m.ftdcStats.Events, "")...)
+		list = append(list, getIncidentAnnotations(m.ftdcStats.Incidents, "")...)
+	}
+	for _, host := range m.hosts {
+		tag := ""
+		if len(m.hosts) > 1 {
+			tag = host
+		}
+		list = append(list, getEventAnnotations(m.hostStats[host].Events, tag)...)
+		list = append(list, getIncidentAnnotations(m.hostStats[host].Incidents, tag)...)
+	}
+	sort.SliceStable(list, func(i int, j int) bool { return list[i].Time < list[j].Time })
+	return list
+}
+
+// getIncidentAnnotations returns annotations of incidents, tagged with the host if not empty
+func getIncidentAnnotations(incidents []Incident, host string) []Annotation {
+	list := []Annotation{}
+	for _, inc := range incidents {
+		tags := []string{"incident", inc.Type, inc.Metric}
+		if host != "" {
+			tags = append(tags, host)
+		}
+		list = append(list, Annotation{Tags: tags, Text: inc.String(),
+			Time: inc.Time.UnixNano() / int64(time.Millisecond), Title: inc.Metric + " " + inc.Type})
+	}
+	return list
+}
+
+// getEventAnnotations returns annotations of events, tagged with the host if not empty
+func getEventAnnotations(events []Event, host string) []Annotation {
+	list := []Annotation{}
+	for _, event := range events {
+		tags := []string{event.Type}
+		if host != "" {
+			tags = append(tags, host)
+		}
+		list = append(list, Annotation{Tags: tags, Text: event.Text,
+			Time: event.Time.UnixNano() / int64(time.Millisecond), Title: event.Type})
+	}
+	return list
+}
+
+// tagKeys returns keys of ad hoc filters
+func (m *Metrics) tagKeys(w http.ResponseWriter, r *http.Request) {
+	keys := []map[string]string{{"type": "string", "text": "host"}, {"type": "string", "text": "type"},
+		{"type": "string", "text": "metric"}}
+	json.NewEncoder(w).Encode(keys)
+}
+
+// tagValues returns values of a key of ad hoc filters
+func (m *Metrics) tagValues(w http.ResponseWriter, r *http.Request) {
+	m.RLock()
+	defer m.RUnlock()
+	values := []map[string]string{}
+	var tr TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&tr); err != nil {
+		json.NewEncoder(w).Encode(values)
+		return
+	}
+	var list []string
+	switch tr.Key {
+	case "host":
+		list = m.hosts
+	case "type":
+		list = append(append(list, EventTypes...), "shift", "spike")
+	case "metric":
+		list = AnomalyMetrics
+	}
+	for _, value := range list {
+		values = append(values, map[string]string{"text": value})
+	}
+	json.NewEncoder(w).Encode(values)
+}
+
+// getAdhocHostStats returns stats of the host of an ad hoc filter, host = <hostname>
+func (m *Metrics) getAdhocHostStats(filters []AdhocFilter) *FTDCStats {
+	for _, filter := range filters {
+		if filter.Key == "host" && filter.Operator == "=" && m.hostStats[filter.Value] != nil {
+			return m.hostStats[filter.Value]
+		}
+	}
+	return nil
+}
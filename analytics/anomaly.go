@@ -0,0 +1,174 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AnomalyMetrics are time series checked for change points and spikes
+var AnomalyMetrics = []string{
+	"conns_current", "cpu_iowait", "cpu_system", "cpu_user",
+	"latency_command", "latency_read", "latency_write", "mem_page_faults",
+	"q_queued_read", "q_queued_write", "scan_objects", "scan_sort",
+	"wt_cache_dirty", "wt_cache_used", "wt_modified_evicted", "wt_unmodified_evicted",
+}
+
+const anomalyWindow = 30         // data points before and after a change point
+const anomalyRatio = 3.0         // of after to before to be an incident
+const incidentGroupMinutes = 5   // incidents within are printed as followed by
+const incidentsLimit = 100       // max number of incidents of a metric
+const minAnomalyDeltaRatio = .05 // of max value of a metric to be an incident
+
+// Incident is a shift (change point) or a spike of a metric
+type Incident struct {
+	After  float64   `json:"after"`  // median after a shift or the max of a spike
+	Before float64   `json:"before"` // median before
+	Metric string    `json:"metric"`
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"` // shift or spike
+}
+
+// Ratio returns ratio of after to before
+func (inc Incident) Ratio() float64 {
+	if inc.Before <= 0 {
+		return math.Inf(1)
+	}
+	return inc.After / inc.Before
+}
+
+// String returns a description, e.g. wt_cache_dirty jumped 5.0x (120 -> 600)
+func (inc Incident) String() string {
+	verb := "jumped"
+	if inc.Type == "spike" {
+		verb = "spiked"
+	}
+	if math.IsInf(inc.Ratio(), 1) {
+		return fmt.Sprintf("%v %v from 0 to %v", inc.Metric, verb, formatAnomalyValue(inc.After))
+	}
+	return fmt.Sprintf("%v %v %.1fx (%v -> %v)", inc.Metric, verb, inc.Ratio(),
+		formatAnomalyValue(inc.Before), formatAnomalyValue(inc.After))
+}
+
+func formatAnomalyValue(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+// GetIncidents returns change points and spikes of AnomalyMetrics in the order of time
+func GetIncidents(tsd map[string]TimeSeriesDoc) []Incident {
+	incidents := []Incident{}
+	for _, metric := range AnomalyMetrics {
+		incidents = append(incidents, getIncidentsOfSeries(metric, tsd[metric].DataPoints)...)
+	}
+	sort.SliceStable(incidents, func(i int, j int) bool {
+		return incidents[i].Time.Before(incidents[j].Time)
+	})
+	return incidents
+}
+
+// getIncidentsOfSeries returns shifts, where the median of the next window is anomalyRatio
+// times the median of the previous window, and spikes, data points anomalyRatio times the
+// median of the previous window while the next window doesn't shift
+func getIncidentsOfSeries(metric string, points [][]float64) []Incident {
+	incidents := []Incident{}
+	values := []float64{}
+	times := []float64{}
+	max := 0.0
+	for _, point := range points {
+		if isValidDataPoint(point) == false {
+			continue
+		}
+		values = append(values, point[0])
+		times = append(times, point[1])
+		max = math.Max(max, point[0])
+	}
+	if len(values) < 2*anomalyWindow || max <= 0 {
+		return incidents
+	}
+	minDelta := math.Max(minAnomalyDeltaRatio*max, math.SmallestNonzeroFloat64)
+	isJump := func(before float64, after float64) bool {
+		return after-before >= minDelta && after >= anomalyRatio*before
+	}
+	toTime := func(ms float64) time.Time {
+		return time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC()
+	}
+	for i := anomalyWindow; i <= len(values)-anomalyWindow && len(incidents) < incidentsLimit; i++ {
+		before := getMedian(values[i-anomalyWindow : i])
+		after := getMedian(values[i : i+anomalyWindow])
+		if isJump(before, after) == true { // the onset has the largest difference of means
+			best, diff := i, getMean(values[i:i+anomalyWindow])-getMean(values[i-anomalyWindow:i])
+			for j := i + 1; j < i+anomalyWindow && j <= len(values)-anomalyWindow; j++ {
+				if d := getMean(values[j:j+anomalyWindow]) - getMean(values[j-anomalyWindow:j]); d > diff {
+					best, diff = j, d
+				}
+			}
+			incidents = append(incidents, Incident{After: getMedian(values[best : best+anomalyWindow]),
+				Before: getMedian(values[best-anomalyWindow : best]), Metric: metric, Time: toTime(times[best]), Type: "shift"})
+			i = best + anomalyWindow - 1
+		} else if isJump(before, values[i]) == true {
+			peak := i
+			for i+1 < len(values) && isJump(before, values[i+1]) == true {
+				i++
+				if values[i] > values[peak] {
+					peak = i
+				}
+			}
+			incidents = append(incidents, Incident{After: values[peak], Before: before, Metric: metric,
+				Time: toTime(times[peak]), Type: "spike"})
+		}
+	}
+	return incidents
+}
+
+func getMean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func getMedian(values []float64) float64 {
+	list := append([]float64{}, values...)
+	sort.Float64s(list)
+	n := len(list)
+	if n == 0 {
+		return 0
+	} else if n%2 == 1 {
+		return list[n/2]
+	}
+	return (list[n/2-1] + list[n/2]) / 2
+}
+
+// PrintIncidents prints a timeline of incidents, incidents within minutes of the first
+// one are printed as followed by
+func PrintIncidents(incidents []Incident) string {
+	var lines []string
+	lines = append(lines, "\n--- Incidents Timeline ---")
+	if len(incidents) == 0 {
+		lines = append(lines, "no change points or spikes found")
+		return strings.Join(lines, "\n") + "\n"
+	}
+	var first Incident
+	line := ""
+	for i, inc := range incidents {
+		if i > 0 && inc.Time.Sub(first.Time) <= incidentGroupMinutes*time.Minute {
+			line += fmt.Sprintf(", followed by %v (+%v)", inc, inc.Time.Sub(first.Time))
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		first = inc
+		line = fmt.Sprintf("at %v %v", inc.Time.In(loc).Format(time.RFC3339), inc)
+	}
+	lines = append(lines, line)
+	return strings.Join(lines, "\n") + "\n"
+}
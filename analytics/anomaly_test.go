@@ -0,0 +1,73 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAnomalyStart = time.Date(2020, 1, 1, 14, 0, 0, 0, time.UTC)
+
+// getTestAnomalySeries returns a data point every second, base values with spikes and a shift
+func getTestAnomalySeries(base float64, shiftAt int, shift float64, spikes map[int]float64) [][]float64 {
+	points := [][]float64{}
+	for i := 0; i < 300; i++ {
+		v := base + float64(i%3) // noises
+		if i >= shiftAt {
+			v = shift + float64(i%3)
+		}
+		if spike, ok := spikes[i]; ok == true {
+			v = spike
+		}
+		points = append(points, []float64{v, float64(testAnomalyStart.Add(time.Duration(i)*time.Second).UnixNano() / 1000000)})
+	}
+	return points
+}
+
+func TestGetIncidents(t *testing.T) {
+	tsd := map[string]TimeSeriesDoc{
+		"wt_cache_dirty": {DataPoints: getTestAnomalySeries(100, 120, 500, nil)},
+		"q_queued_write": {DataPoints: getTestAnomalySeries(1, 1000, 0, map[int]float64{150: 40, 151: 60})},
+		"cpu_user":       {DataPoints: getTestAnomalySeries(20, 1000, 0, nil)},
+	}
+	incidents := GetIncidents(tsd)
+	if len(incidents) != 2 {
+		t.Fatal(incidents)
+	}
+	if incidents[0].Metric != "wt_cache_dirty" || incidents[0].Type != "shift" ||
+		incidents[0].Time.Equal(testAnomalyStart.Add(120*time.Second)) == false {
+		t.Fatal(incidents[0])
+	}
+	if incidents[1].Metric != "q_queued_write" || incidents[1].Type != "spike" || incidents[1].After != 60 ||
+		incidents[1].Time.Equal(testAnomalyStart.Add(151*time.Second)) == false {
+		t.Fatal(incidents[1])
+	}
+	str := PrintIncidents(incidents)
+	if strings.Contains(str, "wt_cache_dirty jumped 5.0x (101 -> 501), followed by q_queued_write spiked 30.0x (2 -> 60) (+31s)") == false {
+		t.Fatal(str)
+	}
+	t.Log(str)
+}
+
+func TestAnnotations(t *testing.T) {
+	m := &Metrics{}
+	m.ftdcStats.Incidents = []Incident{{After: 60, Before: 2, Metric: "q_queued_write", Time: testAnomalyStart, Type: "spike"}}
+	ar := AnnotationRequest{Annotation: map[string]interface{}{"name": "incidents"},
+		Range: RangeDoc{From: testAnomalyStart.Add(-time.Hour), To: testAnomalyStart.Add(time.Hour)}}
+	b, _ := json.Marshal(ar)
+	w := httptest.NewRecorder()
+	m.Handler(w, httptest.NewRequest(http.MethodPost, "/grafana/annotations", bytes.NewReader(b)))
+	var list []Annotation
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Time != testAnomalyStart.Unix()*1000 || list[0].Text != "q_queued_write spiked 30.0x (2 -> 60)" {
+		t.Fatal(w.Body.String())
+	}
+}
@@ -3,82 +3,73 @@
 package analytics
 
 import (
+	"fmt"
 	"math"
 	"sort"
 	"strings"
 	"time"
 )
 
-// ScoreFormula holds metric info
-type ScoreFormula struct {
-	formula string
-	label   string
-	low     int
-	high    int
-}
-
-// FormulaMap holds low and high watermarks
-var FormulaMap = map[string]ScoreFormula{
-	"conns_created/s":       ScoreFormula{label: "conns_created/s", formula: "conns_created/s", low: 0, high: 5},
-	"conns_current":         ScoreFormula{label: "conns_current %%", formula: "1MB*(p95 of conns_current)/RAM", low: 5, high: 20},
-	"cpu_idle":              ScoreFormula{label: "cpu_idle %%", formula: "p5 of cpu_idle", low: 50, high: 80},
-	"cpu_iowait":            ScoreFormula{label: "cpu_iowait %%", formula: "p95 of cpu_iowait", low: 5, high: 15},
-	"cpu_system":            ScoreFormula{label: "cpu_system %%", formula: "p95 of cpu_system", low: 5, high: 15},
-	"cpu_user":              ScoreFormula{label: "cpu_user %%", formula: "p95 of cpu_user", low: 50, high: 70},
-	"disku_":                ScoreFormula{label: "disku_&lt;dev&gt; %%", formula: "p95 of disku_&lt;dev&gt;", low: 50, high: 90},
-	"iops_":                 ScoreFormula{label: "iops_&lt;dev&gt;", formula: "(p95 of iops_&lt;dev&gt;)/(avg of iops_<dev>)", low: 2, high: 4},
-	"latency_command":       ScoreFormula{label: "latency_command (ms)", formula: "p95 of latency_command", low: 20, high: 100},
-	"latency_read":          ScoreFormula{label: "latency_read (ms)", formula: "p95 of latency_read", low: 20, high: 100},
-	"latency_write":         ScoreFormula{label: "latency_write (ms)", formula: "p95 of latency_write", low: 20, high: 100},
-	"mem_page_faults":       ScoreFormula{label: "mem_page_faults", formula: "p95 of mem_page_faults", low: 10, high: 20},
-	"mem_resident":          ScoreFormula{label: "mem_resident %%", formula: "mem_resident/RAM", low: 70, high: 90},
-	"ops_":                  ScoreFormula{label: "ops_&lt;op&gt;", formula: "ops_&lt;op&gt;", low: 0, high: 64000},
-	"queued_read":           ScoreFormula{label: "queued_read", formula: "p95 of queued_read", low: 1, high: 5},
-	"queued_write":          ScoreFormula{label: "queued_write", formula: "p95 of queued_write", low: 1, high: 5},
-	"scan_keys":             ScoreFormula{label: "scan_keys", formula: "scan_keys", low: 0, high: (1024 * 1024)},
-	"scan_objects":          ScoreFormula{label: "scan_objects", formula: "max of [](scan_objects/scan_keys)", low: 2, high: 5},
-	"scan_sort":             ScoreFormula{label: "scan_sort", formula: "scan_sort", low: 0, high: 1000},
-	"ticket_avail_read":     ScoreFormula{label: "ticket_avail_read %%", formula: "(p5 of ticket_avail_read)/128", low: 0, high: 100},
-	"ticket_avail_write":    ScoreFormula{label: "ticket_avail_write %%", formula: "(p5 of ticket_avail_write)/128", low: 0, high: 100},
-	"wt_cache_used":         ScoreFormula{label: "wt_cache_used %%", formula: "(p95 of wt_cache_used)/wt_cache_max", low: 80, high: 95},
-	"wt_cache_dirty":        ScoreFormula{label: "wt_cache_dirty %%", formula: "(p95 of wt_cache_dirty)/wt_cache_max", low: 5, high: 20},
-	"wt_dhandles_active":    ScoreFormula{label: "wt_dhandles_active", formula: "(p95 of wt_dhandles_active)", low: 16000, high: 20000},
-	"wt_modified_evicted":   ScoreFormula{label: "wt_modified_evicted  %%", formula: "(p95 of wt_modified_evicted)/(pages of wt_cache_max)", low: 5, high: 10},
-	"wt_unmodified_evicted": ScoreFormula{label: "wt_unmodified_evicted  %%", formula: "(p95 of wt_unmodified_evicted)/(pages of wt_cache_max)", low: 5, high: 10},
-}
-
 // Assessment stores timeserie data
 type Assessment struct {
-	blocks        int
-	maxCachePages int
-	stats         FTDCStats
-	verbose       bool
+	blocks  int
+	rules   []AssessmentRule
+	stats   FTDCStats
+	vars    map[string]float64 // of normalize expressions
+	verbose bool
 }
 
 type metricStats struct {
-	label  string
-	median float64
-	p5     float64
-	p95    float64
-	score  int
+	label    string
+	median   float64
+	p5       float64
+	p95      float64
+	score    int
+	severity string
 }
 
 // NewAssessment returns assessment object
 func NewAssessment(stats FTDCStats) *Assessment {
-	assessment := Assessment{blocks: 3, stats: stats}
-	cores := stats.ServerInfo.HostInfo.System.NumCores
-	m := FormulaMap["queued_read"]
-	m.low = cores
-	m.high = 5 * cores
-	FormulaMap["queued_read"] = m
-	m = FormulaMap["queued_write"]
-	m.low = cores
-	m.high = 5 * cores
-	FormulaMap["queued_write"] = m
-	assessment.maxCachePages = int(.05 * float64(stats.MaxWTCache) * (1024 * 1024 * 1024) / (4 * 1024)) // 5% of WiredTiger cache
+	assessment := Assessment{blocks: 3, rules: DefaultAssessmentRules, stats: stats}
+	system := stats.ServerInfo.HostInfo.System
+	assessment.vars = map[string]float64{
+		"RAM":         float64(system.MemSizeMB) / 1024,
+		"RAM_MB":      float64(system.MemSizeMB),
+		"cache":       stats.MaxWTCache,
+		"cache_pages": float64(int(.05 * float64(stats.MaxWTCache) * (1024 * 1024 * 1024) / (4 * 1024))), // 5% of WiredTiger cache
+		"cores":       float64(system.NumCores),
+	}
+	assessment.vars["tickets_read"], assessment.vars["tickets_write"] = getTotalTickets(stats.ServerStatusList)
 	return &assessment
 }
 
+// getTotalTickets returns the max WiredTiger read and write tickets, the default 128 if not reported
+func getTotalTickets(list []ServerStatusDoc) (float64, float64) {
+	read, write := uint64(0), uint64(0)
+	for _, stat := range list {
+		if stat.WiredTiger.ConcurrentTransactions.Read.TotalTickets > read {
+			read = stat.WiredTiger.ConcurrentTransactions.Read.TotalTickets
+		}
+		if stat.WiredTiger.ConcurrentTransactions.Write.TotalTickets > write {
+			write = stat.WiredTiger.ConcurrentTransactions.Write.TotalTickets
+		}
+	}
+	if read == 0 {
+		read = 128
+	}
+	if write == 0 {
+		write = 128
+	}
+	return float64(read), float64(write)
+}
+
+// SetRules sets assessment rules
+func (as *Assessment) SetRules(rules []AssessmentRule) {
+	if len(rules) > 0 {
+		as.rules = rules
+	}
+}
+
 // SetVerbose sets verbose level
 func (as *Assessment) SetVerbose(verbose bool) {
 	as.verbose = verbose
@@ -97,44 +88,7 @@ func (as *Assessment) GetAssessment(from time.Time, to time.Time) map[string]int
 			headerList = append(headerList, map[string]string{"text": "Median", "type": "Number"})
 			headerList = append(headerList, map[string]string{"text": "p95", "type": "Number"})
 		}
-		marr := []metricStats{}
-		metrics := serverStatusChartsLegends
-		metrics = append(metrics, wiredTigerChartsLegends...)
-		for _, sm := range systemMetricsChartsLegends {
-			if strings.HasPrefix(sm, "cpu_") {
-				metrics = append(metrics, sm)
-			}
-		}
-		for _, v := range metrics {
-			m := as.getStatsArray(v, from, to)
-			if m.score < 101 || as.verbose {
-				marr = append(marr, m)
-			}
-		}
-		for k, v := range as.stats.DiskStats {
-			p5, median, p95 := as.getStatsByData(v.IOPS, from, to)
-			if p95 == 0 {
-				continue
-			}
-			m := as.getStatsArrayByValues("iops_"+k, p5, median, p95)
-			if m.score < 101 || as.verbose {
-				marr = append(marr, m)
-			}
-			p5, median, p95 = as.getStatsByData(v.Utilization, from, to)
-			m = as.getStatsArrayByValues("disku_"+k, p5, median, p95)
-			if m.score < 101 || as.verbose {
-				marr = append(marr, m)
-			}
-		}
-		sort.Slice(marr, func(i int, j int) bool {
-			// return marr[i].label < marr[j].label
-			if marr[i].score < marr[j].score {
-				return true
-			} else if marr[i].score == marr[j].score {
-				return marr[i].label < marr[j].label
-			}
-			return false
-		})
+		marr := as.getMetricsStats(from, to)
 		arr := []interface{}{}
 		for _, v := range marr {
 			arr = append(arr, []interface{}{v.label, v.score, v.p5, v.median, v.p95}...)
@@ -153,40 +107,138 @@ func (as *Assessment) GetAssessment(from time.Time, to time.Time) map[string]int
 	return map[string]interface{}{"columns": headerList, "type": "table", "rows": rowList}
 }
 
-func (as *Assessment) getStatsArray(metric string, from time.Time, to time.Time) metricStats {
-	p5, median, p95 := as.getStatsByData(as.stats.TimeSeriesData[metric], from, to)
-	return as.getStatsArrayByValues(metric, p5, median, p95)
+// PrintAssessment prints scores of metrics of all data points
+func (as *Assessment) PrintAssessment() string {
+	var lines []string
+	from, to := getTimeRange(as.stats)
+	lines = append(lines, "\n--- Assessment ---")
+	lines = append(lines, "+--------------------------+-------+------------+------------+------------+----------+")
+	lines = append(lines, "| Metric                   | Score | p5         | Median     | p95        | Severity |")
+	lines = append(lines, "|--------------------------+-------+------------+------------+------------+----------|")
+	for _, v := range as.getMetricsStats(from, to) {
+		severity := v.severity // of metrics over the low watermarks
+		if v.score >= 100 {
+			severity = ""
+		}
+		lines = append(lines, fmt.Sprintf("|%-26s|%7d|%12.0f|%12.0f|%12.0f|%-10s|", v.label, v.score, v.p5, v.median, v.p95, severity))
+	}
+	lines = append(lines, "+--------------------------+-------+------------+------------+------------+----------+")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// getTimeRange returns the time range of all data points, or from epoch to now if none
+func getTimeRange(stats FTDCStats) (time.Time, time.Time) {
+	from := time.Unix(0, 0)
+	to := time.Now()
+	if points := stats.TimeSeriesData["mem_resident"].DataPoints; len(points) > 0 {
+		from = time.Unix(0, int64(points[0][1])*int64(time.Millisecond))
+		to = time.Unix(0, int64(points[len(points)-1][1])*int64(time.Millisecond))
+	}
+	return from, to
+}
+
+// getMetricsData returns names and data of metrics to assess, including iops and utilization of disks
+func (as *Assessment) getMetricsData() ([]string, map[string]TimeSeriesDoc) {
+	metrics := append([]string{}, serverStatusChartsLegends...)
+	metrics = append(metrics, wiredTigerChartsLegends...)
+	for _, sm := range systemMetricsChartsLegends {
+		if strings.HasPrefix(sm, "cpu_") {
+			metrics = append(metrics, sm)
+		}
+	}
+	data := map[string]TimeSeriesDoc{}
+	for _, v := range metrics {
+		data[v] = as.stats.TimeSeriesData[v]
+	}
+	disks := []string{}
+	for k := range as.stats.DiskStats {
+		disks = append(disks, k)
+	}
+	sort.Strings(disks)
+	for _, k := range disks {
+		metrics = append(metrics, "iops_"+k, "disku_"+k)
+		data["iops_"+k] = as.stats.DiskStats[k].IOPS
+		data["disku_"+k] = as.stats.DiskStats[k].Utilization
+	}
+	return metrics, data
+}
+
+// getMetricsStats returns stats of metrics scored, or all if verbose, sorted by scores
+func (as *Assessment) getMetricsStats(from time.Time, to time.Time) []metricStats {
+	marr := []metricStats{}
+	metrics, data := as.getMetricsData()
+	for _, v := range metrics {
+		if disk := strings.TrimPrefix(strings.TrimPrefix(v, "iops_"), "disku_"); disk != v {
+			if _, _, p95 := as.getStatsByData(data["iops_"+disk], from, to); p95 == 0 {
+				continue
+			}
+		}
+		m := as.getStatsArray(v, data[v], from, to)
+		if m.score < 101 || as.verbose {
+			marr = append(marr, m)
+		}
+	}
+	sort.Slice(marr, func(i int, j int) bool {
+		if marr[i].score < marr[j].score {
+			return true
+		} else if marr[i].score == marr[j].score {
+			return marr[i].label < marr[j].label
+		}
+		return false
+	})
+	return marr
+}
+
+// getRule returns the rule of a metric, nil if none
+func (as *Assessment) getRule(metric string) *AssessmentRule {
+	for i := range as.rules {
+		if as.rules[i].Matches(metric) {
+			return &as.rules[i]
+		}
+	}
+	return nil
 }
 
-func (as *Assessment) getStatsArrayByValues(metric string, p5 float64, median float64, p95 float64) metricStats {
-	var score = 101
+// getStatsArray returns p5, median, and p95, normalized if the label is of a percentage, and the score by the rule
+func (as *Assessment) getStatsArray(metric string, data TimeSeriesDoc, from time.Time, to time.Time) metricStats {
+	p5, median, p95 := as.getStatsByData(data, from, to)
+	rule := as.getRule(metric)
+	if rule == nil {
+		return metricStats{label: metric, score: 101, p5: math.Round(p5), median: math.Round(median), p95: math.Round(p95)}
+	}
 	label := metric
-	if strings.HasSuffix(label, "modified_evicted") {
-		label = strings.ReplaceAll(label, "modified_evicted", "mod_evicted")
+	if strings.HasSuffix(rule.Metric, "_") == false && rule.Label != "" {
+		label = rule.Label
+	} else if strings.HasSuffix(rule.Label, " %") {
+		label += " %"
+	}
+	others := map[string][]float64{}
+	if matches := reMaxRatio.FindStringSubmatch(rule.Statistic); matches != nil {
+		others[matches[1]] = getFilteredValues(as.stats.TimeSeriesData[matches[1]], from, to)
 	}
-	if as.stats.MaxWTCache > 0 && (metric == "wt_cache_used" || metric == "wt_cache_dirty") {
-		u := 100 * p95 / float64(as.stats.MaxWTCache)
-		if metric == "wt_cache_used" { // 80% to 100%
-			score = GetScoreByRange(u, 80, 95)
-		} else if metric == "wt_cache_dirty" { // 5% to 20%
-			score = GetScoreByRange(u, 5, 20)
+	_, score := rule.Score(getFilteredValues(data, from, to), others, as.vars)
+	if rule.Normalize != "" && strings.HasSuffix(rule.Label, "%") {
+		var err error
+		list := []*float64{&p5, &median, &p95}
+		for _, v := range list {
+			if *v, err = Normalize(rule.Normalize, *v, as.vars); err != nil {
+				*v = math.NaN()
+			}
 		}
-		return metricStats{label: label + " %", score: score, p5: math.Round(100 * p5 / float64(as.stats.MaxWTCache)),
-			median: math.Round(100 * median / float64(as.stats.MaxWTCache)), p95: math.Round(100 * p95 / float64(as.stats.MaxWTCache))}
-	} else if as.stats.ServerInfo.HostInfo.System.MemSizeMB > 0 && metric == "mem_resident" {
-		total := float64(as.stats.ServerInfo.HostInfo.System.MemSizeMB) / 1024
-		u := 100 * p95 / total
-		score = GetScoreByRange(u, 70, 90)
-		return metricStats{label: label + " %", score: score, p5: math.Round(100 * p5 / total),
-			median: math.Round(100 * median / total), p95: math.Round(100 * p95 / total)}
 	}
-	score = as.getScore(metric, p5, median, p95)
-	if strings.HasPrefix(metric, "cpu_") || strings.HasPrefix(metric, "disku_") {
-		return metricStats{label: label + " %", score: score, p5: math.Round(p5),
-			median: math.Round(median), p95: math.Round(p95)}
+	return metricStats{label: label, score: score, p5: math.Round(p5), median: math.Round(median),
+		p95: math.Round(p95), severity: rule.Severity}
+}
+
+// getFilteredValues returns values of data points within a range
+func getFilteredValues(data TimeSeriesDoc, from time.Time, to time.Time) []float64 {
+	values := []float64{}
+	for _, dp := range FilterTimeSeriesData(data, from, to).DataPoints {
+		if isValidDataPoint(dp) == true {
+			values = append(values, dp[0])
+		}
 	}
-	return metricStats{label: label, score: score, p5: math.Round(p5),
-		median: math.Round(median), p95: math.Round(p95)}
+	return values
 }
 
 func (as *Assessment) getStatsByData(data TimeSeriesDoc, from time.Time, to time.Time) (float64, float64, float64) {
@@ -220,76 +272,3 @@ func (as *Assessment) getStatsByData(data TimeSeriesDoc, from time.Time, to time
 	}
 	return arr[p5], arr[median], arr[p95]
 }
-
-func (as *Assessment) getScore(metric string, p5 float64, median float64, p95 float64) int {
-	score := 101
-	met := metric
-	if strings.HasPrefix(met, "disku_") {
-		met = "disku_"
-	} else if strings.HasPrefix(met, "iops_") {
-		met = "iops_"
-	} else if strings.HasPrefix(met, "ops_") {
-		met = "ops_"
-	}
-	if FormulaMap[met].label == "" {
-		return score
-	}
-	lwm := float64(FormulaMap[met].low)
-	hwm := float64(FormulaMap[met].high)
-	if metric == "conns_created/s" { // 300 conns created per minute, 5/second
-		score = GetScoreByRange(median, lwm, hwm)
-	} else if metric == "conns_current" { // 5% to 20%
-		pct := 100 * p95 / float64(as.stats.ServerInfo.HostInfo.System.MemSizeMB)
-		score = GetScoreByRange(pct, lwm, hwm)
-	} else if metric == "cpu_idle" {
-		if math.IsNaN(p5) {
-			return score
-		}
-		score = 100 - GetScoreByRange(p5, lwm, hwm)
-	} else if metric == "cpu_iowait" || metric == "cpu_system" { // 5% - 15% io_wait
-		score = GetScoreByRange(p95, lwm, hwm)
-	} else if metric == "cpu_user" { // under 50% is good
-		score = GetScoreByRange(p95, lwm, hwm)
-	} else if strings.HasPrefix(metric, "disku_") { // under 50% is good
-		score = GetScoreByRange(p95, lwm, hwm)
-	} else if strings.HasPrefix(metric, "iops_") { // median:p95 ratio
-		if p95 < 100 {
-			return score
-		}
-		u := p95 / median
-		score = GetScoreByRange(u, lwm, hwm)
-	} else if strings.HasPrefix(metric, "latency_") { // 20ms is good
-		score = GetScoreByRange(p95, lwm, hwm)
-	} else if metric == "mem_page_faults" { // 10 page faults
-		score = GetScoreByRange(p95, lwm, hwm)
-	} else if strings.HasPrefix(metric, "ops_") { // 2ms an op
-		score = GetScoreByRange(p95, lwm, hwm)
-	} else if strings.HasPrefix(metric, "q_queued_") {
-		score = GetScoreByRange(p95, lwm, hwm)
-	} else if metric == "scan_keys" { // 1 mil/sec key scanned
-		score = GetScoreByRange(p95, 0, 1024.0*1024)
-	} else if metric == "scan_objects" {
-		if p95 < 1000 {
-			return 100
-		}
-		keys := as.stats.TimeSeriesData["scan_keys"]
-		objs := as.stats.TimeSeriesData["scan_objects"]
-		max := 0.0
-		for i := range keys.DataPoints {
-			scale := objs.DataPoints[i][0] / keys.DataPoints[i][0]
-			if scale > max {
-				max = scale
-			}
-		}
-		score = GetScoreByRange(max, lwm, hwm)
-	} else if metric == "scan_sort" { // 1 k sorted in mem
-		score = GetScoreByRange(p95, lwm, hwm)
-	} else if strings.HasPrefix(metric, "ticket_avail_") {
-		score = int(100 * p5 / 128)
-	} else if metric == "wt_dhandles_active" {
-		score = GetScoreByRange(p95, lwm, hwm)
-	} else if metric == "wt_modified_evicted" || metric == "wt_unmodified_evicted" {
-		score = GetScoreByRange(p95/float64(as.maxCachePages), lwm, hwm)
-	}
-	return score
-}
@@ -0,0 +1,320 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AssessmentRule scores a metric by a statistic of its data points. A score is 100 if the
+// normalized statistic is under the low watermark, and 0 if over the high watermark, or the
+// other way around if higher is better.
+type AssessmentRule struct {
+	HigherIsBetter bool    `json:"higherIsBetter,omitempty"`
+	High           float64 `json:"high"`
+	Label          string  `json:"label"`
+	Low            float64 `json:"low"`
+	Metric         string  `json:"metric"`              // a metric or a prefix ending with _, e.g. disku_
+	MinValue       float64 `json:"minValue,omitempty"`  // not scored if p95 is less
+	Normalize      string  `json:"normalize,omitempty"` // e.g. 100*value/cache, see NormalizeVariables
+	Severity       string  `json:"severity,omitempty"`  // info, warning, or critical
+	Statistic      string  `json:"statistic"`           // pN, median, avg, min, max, a ratio, e.g. p95/median, or max_ratio(metric)
+}
+
+// NormalizeVariables are variables of normalize expressions besides value
+var NormalizeVariables = []string{
+	"RAM",           // in GB
+	"RAM_MB",        // in MB
+	"cache",         // WiredTiger cache size in GB
+	"cache_pages",   // 5% of WiredTiger cache in 4KB pages
+	"cores",         // number of CPU cores
+	"tickets_read",  // WiredTiger read tickets, concurrentTransactions.read.totalTickets
+	"tickets_write", // WiredTiger write tickets, concurrentTransactions.write.totalTickets
+}
+
+// Severities are severities of rules
+var Severities = []string{"info", "warning", "critical"}
+
+// DefaultAssessmentRules are default rules, a rules file replaces rules of the same metrics
+var DefaultAssessmentRules = []AssessmentRule{
+	{Metric: "conns_created/s", Label: "conns_created/s", Statistic: "median", Low: 0, High: 5, Severity: "warning"},
+	{Metric: "conns_current", Label: "conns_current %", Statistic: "p95", Normalize: "100*value/RAM_MB", Low: 5, High: 20, Severity: "warning"},
+	{Metric: "cpu_idle", Label: "cpu_idle %", Statistic: "p5", Low: 50, High: 80, HigherIsBetter: true, Severity: "warning"},
+	{Metric: "cpu_iowait", Label: "cpu_iowait %", Statistic: "p95", Low: 5, High: 15, Severity: "warning"},
+	{Metric: "cpu_system", Label: "cpu_system %", Statistic: "p95", Low: 5, High: 15, Severity: "warning"},
+	{Metric: "cpu_user", Label: "cpu_user %", Statistic: "p95", Low: 50, High: 70, Severity: "warning"},
+	{Metric: "disku_", Label: "disku_<dev> %", Statistic: "p95", Low: 50, High: 90, Severity: "warning"},
+	{Metric: "iops_", Label: "iops_<dev>", Statistic: "p95/median", MinValue: 100, Low: 2, High: 4, Severity: "info"},
+	{Metric: "latency_command", Label: "latency_command (ms)", Statistic: "p95", Low: 20, High: 100, Severity: "critical"},
+	{Metric: "latency_read", Label: "latency_read (ms)", Statistic: "p95", Low: 20, High: 100, Severity: "critical"},
+	{Metric: "latency_write", Label: "latency_write (ms)", Statistic: "p95", Low: 20, High: 100, Severity: "critical"},
+	{Metric: "mem_page_faults", Label: "mem_page_faults", Statistic: "p95", Low: 10, High: 20, Severity: "warning"},
+	{Metric: "mem_resident", Label: "mem_resident %", Statistic: "p95", Normalize: "100*value/RAM", Low: 70, High: 90, Severity: "critical"},
+	{Metric: "ops_", Label: "ops_<op>", Statistic: "p95", Low: 0, High: 64000, Severity: "info"},
+	{Metric: "q_queued_read", Label: "queued_read", Statistic: "p95", Normalize: "value/cores", Low: 1, High: 5, Severity: "critical"},
+	{Metric: "q_queued_write", Label: "queued_write", Statistic: "p95", Normalize: "value/cores", Low: 1, High: 5, Severity: "critical"},
+	{Metric: "scan_keys", Label: "scan_keys", Statistic: "p95", Low: 0, High: 1024 * 1024, Severity: "warning"},
+	{Metric: "scan_objects", Label: "scan_objects", Statistic: "max_ratio(scan_keys)", MinValue: 1000, Low: 2, High: 5, Severity: "warning"},
+	{Metric: "scan_sort", Label: "scan_sort", Statistic: "p95", Low: 0, High: 1000, Severity: "warning"},
+	{Metric: "ticket_avail_read", Label: "ticket_avail_read %", Statistic: "p5", Normalize: "100*value/tickets_read", Low: 0, High: 100, HigherIsBetter: true, Severity: "critical"},
+	{Metric: "ticket_avail_write", Label: "ticket_avail_write %", Statistic: "p5", Normalize: "100*value/tickets_write", Low: 0, High: 100, HigherIsBetter: true, Severity: "critical"},
+	{Metric: "wt_cache_used", Label: "wt_cache_used %", Statistic: "p95", Normalize: "100*value/cache", Low: 80, High: 95, Severity: "critical"},
+	{Metric: "wt_cache_dirty", Label: "wt_cache_dirty %", Statistic: "p95", Normalize: "100*value/cache", Low: 5, High: 20, Severity: "critical"},
+	{Metric: "wt_dhandles_active", Label: "wt_dhandles_active", Statistic: "p95", Low: 16000, High: 20000, Severity: "warning"},
+	{Metric: "wt_modified_evicted", Label: "wt_mod_evicted %", Statistic: "p95", Normalize: "100*value/cache_pages", Low: 5, High: 10, Severity: "warning"},
+	{Metric: "wt_unmodified_evicted", Label: "wt_unmod_evicted %", Statistic: "p95", Normalize: "100*value/cache_pages", Low: 5, High: 10, Severity: "warning"},
+}
+
+var reStatistic = regexp.MustCompile(`^(p\d{1,2}|median|avg|min|max)$`)
+var reMaxRatio = regexp.MustCompile(`^max_ratio\(([^()]+)\)$`)
+var reNormalizeTokens = regexp.MustCompile(`\s*([0-9.]+|[A-Za-z_]+|[*/])\s*`)
+
+// LoadAssessmentRules returns default rules replaced and appended by rules of a JSON file,
+// either an array of rules or {"rules": [...]}
+func LoadAssessmentRules(filename string) ([]AssessmentRule, error) {
+	var err error
+	var data []byte
+	if data, err = ioutil.ReadFile(filename); err != nil {
+		return nil, err
+	}
+	var rules []AssessmentRule
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		err = json.Unmarshal(data, &rules)
+	} else {
+		var doc struct {
+			Rules []AssessmentRule `json:"rules"`
+		}
+		err = json.Unmarshal(data, &doc)
+		rules = doc.Rules
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", filename, err)
+	}
+	return MergeAssessmentRules(DefaultAssessmentRules, rules)
+}
+
+// MergeAssessmentRules returns rules replaced and appended by other rules, by metrics
+func MergeAssessmentRules(rules []AssessmentRule, others []AssessmentRule) ([]AssessmentRule, error) {
+	merged := append([]AssessmentRule{}, rules...)
+	for _, rule := range others {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		replaced := false
+		for i := range merged {
+			if merged[i].Metric == rule.Metric {
+				merged[i] = rule
+				replaced = true
+			}
+		}
+		if replaced == false {
+			merged = append(merged, rule)
+		}
+	}
+	return merged, nil
+}
+
+// Validate returns an error if the statistic or the normalize expression is invalid
+func (rule AssessmentRule) Validate() error {
+	if rule.Metric == "" {
+		return errors.New("metric is required")
+	}
+	if rule.High < rule.Low {
+		return fmt.Errorf("rule %v: high %v is less than low %v", rule.Metric, rule.High, rule.Low)
+	}
+	if rule.Severity != "" && getIndexOf(Severities, rule.Severity) < 0 {
+		return fmt.Errorf("rule %v: unsupported severity %v", rule.Metric, rule.Severity)
+	}
+	if _, err := GetStatistic([]float64{1}, rule.Statistic); err != nil && reMaxRatio.MatchString(rule.Statistic) == false {
+		return fmt.Errorf("rule %v: %v", rule.Metric, err)
+	}
+	if rule.Normalize != "" {
+		vars := map[string]float64{}
+		for _, name := range NormalizeVariables {
+			vars[name] = 1
+		}
+		if _, err := Normalize(rule.Normalize, 1, vars); err != nil {
+			return fmt.Errorf("rule %v: %v", rule.Metric, err)
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the rule is of the metric
+func (rule AssessmentRule) Matches(metric string) bool {
+	if strings.HasSuffix(rule.Metric, "_") {
+		return strings.HasPrefix(metric, rule.Metric)
+	}
+	return metric == rule.Metric
+}
+
+// Formula returns a description of the rule, e.g. 100*(p95 of wt_cache_used)/cache
+func (rule AssessmentRule) Formula() string {
+	metric := rule.Metric
+	if strings.HasSuffix(metric, "_") {
+		metric += "<x>"
+	}
+	formula := rule.Statistic + " of " + metric
+	if matches := reMaxRatio.FindStringSubmatch(rule.Statistic); matches != nil {
+		formula = fmt.Sprintf("max of %v/%v", metric, matches[1])
+	}
+	if rule.Normalize != "" {
+		formula = strings.Replace(rule.Normalize, "value", "("+formula+")", 1)
+	}
+	if rule.HigherIsBetter == true {
+		formula += ", higher is better"
+	}
+	return formula
+}
+
+// Score returns the normalized statistic and a score, 0 to 100, or 101 if not scored. values
+// are data points of the metric and others are data points of other metrics by names.
+func (rule AssessmentRule) Score(values []float64, others map[string][]float64, vars map[string]float64) (float64, int) {
+	var err error
+	var v float64
+	if len(values) == 0 {
+		return math.NaN(), 101
+	}
+	if rule.MinValue > 0 {
+		if p95, _ := GetStatistic(values, "p95"); p95 < rule.MinValue {
+			return math.NaN(), 101
+		}
+	}
+	if matches := reMaxRatio.FindStringSubmatch(rule.Statistic); matches != nil {
+		v = getMaxRatio(values, others[matches[1]])
+	} else if v, err = GetStatistic(values, rule.Statistic); err != nil {
+		return math.NaN(), 101
+	}
+	if rule.Normalize != "" {
+		if v, err = Normalize(rule.Normalize, v, vars); err != nil {
+			return math.NaN(), 101
+		}
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v, 101
+	}
+	score := GetScoreByRange(v, rule.Low, rule.High)
+	if rule.HigherIsBetter == true {
+		score = 100 - score
+	}
+	return v, score
+}
+
+// getMaxRatio returns the max ratio of values to other values of the same data points
+func getMaxRatio(values []float64, others []float64) float64 {
+	max := math.NaN()
+	for i := 0; i < len(values) && i < len(others); i++ {
+		if others[i] == 0 {
+			continue
+		}
+		if ratio := values[i] / others[i]; math.IsNaN(max) || ratio > max {
+			max = ratio
+		}
+	}
+	return max
+}
+
+// GetStatistic returns pN, median, avg, min, or max of values, or a ratio of two, e.g. p95/median
+func GetStatistic(values []float64, statistic string) (float64, error) {
+	if n := strings.Index(statistic, "/"); n > 0 {
+		a, err := GetStatistic(values, statistic[:n])
+		if err != nil {
+			return 0, err
+		}
+		b, err := GetStatistic(values, statistic[n+1:])
+		if err != nil {
+			return 0, err
+		}
+		return a / b, nil
+	}
+	if reStatistic.MatchString(statistic) == false {
+		return 0, errors.New("unsupported statistic " + statistic)
+	}
+	if len(values) == 0 {
+		return math.NaN(), nil
+	}
+	arr := append([]float64{}, values...)
+	sort.Float64s(arr)
+	switch statistic {
+	case "avg":
+		sum := 0.0
+		for _, v := range arr {
+			sum += v
+		}
+		return sum / float64(len(arr)), nil
+	case "min":
+		return arr[0], nil
+	case "max":
+		return arr[len(arr)-1], nil
+	case "median":
+		statistic = "p50"
+	}
+	pct, _ := strconv.Atoi(statistic[1:])
+	return arr[getPercentileIndex(len(arr), float64(pct)/100)], nil
+}
+
+// getPercentileIndex returns the index of a percentile the same way as Assessment.getStatsByData
+func getPercentileIndex(length int, percentile float64) int {
+	n := int(float64(length+1) * percentile)
+	if n > length-1 {
+		n = length - 1
+	}
+	return n
+}
+
+// Normalize evaluates a normalize expression of value and variables, numbers, and * and / from left to right
+func Normalize(expression string, value float64, vars map[string]float64) (float64, error) {
+	tokens := reNormalizeTokens.FindAllStringSubmatch(expression, -1)
+	if len(strings.Join(reNormalizeTokens.FindAllString(expression, -1), "")) != len(expression) {
+		return 0, errors.New("invalid normalize expression " + expression)
+	}
+	if len(tokens)%2 == 0 || strings.Contains(expression, "value") == false {
+		return 0, errors.New("invalid normalize expression " + expression)
+	}
+	result := 0.0
+	op := "*"
+	for i, token := range tokens {
+		str := token[1]
+		if i%2 == 1 {
+			if str != "*" && str != "/" {
+				return 0, errors.New("invalid normalize expression " + expression)
+			}
+			op = str
+			continue
+		}
+		var operand float64
+		var err error
+		if str == "value" {
+			operand = value
+		} else if v, ok := vars[str]; ok == true {
+			operand = v
+		} else if operand, err = strconv.ParseFloat(str, 64); err != nil {
+			return 0, errors.New("unknown variable " + str + " of " + expression)
+		}
+		if i == 0 {
+			result = operand
+		} else if op == "*" {
+			result *= operand
+		} else {
+			result /= operand
+		}
+	}
+	return result, nil
+}
+
+func getIndexOf(list []string, value string) int {
+	for i, v := range list {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
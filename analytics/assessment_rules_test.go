@@ -0,0 +1,137 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetStatistic(t *testing.T) {
+	values := []float64{}
+	for i := 100; i > 0; i-- {
+		values = append(values, float64(i))
+	}
+	for statistic, expected := range map[string]float64{"p5": 6, "median": 51, "p95": 96, "avg": 50.5,
+		"min": 1, "max": 100, "p95/median": 96.0 / 51} {
+		if v, err := GetStatistic(values, statistic); err != nil || v != expected {
+			t.Fatal(statistic, v, err)
+		}
+	}
+	if _, err := GetStatistic(values, "p100"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	vars := map[string]float64{"cache": 4, "cores": 2}
+	if v, err := Normalize("100*value/cache", 1, vars); err != nil || v != 25 {
+		t.Fatal(v, err)
+	}
+	if v, err := Normalize("value / cores", 8, vars); err != nil || v != 4 {
+		t.Fatal(v, err)
+	}
+	for _, expression := range []string{"100*cache", "value+1", "value/RAM", "value*"} {
+		if _, err := Normalize(expression, 1, vars); err == nil {
+			t.Fatal("expected error", expression)
+		}
+	}
+}
+
+func TestAssessmentRuleScore(t *testing.T) {
+	vars := map[string]float64{"cache": 10}
+	values := []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 2}
+	rule := AssessmentRule{Metric: "wt_cache_dirty", Statistic: "max", Normalize: "100*value/cache", Low: 5, High: 20}
+	if v, score := rule.Score(values, nil, vars); v != 20 || score != 0 {
+		t.Fatal(v, score)
+	}
+	rule = AssessmentRule{Metric: "ticket_avail_write", Statistic: "p5", Normalize: "100*value/128", Low: 0, High: 100,
+		HigherIsBetter: true}
+	if _, score := rule.Score([]float64{64, 128}, nil, vars); score != 50 {
+		t.Fatal(score)
+	}
+	rule = AssessmentRule{Metric: "scan_objects", Statistic: "max_ratio(scan_keys)", MinValue: 1000, Low: 2, High: 5}
+	if _, score := rule.Score([]float64{10, 20}, nil, vars); score != 101 {
+		t.Fatal(score)
+	}
+	others := map[string][]float64{"scan_keys": {1000, 1000}}
+	if v, score := rule.Score([]float64{2000, 5000}, others, vars); v != 5 || score != 0 {
+		t.Fatal(v, score)
+	}
+	rule = AssessmentRule{Metric: "mem_resident", Statistic: "p95", Normalize: "100*value/RAM", Low: 70, High: 90}
+	if v, score := rule.Score(values, nil, map[string]float64{"RAM": 0}); math.IsInf(v, 1) == false || score != 101 {
+		t.Fatal(v, score)
+	}
+}
+
+func TestTotalTicketsVariables(t *testing.T) {
+	stats := FTDCStats{}
+	if as := NewAssessment(stats); as.vars["tickets_read"] != 128 || as.vars["tickets_write"] != 128 {
+		t.Fatal(as.vars)
+	}
+	ss := ServerStatusDoc{}
+	ss.WiredTiger.ConcurrentTransactions.Read.TotalTickets = 256
+	ss.WiredTiger.ConcurrentTransactions.Write.TotalTickets = 64
+	stats.ServerStatusList = []ServerStatusDoc{ss}
+	as := NewAssessment(stats)
+	if as.vars["tickets_read"] != 256 || as.vars["tickets_write"] != 64 {
+		t.Fatal(as.vars)
+	}
+	rule := as.getRule("ticket_avail_write")
+	if _, score := rule.Score([]float64{32, 64}, nil, as.vars); rule.Normalize != "100*value/tickets_write" || score != 50 {
+		t.Fatal(rule, score)
+	}
+}
+
+func TestLoadAssessmentRules(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "rules")
+	defer os.RemoveAll(dir)
+	filename := dir + "/rules.json"
+	ioutil.WriteFile(filename, []byte(`{"rules": [
+  {"metric": "ticket_avail_write", "label": "ticket_avail_write", "statistic": "p5", "low": 10, "high": 50,
+   "higherIsBetter": true, "severity": "critical"},
+  {"metric": "conns_active", "label": "conns_active per core", "statistic": "p99", "normalize": "value/cores",
+   "low": 10, "high": 50, "severity": "warning"}]}`), 0644)
+	rules, err := LoadAssessmentRules(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != len(DefaultAssessmentRules)+1 {
+		t.Fatal(len(rules))
+	}
+	as := NewAssessment(FTDCStats{})
+	as.SetRules(rules)
+	if rule := as.getRule("ticket_avail_write"); rule == nil || rule.Normalize != "" || rule.Low != 10 {
+		t.Fatal(rule)
+	}
+	if rule := as.getRule("disku_sda"); rule == nil || rule.Metric != "disku_" {
+		t.Fatal(rule)
+	}
+
+	ioutil.WriteFile(filename, []byte(`[{"metric": "cpu_user", "statistic": "p95", "low": 50, "high": 70, "severity": "fatal"}]`), 0644)
+	if _, err = LoadAssessmentRules(filename); err == nil || strings.Contains(err.Error(), "severity") == false {
+		t.Fatal(err)
+	}
+}
+
+func TestPrintAssessment(t *testing.T) {
+	stats := FTDCStats{TimeSeriesData: map[string]TimeSeriesDoc{}, MaxWTCache: 1}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for metric, value := range map[string]float64{"mem_resident": 1, "wt_cache_dirty": .5, "latency_read": 10} {
+		doc := TimeSeriesDoc{Target: metric}
+		for i := 0; i < 10; i++ {
+			doc.DataPoints = append(doc.DataPoints, []float64{value, float64(start.Add(time.Duration(i)*time.Second).UnixNano() / 1000000)})
+		}
+		stats.TimeSeriesData[metric] = doc
+	}
+	str := NewAssessment(stats).PrintAssessment()
+	if strings.Contains(str, "|wt_cache_dirty %          |      0|          50|          50|          50|critical  |") == false ||
+		strings.Contains(str, "|latency_read (ms)         |    100|") == false {
+		t.Fatal(str)
+	}
+	t.Log(str)
+}
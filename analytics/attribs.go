@@ -70,6 +70,8 @@ func (attr *Attribs) GetServerStatusDataPoints(i int) ServerStatusDoc {
 	ss.WiredTiger.DataHandle.Active = attr.get("serverStatus/wiredTiger/data-handle/connection data handles currently active", i)
 	ss.WiredTiger.ConcurrentTransactions.Read.Available = attr.get("serverStatus/wiredTiger/concurrentTransactions/read/available", i)
 	ss.WiredTiger.ConcurrentTransactions.Write.Available = attr.get("serverStatus/wiredTiger/concurrentTransactions/write/available", i)
+	ss.WiredTiger.ConcurrentTransactions.Read.TotalTickets = attr.get("serverStatus/wiredTiger/concurrentTransactions/read/totalTickets", i)
+	ss.WiredTiger.ConcurrentTransactions.Write.TotalTickets = attr.get("serverStatus/wiredTiger/concurrentTransactions/write/totalTickets", i)
 	return ss
 }
 
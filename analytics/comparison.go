@@ -0,0 +1,327 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const minSignificantChange = 5.0 // percentage of change of medians to be significant
+const significantT = 3.0         // Welch's t statistic of a significant change
+const likelyT = 2.0              // Welch's t statistic of a likely change
+
+// tTestBlock is the block of means tested, per-second samples are autocorrelated and not independent
+const tTestBlock = time.Minute
+
+// Comparison compares stats of metrics of a baseline window and a compared window of FTDC data,
+// e.g. before and after an upgrade
+type Comparison struct {
+	sync.Mutex
+	baseline *comparisonWindow
+	compare  *comparisonWindow
+	rules    []AssessmentRule
+	verbose  bool
+}
+
+// comparisonWindow is a directory of diagnostic data, a time range, or all of the data if both are empty
+type comparisonWindow struct {
+	filename string
+	from     time.Time
+	stats    *FTDCStats // decoded from the directory
+	to       time.Time
+}
+
+type metricComparison struct {
+	baseline metricStats
+	change   float64 // percentage of change of medians
+	compare  metricStats
+	hint     string
+	rank     int // of the hint
+}
+
+// ComparisonWindow is a directory or a file of diagnostic data, or a time range From to To.
+// An empty window is of all data.
+type ComparisonWindow struct {
+	Filename string
+	From     time.Time
+	To       time.Time
+}
+
+// NewComparison returns a comparison of a baseline window to a compared window
+func NewComparison(baseline ComparisonWindow, compare ComparisonWindow) (*Comparison, error) {
+	var err error
+	c := Comparison{rules: DefaultAssessmentRules}
+	if c.baseline, err = newComparisonWindow(baseline); err != nil {
+		return nil, err
+	}
+	if c.compare, err = newComparisonWindow(compare); err != nil {
+		return nil, err
+	}
+	return &c, err
+}
+
+func newComparisonWindow(window ComparisonWindow) (*comparisonWindow, error) {
+	w := comparisonWindow{filename: window.Filename, from: window.From, to: window.To}
+	if w.from.IsZero() && w.to.IsZero() {
+		return &w, nil
+	}
+	if w.from.IsZero() || w.to.IsZero() || w.to.Before(w.from) {
+		return nil, fmt.Errorf("invalid window %v,%v, from must be before to",
+			w.from.Format(time.RFC3339), w.to.Format(time.RFC3339))
+	}
+	return &w, nil
+}
+
+// SetRules sets assessment rules
+func (c *Comparison) SetRules(rules []AssessmentRule) {
+	if len(rules) > 0 {
+		c.rules = rules
+	}
+}
+
+// SetVerbose sets verbose mode to compare all metrics
+func (c *Comparison) SetVerbose(verbose bool) {
+	c.verbose = verbose
+}
+
+// getStats returns stats of a window, decoded from its directory, or the stats given
+func (c *Comparison) getStats(w *comparisonWindow, stats *FTDCStats) (*FTDCStats, error) {
+	if w.filename == "" {
+		return stats, nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	if w.stats == nil {
+		d := NewDiagnosticData()
+		if err := d.DecodeDiagnosticData([]string{w.filename}); err != nil {
+			return nil, err
+		}
+		w.stats = d.getFTDCStats()
+	}
+	return w.stats, nil
+}
+
+// getRange returns the time range of a window, all data points of stats if not a time range
+func (w *comparisonWindow) getRange(stats FTDCStats) (time.Time, time.Time) {
+	if w.from.IsZero() == false {
+		return w.from, w.to
+	}
+	return getTimeRange(stats)
+}
+
+// getMetricsComparison returns stats of metrics of both windows, metrics scored or changed, or all
+// if verbose, sorted by significance
+func (c *Comparison) getMetricsComparison(stats *FTDCStats) ([]metricComparison, error) {
+	var err error
+	var bstats, cstats *FTDCStats
+	if bstats, err = c.getStats(c.baseline, stats); err != nil {
+		return nil, err
+	}
+	if cstats, err = c.getStats(c.compare, stats); err != nil {
+		return nil, err
+	}
+	bas := NewAssessment(*bstats)
+	bas.SetRules(c.rules)
+	cas := NewAssessment(*cstats)
+	cas.SetRules(c.rules)
+	bfrom, bto := c.baseline.getRange(*bstats)
+	cfrom, cto := c.compare.getRange(*cstats)
+	metrics, bdata := bas.getMetricsData()
+	cmetrics, cdata := cas.getMetricsData()
+	for _, metric := range cmetrics { // disks of the compared window only
+		if _, ok := bdata[metric]; ok == false {
+			metrics = append(metrics, metric)
+		}
+	}
+	list := []metricComparison{}
+	for _, metric := range metrics {
+		bvalues := getFilteredValues(bdata[metric], bfrom, bto)
+		cvalues := getFilteredValues(cdata[metric], cfrom, cto)
+		if len(bvalues) == 0 && len(cvalues) == 0 {
+			continue
+		}
+		mc := metricComparison{baseline: bas.getStatsArray(metric, bdata[metric], bfrom, bto),
+			compare: cas.getStatsArray(metric, cdata[metric], cfrom, cto)}
+		bmeans := getBlockMeans(bdata[metric], bfrom, bto, tTestBlock)
+		cmeans := getBlockMeans(cdata[metric], cfrom, cto, tTestBlock)
+		mc.change, mc.hint, mc.rank = getSignificance(bvalues, cvalues, bmeans, cmeans, bas.getRule(metric))
+		scored := mc.baseline.score < 101 || mc.compare.score < 101
+		if scored || mc.rank < 3 || c.verbose {
+			list = append(list, mc)
+		}
+	}
+	sort.SliceStable(list, func(i int, j int) bool {
+		if list[i].rank != list[j].rank {
+			return list[i].rank < list[j].rank
+		}
+		return list[i].baseline.label < list[j].baseline.label
+	})
+	return list, err
+}
+
+// getSignificance returns the percentage of change of medians and a hint of significance, by
+// Welch's t-test of block means, and whether better or worse by the rule, with a rank of worse, better,
+// changed, not significant, and insufficient data
+func getSignificance(bvalues []float64, cvalues []float64, bmeans []float64, cmeans []float64,
+	rule *AssessmentRule) (float64, string, int) {
+	if len(bvalues) < 2 || len(cvalues) < 2 || len(bmeans) < 2 || len(cmeans) < 2 {
+		return math.NaN(), "insufficient data", 4
+	}
+	bmedian := getMedian(bvalues)
+	cmedian := getMedian(cvalues)
+	change := 0.0
+	if bmedian != 0 {
+		change = 100 * (cmedian - bmedian) / math.Abs(bmedian)
+	} else if cmedian != 0 {
+		change = math.Inf(1)
+	}
+	t := getWelchT(bmeans, cmeans)
+	if math.Abs(t) < likelyT || math.Abs(change) < minSignificantChange {
+		return change, "not significant", 3
+	}
+	hint := "likely"
+	if math.Abs(t) >= significantT {
+		hint = "significant"
+	}
+	higher := cmedian > bmedian
+	if rule == nil {
+		if higher {
+			return change, hint + ", higher", 2
+		}
+		return change, hint + ", lower", 2
+	} else if higher == rule.HigherIsBetter {
+		return change, hint + ", better", 1
+	}
+	return change, hint + ", worse", 0
+}
+
+// getBlockMeans returns means of valid data points of a window by blocks of a duration, e.g. per-minute
+// averages
+func getBlockMeans(data TimeSeriesDoc, from time.Time, to time.Time, block time.Duration) []float64 {
+	means := []float64{}
+	ms := float64(block / time.Millisecond)
+	sum, count, current := 0.0, 0, math.NaN()
+	for _, dp := range FilterTimeSeriesData(data, from, to).DataPoints {
+		if isValidDataPoint(dp) == false {
+			continue
+		}
+		if n := math.Floor(dp[1] / ms); n != current {
+			if count > 0 {
+				means = append(means, sum/float64(count))
+			}
+			sum, count, current = 0, 0, n
+		}
+		sum += dp[0]
+		count++
+	}
+	if count > 0 {
+		means = append(means, sum/float64(count))
+	}
+	return means
+}
+
+// getWelchT returns Welch's t statistic of two samples
+func getWelchT(a []float64, b []float64) float64 {
+	amean, bmean := getMean(a), getMean(b)
+	se := math.Sqrt(getVariance(a, amean)/float64(len(a)) + getVariance(b, bmean)/float64(len(b)))
+	if se == 0 {
+		if amean == bmean {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return (bmean - amean) / se
+}
+
+// getVariance returns the sample variance
+func getVariance(values []float64, mean float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return sum / float64(len(values)-1)
+}
+
+func formatChange(change float64) string {
+	if math.IsNaN(change) {
+		return "-"
+	} else if math.IsInf(change, 0) {
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%%", change)
+}
+
+func formatScores(b metricStats, c metricStats) string {
+	if b.score == 101 && c.score == 101 {
+		return "-"
+	}
+	return fmt.Sprintf("%d -> %d", b.score, c.score)
+}
+
+// describe returns the source and the time range of a window
+func (w *comparisonWindow) describe(stats FTDCStats) string {
+	from, to := w.getRange(stats)
+	source := "all data"
+	if w.filename != "" {
+		source = w.filename
+	} else if w.from.IsZero() == false {
+		source = "range"
+	}
+	return fmt.Sprintf("%v, %v to %v", source, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+}
+
+// PrintComparison prints medians of metrics of both windows, changes, scores, and hints of significance
+func (c *Comparison) PrintComparison(stats *FTDCStats) (string, error) {
+	list, err := c.getMetricsComparison(stats)
+	if err != nil {
+		return "", err
+	}
+	bstats, _ := c.getStats(c.baseline, stats)
+	cstats, _ := c.getStats(c.compare, stats)
+	var lines []string
+	lines = append(lines, "\n--- Comparison ---")
+	lines = append(lines, "Baseline: "+c.baseline.describe(*bstats))
+	lines = append(lines, "Compare:  "+c.compare.describe(*cstats))
+	lines = append(lines, "+--------------------------+------------+------------+---------+-----------+----------------------+")
+	lines = append(lines, "| Metric                   | Baseline   | Compare    | Change  | Score     | Significance         |")
+	lines = append(lines, "|--------------------------+------------+------------+---------+-----------+----------------------|")
+	for _, v := range list {
+		lines = append(lines, fmt.Sprintf("|%-26s|%12.0f|%12.0f|%9s|%-11s|%-22s|", v.baseline.label, v.baseline.median,
+			v.compare.median, formatChange(v.change), formatScores(v.baseline, v.compare), v.hint))
+	}
+	lines = append(lines, "+--------------------------+------------+------------+---------+-----------+----------------------+")
+	lines = append(lines, "Baseline and Compare are medians, Change is of medians, and Significance is of Welch's t-test of per-minute means.")
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// GetComparison gets a comparison table of a Grafana table panel
+func (c *Comparison) GetComparison(stats *FTDCStats) map[string]interface{} {
+	var headerList []map[string]string
+	var rowList [][]interface{}
+	list, err := c.getMetricsComparison(stats)
+	if err != nil {
+		headerList = append(headerList, map[string]string{"text": "Reason", "type": "string"})
+		rowList = append(rowList, []interface{}{err.Error()})
+		return map[string]interface{}{"columns": headerList, "type": "table", "rows": rowList}
+	}
+	for _, text := range []string{"Metric", "Baseline p5", "Baseline Median", "Baseline p95", "Compare p5",
+		"Compare Median", "Compare p95", "Change", "Score", "Significance"} {
+		headerList = append(headerList, map[string]string{"text": text, "type": "Number"})
+	}
+	for _, i := range []int{0, 7, 8, 9} { // Metric, Change, Score, and Significance
+		headerList[i]["type"] = "string"
+	}
+	for _, v := range list {
+		rowList = append(rowList, []interface{}{v.baseline.label, v.baseline.p5, v.baseline.median, v.baseline.p95,
+			v.compare.p5, v.compare.median, v.compare.p95, formatChange(v.change), formatScores(v.baseline, v.compare), v.hint})
+	}
+	return map[string]interface{}{"columns": headerList, "type": "table", "rows": rowList}
+}
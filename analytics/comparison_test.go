@@ -0,0 +1,129 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getTestComparisonStats(start time.Time) *FTDCStats {
+	stats := FTDCStats{TimeSeriesData: map[string]TimeSeriesDoc{}, MaxWTCache: 1}
+	stats.ServerInfo.HostInfo.System.NumCores = 2
+	for i := 0; i < 120; i++ {
+		ms := float64(start.Add(time.Duration(i)*time.Minute).UnixNano() / int64(time.Millisecond))
+		latency, tickets, queries := 10.0+float64(i%3), 128.0, 1000.0+float64(i%5)
+		if i >= 60 {
+			latency, tickets = 30+float64(i%3), 64
+		}
+		for metric, value := range map[string]float64{"mem_resident": 1, "latency_read": latency,
+			"ticket_avail_read": tickets, "ops_query": queries} {
+			doc := stats.TimeSeriesData[metric]
+			doc.Target = metric
+			doc.DataPoints = append(doc.DataPoints, []float64{value, ms})
+			stats.TimeSeriesData[metric] = doc
+		}
+	}
+	return &stats
+}
+
+func TestComparison(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats := getTestComparisonStats(start)
+	c, err := NewComparison(getTestComparisonWindows())
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := c.getMetricsComparison(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hints := map[string]string{}
+	for _, v := range list {
+		hints[v.baseline.label] = v.hint
+	}
+	if len(list) == 0 || list[0].hint != "significant, worse" || hints["latency_read (ms)"] != "significant, worse" ||
+		hints["ticket_avail_read %"] != "significant, worse" || hints["ops_query"] != "not significant" {
+		t.Fatal(hints)
+	}
+	str, err := c.PrintComparison(stats)
+	if err != nil || strings.Contains(str, "|latency_read (ms)         |          11|          31|  +181.8%|") == false {
+		t.Fatal(str, err)
+	}
+	t.Log(str)
+
+	c.SetVerbose(true)
+	if list, _ = c.getMetricsComparison(stats); len(list) != 4 || list[3].hint != "not significant" {
+		t.Fatal(list)
+	}
+}
+
+func getTestComparisonWindows() (ComparisonWindow, ComparisonWindow) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return ComparisonWindow{From: start, To: start.Add(time.Hour - time.Second)},
+		ComparisonWindow{From: start.Add(time.Hour), To: start.Add(2*time.Hour - time.Second)}
+}
+
+func TestNewComparisonWindow(t *testing.T) {
+	if w, err := newComparisonWindow(ComparisonWindow{Filename: os.TempDir()}); err != nil || w.filename != os.TempDir() {
+		t.Fatal(w, err)
+	}
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if w, err := newComparisonWindow(ComparisonWindow{From: from, To: from.Add(time.Hour)}); err != nil ||
+		w.to.Equal(from.Add(time.Hour)) == false {
+		t.Fatal(w, err)
+	}
+	for _, window := range []ComparisonWindow{{From: from, To: from.Add(-time.Hour)}, {From: from}, {To: from}} {
+		if _, err := newComparisonWindow(window); err == nil {
+			t.Fatal("expected error", window)
+		}
+	}
+}
+
+func TestComparisonTable(t *testing.T) {
+	m := &Metrics{ftdcStats: *getTestComparisonStats(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))}
+	c, _ := NewComparison(getTestComparisonWindows())
+	m.SetComparison(c)
+	qr := QueryRequest{Targets: []TargetDoc{{Target: "comparison", Type: "table"}}}
+	b, _ := json.Marshal(qr)
+	w := httptest.NewRecorder()
+	m.Handler(w, httptest.NewRequest(http.MethodPost, "/grafana/query", bytes.NewReader(b)))
+	var tables []struct {
+		Columns []map[string]string `json:"columns"`
+		Rows    [][]interface{}     `json:"rows"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &tables); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if len(tables) != 1 || len(tables[0].Columns) != 10 || len(tables[0].Rows) == 0 || tables[0].Rows[0][9] != "significant, worse" {
+		t.Fatal(w.Body.String())
+	}
+	for i, text := range []string{"Metric", "Change", "Score", "Significance"} {
+		if column := tables[0].Columns[[]int{0, 7, 8, 9}[i]]; column["text"] != text || column["type"] != "string" {
+			t.Fatal(column)
+		}
+	}
+}
+
+func TestGetBlockMeans(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	doc := TimeSeriesDoc{}
+	for i := 0; i < 180; i++ { // per-second samples of 3 minutes
+		ms := float64(start.Add(time.Duration(i)*time.Second).UnixNano() / int64(time.Millisecond))
+		doc.DataPoints = append(doc.DataPoints, []float64{float64(i / 60), ms})
+	}
+	means := getBlockMeans(doc, start, start.Add(3*time.Minute), time.Minute)
+	if len(means) != 3 || means[0] != 0 || means[2] != 2 {
+		t.Fatal(means)
+	}
+	values := getFilteredValues(doc, start, start.Add(3*time.Minute))
+	if _, hint, _ := getSignificance(values, values, means[:1], means[1:], nil); hint != "insufficient data" {
+		t.Fatal(hint)
+	}
+}
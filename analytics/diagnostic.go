@@ -60,9 +60,20 @@ type DiagnosticData struct {
 	ServerStatusList  []ServerStatusDoc
 	ReplSetStatusList []ReplSetStatusDoc
 	SystemMetricsList []SystemMetricsDoc
+	MetricSeries      map[string]TimeSeriesDoc // by metric path
+	comparison        *Comparison
 	endpoints         []string
+	format            string
+	ftdcStats         *FTDCStats
+	rules             []AssessmentRule
+	pathMatcher       *ftdc.PathMatcher
+	stride            int // every stride-th sample of a chunk is kept
 }
 
+// maxDiagnosticSamples is the max number of samples kept of all files, every other one is dropped when
+// over, and later files are read at the doubled stride, memory is bounded regardless of the number of files
+var maxDiagnosticSamples = 24 * 3600
+
 // DiagnosticDoc -
 type DiagnosticDoc struct {
 	Start            time.Time        `json:"start" bson:"start"`
@@ -116,9 +127,43 @@ func (d *DiagnosticData) PrintDiagnosticData(filenames []string) (string, error)
 		strs = append(strs, string(b))
 	}
 	strs = append(strs, PrintAllStats(d.ServerStatusList, -1))
+	if len(d.MetricSeries) > 0 {
+		strs = append(strs, PrintMetricSeries(d.MetricSeries, -1))
+	}
+	strs = append(strs, PrintIncidents(d.getFTDCStats().Incidents))
+	as := NewAssessment(*d.getFTDCStats())
+	as.SetRules(d.rules)
+	strs = append(strs, as.PrintAssessment())
+	if d.comparison != nil {
+		str, err := d.comparison.PrintComparison(d.getFTDCStats())
+		if err != nil {
+			return "", err
+		}
+		strs = append(strs, str)
+	}
 	return strings.Join(strs, "\n"), nil
 }
 
+// SetComparison sets a comparison of a baseline window and a compared window
+func (d *DiagnosticData) SetComparison(comparison *Comparison) {
+	d.comparison = comparison
+}
+
+// SetAssessmentRules sets assessment rules
+func (d *DiagnosticData) SetAssessmentRules(rules []AssessmentRule) {
+	d.rules = rules
+}
+
+// getFTDCStats returns time series data and incidents computed from decoded data
+func (d *DiagnosticData) getFTDCStats() *FTDCStats {
+	if d.ftdcStats == nil {
+		m := &Metrics{}
+		m.AddFTDCDetailStats(d)
+		d.ftdcStats = &m.ftdcStats
+	}
+	return d.ftdcStats
+}
+
 // readDiagnosticDir reads diagnotics.data from a directory
 func (d *DiagnosticData) readDiagnosticDir(dirname string) error {
 	var err error
@@ -159,84 +204,162 @@ func (d *DiagnosticData) readDiagnosticFiles(filenames []string) error {
 
 	btime := time.Now()
 	log.Printf("reading %d files with %d second(s) interval\n", len(filenames), 1)
-	var diagDataMap = map[string]DiagnosticData{}
-	nThreads := runtime.NumCPU() - 1
+	nThreads := runtime.GOMAXPROCS(0) - 1
 	if nThreads < 1 {
 		nThreads = 1
 	}
-	var wg = gox.NewWaitGroup(nThreads) // use 4 threads to read
-	for threadNum := 0; threadNum < len(filenames); threadNum++ {
-		filename := filenames[threadNum]
-		if strings.Index(filename, "metrics.") < 0 {
-			continue
+	metricsFilenames := []string{}
+	for _, filename := range filenames {
+		if strings.Index(filename, "metrics.") >= 0 {
+			metricsFilenames = append(metricsFilenames, filename)
 		}
-		wg.Add(1)
-		go func(filename string) {
-			defer wg.Done()
-			var diagData DiagnosticData
-			if diagData, err = d.readDiagnosticFile(filename); err == nil {
-				diagDataMap[filename] = diagData
-			}
-		}(filename)
-	}
-	wg.Wait()
-
-	keys := []string{}
-	for k := range diagDataMap {
-		keys = append(keys, k)
 	}
-	sort.Strings(keys)
-	for _, key := range keys {
-		if diagDataMap[key].ServerInfo != nil {
-			d.ServerInfo = diagDataMap[key].ServerInfo
+	// read a group of files at a time, one chunk at a time, and keep decoded samples only, thinned to at
+	// most maxDiagnosticSamples after each group
+	for i := 0; i < len(metricsFilenames); i += nThreads {
+		group := metricsFilenames[i:]
+		if len(group) > nThreads {
+			group = group[:nThreads]
+		}
+		diagDataList := make([]*DiagnosticData, len(group))
+		var wg = gox.NewWaitGroup(nThreads)
+		for n, filename := range group {
+			wg.Add(1)
+			go func(n int, filename string) {
+				defer wg.Done()
+				diagData, err := d.readDiagnosticFile(filename)
+				if err != nil {
+					log.Println(filepath.Base(filename), err)
+				}
+				diagDataList[n] = &diagData
+			}(n, filename)
+		}
+		wg.Wait()
+		for _, diagData := range diagDataList {
+			if diagData == nil {
+				continue
+			}
+			if diagData.ServerInfo != nil {
+				d.ServerInfo = diagData.ServerInfo
+			}
+			d.ServerStatusList = append(d.ServerStatusList, diagData.ServerStatusList...)
+			d.SystemMetricsList = append(d.SystemMetricsList, diagData.SystemMetricsList...)
+			d.ReplSetStatusList = append(d.ReplSetStatusList, diagData.ReplSetStatusList...)
+			if len(diagData.MetricSeries) > 0 {
+				if d.MetricSeries == nil {
+					d.MetricSeries = map[string]TimeSeriesDoc{}
+				}
+				mergeMetricSeries(d.MetricSeries, diagData.MetricSeries)
+			}
 		}
-		d.ServerStatusList = append(d.ServerStatusList, diagDataMap[key].ServerStatusList...)
-		d.SystemMetricsList = append(d.SystemMetricsList, diagDataMap[key].SystemMetricsList...)
-		d.ReplSetStatusList = append(d.ReplSetStatusList, diagDataMap[key].ReplSetStatusList...)
+		d.thinSamples()
 	}
 	log.Println(len(filenames), "files loaded, time spent:", time.Now().Sub(btime))
 	return err
 }
 
-// readDiagnosticFile reads diagnostic.data from a file
+// readDiagnosticFile reads diagnostic.data from a file, one chunk at a time
 func (d *DiagnosticData) readDiagnosticFile(filename string) (DiagnosticData, error) {
 	btm := time.Now()
-	var diagData = DiagnosticData{}
-	var buffer []byte
+	var diagData = DiagnosticData{pathMatcher: d.pathMatcher, stride: d.getStride()}
 	var err error
 	var r *bufio.Reader
 
 	if r, err = gox.NewFileReader(filename); err != nil {
 		return diagData, err
 	}
-	if buffer, err = ioutil.ReadAll(r); err != nil {
-		return diagData, err
-	}
-
-	metrics := ftdc.NewMetrics()
-	metrics.ReadAllMetrics(&buffer)
-	diagData.ServerInfo = metrics.Doc
-	for _, v := range metrics.Data {
+	reader := ftdc.NewChunkReader(r)
+	blocks := 0
+	skipped := 0
+	for {
+		v, e := reader.Next()
+		if e == io.EOF {
+			break
+		} else if e != nil { // skips the chunk and continues
+			log.Println(filepath.Base(filename), e)
+			skipped++
+			continue
+		}
+		blocks++
 		var doc DiagnosticDoc
 		bson.Unmarshal(v.Block, &doc) // first document
 		diagData.ReplSetStatusList = append(diagData.ReplSetStatusList, doc.ReplSetGetStatus)
 		attrib := NewAttribs(&v.DataPointsMap)
-		for i := 0; i < int(v.NumDeltas); i++ {
+		for i := 0; i < int(v.NumDeltas); i += diagData.getStride() {
 			ss := attrib.GetServerStatusDataPoints(i)
 			diagData.ServerStatusList = append(diagData.ServerStatusList, ss)
 			sm := attrib.GetSystemMetricsDataPoints(i)
 			diagData.SystemMetricsList = append(diagData.SystemMetricsList, sm)
 		}
+		diagData.addMetricSeries(v)
 	}
+	diagData.ServerInfo = reader.Doc
 
 	filename = filepath.Base(filename)
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 	mem := fmt.Sprintf("Memory Alloc = %v MiB, TotalAlloc = %v MiB", m.Alloc/(1024*1024), m.TotalAlloc/(1024*1024))
-	log.Println(filename, "blocks:", len(metrics.Data), ", time:", time.Now().Sub(btm), mem)
+	log.Println(filename, "blocks:", blocks, ", skipped:", skipped, ", time:", time.Now().Sub(btm), mem)
 	return diagData, err
 }
 
+func (d *DiagnosticData) getStride() int {
+	if d.stride < 1 {
+		return 1
+	}
+	return d.stride
+}
+
+// thinSamples drops every other sample and doubles the stride until at most maxDiagnosticSamples are kept
+func (d *DiagnosticData) thinSamples() {
+	for len(d.ServerStatusList) > maxDiagnosticSamples || len(d.SystemMetricsList) > maxDiagnosticSamples {
+		d.ServerStatusList = thinServerStatusList(d.ServerStatusList)
+		d.SystemMetricsList = thinSystemMetricsList(d.SystemMetricsList)
+		d.stride = 2 * d.getStride()
+	}
+	for len(d.ReplSetStatusList) > maxDiagnosticSamples {
+		d.ReplSetStatusList = thinReplSetStatusList(d.ReplSetStatusList)
+	}
+	for path, doc := range d.MetricSeries {
+		for len(doc.DataPoints) > maxDiagnosticSamples {
+			doc.DataPoints = thinDataPoints(doc.DataPoints)
+		}
+		d.MetricSeries[path] = doc
+	}
+}
+
+func thinServerStatusList(list []ServerStatusDoc) []ServerStatusDoc {
+	thinned := make([]ServerStatusDoc, 0, (len(list)+1)/2)
+	for i := 0; i < len(list); i += 2 {
+		thinned = append(thinned, list[i])
+	}
+	return thinned
+}
+
+func thinSystemMetricsList(list []SystemMetricsDoc) []SystemMetricsDoc {
+	thinned := make([]SystemMetricsDoc, 0, (len(list)+1)/2)
+	for i := 0; i < len(list); i += 2 {
+		thinned = append(thinned, list[i])
+	}
+	return thinned
+}
+
+func thinReplSetStatusList(list []ReplSetStatusDoc) []ReplSetStatusDoc {
+	thinned := make([]ReplSetStatusDoc, 0, (len(list)+1)/2)
+	for i := 0; i < len(list); i += 2 {
+		thinned = append(thinned, list[i])
+	}
+	return thinned
+}
+
+func thinDataPoints(points [][]float64) [][]float64 {
+	thinned := make([][]float64, 0, (len(points)+1)/2)
+	for i := 0; i < len(points); i += 2 {
+		thinned = append(thinned, points[i])
+	}
+	return thinned
+}
+
 // analyzeServerStatus -
 func (d *DiagnosticData) analyzeServerStatus(filename string) error {
 	var err error
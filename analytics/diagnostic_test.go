@@ -3,10 +3,18 @@
 package analytics
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"runtime"
+	"runtime/debug"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/simagix/keyhole/ftdc"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 const DiagnosticDataDirectory = "../diagnostic.data"
@@ -33,3 +41,88 @@ func TestReadDiagnosticFiles(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// writeTestDiagnosticFiles writes files of a chunk of per-second samples each
+func writeTestDiagnosticFiles(dir string, files int) ([]string, error) {
+	filenames := []string{}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for n := 0; n < files; n++ {
+		var buffer bytes.Buffer
+		encoder := ftdc.NewEncoder(&buffer)
+		encoder.WriteMetadata(bson.D{{Key: "hostInfo", Value: bson.D{{Key: "system", Value: bson.D{{Key: "hostname", Value: "localhost"}}}}}})
+		for i := 0; i < ftdc.DefaultMaxSamples; i++ {
+			t := start.Add(time.Duration(n*ftdc.DefaultMaxSamples+i) * time.Second)
+			doc := bson.D{{Key: "start", Value: t},
+				{Key: "serverStatus", Value: bson.D{{Key: "localTime", Value: t}, {Key: "uptime", Value: int64(i)},
+					{Key: "opcounters", Value: bson.D{{Key: "query", Value: int64(10 * i)}}}}},
+				{Key: "systemMetrics", Value: bson.D{{Key: "cpu", Value: bson.D{{Key: "user_ms", Value: int64(i)}}}}}}
+			if err := encoder.Encode(doc); err != nil {
+				return filenames, err
+			}
+		}
+		encoder.Flush()
+		filename := fmt.Sprintf("%v/metrics.%v-00000", dir, start.Add(time.Duration(n)*time.Hour).Format("2006-01-02T15-04-05Z"))
+		if err := ioutil.WriteFile(filename, buffer.Bytes(), 0644); err != nil {
+			return filenames, err
+		}
+		filenames = append(filenames, filename)
+	}
+	return filenames, nil
+}
+
+// getPeakHeapAlloc returns the max heap allocated while running a function, over the heap before
+func getPeakHeapAlloc(fn func()) uint64 {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	base, peak := m.HeapAlloc, m.HeapAlloc
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				var s runtime.MemStats
+				runtime.ReadMemStats(&s)
+				if s.HeapAlloc > peak {
+					peak = s.HeapAlloc
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	fn()
+	done <- true
+	if peak < base {
+		return 0
+	}
+	return peak - base
+}
+
+func TestReadDiagnosticFilesBoundedMemory(t *testing.T) {
+	defer func(max int) { maxDiagnosticSamples = max }(maxDiagnosticSamples)
+	defer debug.SetGCPercent(debug.SetGCPercent(10)) // garbage is not counted as peak
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(2))  // a file at a time
+	maxDiagnosticSamples = 2 * ftdc.DefaultMaxSamples
+	dir, _ := ioutil.TempDir("", "diagnostic.data")
+	defer os.RemoveAll(dir)
+	filenames, err := writeTestDiagnosticFiles(dir, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peaks := []uint64{}
+	for _, files := range []int{8, 32} {
+		d := NewDiagnosticData()
+		peaks = append(peaks, getPeakHeapAlloc(func() { err = d.readDiagnosticFiles(filenames[:files]) }))
+		last := time.Date(2020, 1, 1, 0, 0, files*ftdc.DefaultMaxSamples-ftdc.DefaultMaxSamples, 0, time.UTC)
+		if err != nil || len(d.ServerStatusList) > maxDiagnosticSamples || len(d.ServerStatusList) != len(d.SystemMetricsList) ||
+			d.ServerStatusList[len(d.ServerStatusList)-1].LocalTime.Before(last) {
+			t.Fatal(err, len(d.ServerStatusList), len(d.SystemMetricsList))
+		}
+		t.Log(files, "files, samples kept:", len(d.ServerStatusList), ", stride:", d.stride, ", peak heap:", peaks[len(peaks)-1])
+	}
+	if peaks[1] > 2*peaks[0] {
+		t.Fatal("peak heap grows with the number of files", peaks)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is a server event derived from FTDC data, a restart, a member state change,
+// an election, or a config change
+type Event struct {
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+}
+
+// EventTypes are types of events
+var EventTypes = []string{"config", "election", "restart", "state"}
+
+var memberStateNames = map[int]string{0: "STARTUP", 1: PRIMARY, 2: SECONDARY, 3: "RECOVERING", 5: "STARTUP2",
+	6: "UNKNOWN", 7: "ARBITER", 8: "DOWN", 9: "ROLLBACK", 10: "REMOVED"}
+
+func getMemberStateName(state int) string {
+	if name, ok := memberStateNames[state]; ok == true {
+		return name
+	}
+	return fmt.Sprintf("state %d", state)
+}
+
+// GetEvents returns events from server status and replica set status in the order of time
+func GetEvents(serverStatusList []ServerStatusDoc, replSetStatusList []ReplSetStatusDoc) []Event {
+	events := getServerStatusEvents(serverStatusList)
+	events = append(events, getReplSetStatusEvents(replSetStatusList)...)
+	sort.SliceStable(events, func(i int, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events
+}
+
+// getServerStatusEvents returns restarts, uptime resets, and changes of version and WiredTiger cache size
+func getServerStatusEvents(serverStatusList []ServerStatusDoc) []Event {
+	events := []Event{}
+	for i := 1; i < len(serverStatusList); i++ {
+		prev, stat := serverStatusList[i-1], serverStatusList[i]
+		if stat.Uptime < prev.Uptime {
+			events = append(events, Event{Time: stat.LocalTime, Type: "restart",
+				Text: fmt.Sprintf("mongod restarted, uptime reset from %d to %d seconds", prev.Uptime, stat.Uptime)})
+		}
+		if prev.Version != "" && stat.Version != "" && prev.Version != stat.Version {
+			events = append(events, Event{Time: stat.LocalTime, Type: "config",
+				Text: fmt.Sprintf("version changed from %v to %v", prev.Version, stat.Version)})
+		}
+		pmax, max := prev.WiredTiger.Cache.MaxBytesConfigured, stat.WiredTiger.Cache.MaxBytesConfigured
+		if pmax > 0 && max > 0 && pmax != max {
+			events = append(events, Event{Time: stat.LocalTime, Type: "config",
+				Text: fmt.Sprintf("WiredTiger cache size changed from %.1f GB to %.1f GB",
+					float64(pmax)/(1024*1024*1024), float64(max)/(1024*1024*1024))})
+		}
+	}
+	return events
+}
+
+// getReplSetStatusEvents returns member state changes, elections, a new primary or a new term,
+// and members added or removed
+func getReplSetStatusEvents(replSetStatusList []ReplSetStatusDoc) []Event {
+	events := []Event{}
+	var prev ReplSetStatusDoc
+	for i, doc := range replSetStatusList {
+		if len(doc.Members) == 0 {
+			continue
+		}
+		if i == 0 || len(prev.Members) == 0 {
+			prev = doc
+			continue
+		}
+		pstates := map[string]int{}
+		pprimary := ""
+		for _, member := range prev.Members {
+			pstates[member.Name] = member.State
+			if member.State == 1 {
+				pprimary = member.Name
+			}
+		}
+		primary := ""
+		added := []string{}
+		for _, member := range doc.Members {
+			if member.State == 1 {
+				primary = member.Name
+			}
+			if state, ok := pstates[member.Name]; ok == false {
+				added = append(added, member.Name)
+			} else if state != member.State {
+				events = append(events, Event{Time: doc.Date, Type: "state", Text: fmt.Sprintf("%v changed from %v to %v",
+					member.Name, getMemberStateName(state), getMemberStateName(member.State))})
+			}
+			delete(pstates, member.Name)
+		}
+		removed := []string{}
+		for name := range pstates {
+			removed = append(removed, name)
+		}
+		sort.Strings(removed)
+		if len(added) > 0 {
+			events = append(events, Event{Time: doc.Date, Type: "config", Text: "members added: " + strings.Join(added, ", ")})
+		}
+		if len(removed) > 0 {
+			events = append(events, Event{Time: doc.Date, Type: "config", Text: "members removed: " + strings.Join(removed, ", ")})
+		}
+		if primary != "" && primary != pprimary {
+			events = append(events, Event{Time: doc.Date, Type: "election",
+				Text: fmt.Sprintf("%v elected primary, term %d", primary, doc.Term)})
+		} else if doc.Term > prev.Term && prev.Term > 0 {
+			events = append(events, Event{Time: doc.Date, Type: "election",
+				Text: fmt.Sprintf("term changed from %d to %d", prev.Term, doc.Term)})
+		}
+		prev = doc
+	}
+	return events
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetEvents(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	serverStatusList := []ServerStatusDoc{}
+	for i, uptime := range []uint64{100, 101, 5, 6} {
+		var ss ServerStatusDoc
+		ss.LocalTime = start.Add(time.Duration(i) * time.Minute)
+		ss.Uptime = uptime
+		ss.WiredTiger.Cache.MaxBytesConfigured = 1024 * 1024 * 1024
+		if i == 3 {
+			ss.WiredTiger.Cache.MaxBytesConfigured *= 2
+		}
+		serverStatusList = append(serverStatusList, ss)
+	}
+	replSetStatusList := []ReplSetStatusDoc{
+		{Date: start, Term: 1, Members: []MemberDoc{{Name: "a", State: 1}, {Name: "b", State: 2}}},
+		{Date: start.Add(5 * time.Minute), Term: 2, Members: []MemberDoc{{Name: "a", State: 2}, {Name: "b", State: 1},
+			{Name: "c", State: 5}}},
+		{Date: start.Add(10 * time.Minute), Term: 3, Members: []MemberDoc{{Name: "a", State: 2}, {Name: "b", State: 1}}},
+	}
+	events := GetEvents(serverStatusList, replSetStatusList)
+	expected := []Event{
+		{Type: "restart", Text: "mongod restarted, uptime reset from 101 to 5 seconds"},
+		{Type: "config", Text: "WiredTiger cache size changed from 1.0 GB to 2.0 GB"},
+		{Type: "state", Text: "a changed from PRIMARY to SECONDARY"},
+		{Type: "state", Text: "b changed from SECONDARY to PRIMARY"},
+		{Type: "config", Text: "members added: c"},
+		{Type: "election", Text: "b elected primary, term 2"},
+		{Type: "config", Text: "members removed: c"},
+		{Type: "election", Text: "term changed from 2 to 3"},
+	}
+	if len(events) != len(expected) {
+		t.Fatal(events)
+	}
+	for i, event := range events {
+		if event.Type != expected[i].Type || event.Text != expected[i].Text {
+			t.Fatal(i, event)
+		}
+	}
+}
+
+func TestAnnotationsByTags(t *testing.T) {
+	m := &Metrics{}
+	m.AddFTDCDetailStats(getTestHostDiagnosticData("primary:27017", 1024))
+	m.AddFTDCDetailStats(getTestHostDiagnosticData("secondary:27017", 1024))
+	tm := time.Date(2020, 1, 1, 0, 0, 1, 0, time.UTC)
+	m.hostStats["secondary:27017"].Events = []Event{{Text: "mongod restarted", Time: tm, Type: "restart"}}
+	m.hostStats["primary:27017"].Events = []Event{{Text: "b elected primary", Time: tm, Type: "election"}}
+
+	ar := AnnotationRequest{Annotation: map[string]interface{}{"query": "restart"},
+		Range: RangeDoc{From: tm.Add(-time.Hour), To: tm.Add(time.Hour)}}
+	b, _ := json.Marshal(ar)
+	w := httptest.NewRecorder()
+	m.Handler(w, httptest.NewRequest(http.MethodPost, "/grafana/annotations", bytes.NewReader(b)))
+	var list []Annotation
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Text != "mongod restarted" || len(list[0].Tags) != 2 || list[0].Tags[1] != "secondary:27017" {
+		t.Fatal(w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	m.Handler(w, httptest.NewRequest(http.MethodPost, "/grafana/tag-values", bytes.NewReader([]byte(`{"key": "host"}`))))
+	var values []map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &values); err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[1]["text"] != "secondary:27017" {
+		t.Fatal(w.Body.String())
+	}
+	if stats := m.getAdhocHostStats([]AdhocFilter{{Key: "host", Operator: "=", Value: "secondary:27017"}}); stats == nil ||
+		stats != m.hostStats["secondary:27017"] {
+		t.Fatal(stats)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SetExportFormat sets the format of exported time series, csv, jsonl, or openmetrics
+func (d *DiagnosticData) SetExportFormat(format string) error {
+	if format != "" && format != "csv" && format != "jsonl" && format != "openmetrics" {
+		return errors.New("unsupported export format " + format + ", use csv, jsonl, or openmetrics")
+	}
+	d.format = format
+	return nil
+}
+
+// OutputTimeSeries writes decoded time series in the format set by SetExportFormat
+func (d *DiagnosticData) OutputTimeSeries() error {
+	var err error
+	if d.format == "" {
+		return err
+	}
+	if len(d.ServerStatusList) == 0 {
+		return errors.New("no FTDC data to export")
+	}
+	stats := d.getFTDCStats()
+	tsd := stats.TimeSeriesData
+	hostname := stats.ServerInfo.HostInfo.System.Hostname
+	if hostname == "" {
+		hostname = "mongod"
+	}
+	outdir := "./out/"
+	os.Mkdir(outdir, 0755)
+	basename := outdir + strings.ReplaceAll(hostname, ":", "_") + "-ftdc"
+	var ofile string
+	var data []byte
+	switch d.format {
+	case "csv":
+		ofile = basename + ".csv"
+		var buffer bytes.Buffer
+		writer := csv.NewWriter(&buffer)
+		if err = writer.WriteAll(getTimeSeriesRecords(tsd)); err != nil {
+			return err
+		}
+		data = buffer.Bytes()
+	case "jsonl":
+		ofile = basename + ".jsonl"
+		if data, err = getTimeSeriesJSONLines(tsd); err != nil {
+			return err
+		}
+	case "openmetrics":
+		ofile = basename + ".om"
+		data = getTimeSeriesOpenMetrics(tsd, hostname)
+	}
+	if err = ioutil.WriteFile(ofile, data, 0644); err != nil {
+		return err
+	}
+	fmt.Println(d.format, "time series written to", ofile)
+	return err
+}
+
+// getTimeSeriesTargets returns sorted targets having valid data points
+func getTimeSeriesTargets(tsd map[string]TimeSeriesDoc) []string {
+	targets := []string{}
+	for k, v := range tsd {
+		for _, point := range v.DataPoints {
+			if isValidDataPoint(point) == true {
+				targets = append(targets, k)
+				break
+			}
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// getTimeSeriesRows returns sorted timestamps in ms and values of each timestamp by target
+func getTimeSeriesRows(tsd map[string]TimeSeriesDoc, targets []string) ([]float64, map[float64]map[string]float64) {
+	rows := map[float64]map[string]float64{}
+	for _, target := range targets {
+		for _, point := range tsd[target].DataPoints {
+			if isValidDataPoint(point) == false {
+				continue
+			}
+			if rows[point[1]] == nil {
+				rows[point[1]] = map[string]float64{}
+			}
+			if _, ok := rows[point[1]][target]; ok == false {
+				rows[point[1]][target] = point[0]
+			}
+		}
+	}
+	times := []float64{}
+	for t := range rows {
+		times = append(times, t)
+	}
+	sort.Float64s(times)
+	return times, rows
+}
+
+func isValidDataPoint(point []float64) bool {
+	return len(point) >= 2 && math.IsNaN(point[0]) == false && math.IsInf(point[0], 0) == false
+}
+
+func formatTimestamp(ms float64) string {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// getTimeSeriesRecords returns a row per timestamp and a column per target, empty if no data point
+func getTimeSeriesRecords(tsd map[string]TimeSeriesDoc) [][]string {
+	targets := getTimeSeriesTargets(tsd)
+	times, rows := getTimeSeriesRows(tsd, targets)
+	records := [][]string{append([]string{"timestamp"}, targets...)}
+	for _, t := range times {
+		record := []string{formatTimestamp(t)}
+		for _, target := range targets {
+			if v, ok := rows[t][target]; ok == true {
+				record = append(record, strconv.FormatFloat(v, 'f', -1, 64))
+			} else {
+				record = append(record, "")
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// getTimeSeriesJSONLines returns a JSON document per timestamp
+func getTimeSeriesJSONLines(tsd map[string]TimeSeriesDoc) ([]byte, error) {
+	var buffer bytes.Buffer
+	targets := getTimeSeriesTargets(tsd)
+	times, rows := getTimeSeriesRows(tsd, targets)
+	for _, t := range times {
+		doc := map[string]interface{}{"timestamp": formatTimestamp(t)}
+		for k, v := range rows[t] {
+			doc[k] = v
+		}
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(b)
+		buffer.WriteString("\n")
+	}
+	return buffer.Bytes(), nil
+}
+
+var reInvalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// getOpenMetricsName returns a valid metric name of a target, e.g. mongodb_wt_cache_used
+func getOpenMetricsName(target string) string {
+	return "mongodb_" + strings.Trim(reInvalidMetricChars.ReplaceAllString(target, "_"), "_")
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// getTimeSeriesOpenMetrics returns gauges with timestamps in seconds, samples of a metric family are
+// grouped together and targets sanitized to the same name are told apart by the target label
+func getTimeSeriesOpenMetrics(tsd map[string]TimeSeriesDoc, hostname string) []byte {
+	var buffer bytes.Buffer
+	families := map[string][]string{}
+	names := []string{}
+	for _, target := range getTimeSeriesTargets(tsd) {
+		name := getOpenMetricsName(target)
+		if families[name] == nil {
+			names = append(names, name)
+		}
+		families[name] = append(families[name], target)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buffer.WriteString(fmt.Sprintf("# TYPE %v gauge\n", name))
+		for _, target := range families[name] {
+			labels := fmt.Sprintf(`{host="%v",target="%v"}`, escapeLabelValue(hostname), escapeLabelValue(target))
+			last := math.Inf(-1)
+			for _, point := range tsd[target].DataPoints {
+				if isValidDataPoint(point) == false || point[1] <= last {
+					continue // timestamps must be increasing
+				}
+				last = point[1]
+				buffer.WriteString(fmt.Sprintf("%v%v %v %v\n", name, labels,
+					strconv.FormatFloat(point[0], 'f', -1, 64), strconv.FormatFloat(point[1]/1000, 'f', -1, 64)))
+			}
+		}
+	}
+	buffer.WriteString("# EOF\n")
+	return buffer.Bytes()
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func getTestTimeSeriesData() map[string]TimeSeriesDoc {
+	return map[string]TimeSeriesDoc{
+		"wt_cache_used": {Target: "wt_cache_used", DataPoints: [][]float64{{1.5, 1000}, {2, 2000}, {math.NaN(), 3000}}},
+		"serverStatus/metrics/repl/apply/ops": {Target: "serverStatus/metrics/repl/apply/ops",
+			DataPoints: [][]float64{{10, 2000}, {20, 3000}}},
+		"empty": {Target: "empty"},
+	}
+}
+
+func TestDiagnosticDataSetExportFormat(t *testing.T) {
+	d := NewDiagnosticData()
+	for _, format := range []string{"", "csv", "jsonl", "openmetrics"} {
+		if err := d.SetExportFormat(format); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.SetExportFormat("parquet"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestGetTimeSeriesRecords(t *testing.T) {
+	records := getTimeSeriesRecords(getTestTimeSeriesData())
+	if len(records) != 4 || strings.Join(records[0], ",") != "timestamp,serverStatus/metrics/repl/apply/ops,wt_cache_used" {
+		t.Fatal(records)
+	}
+	if strings.Join(records[1], ",") != "1970-01-01T00:00:01.000Z,,1.5" ||
+		strings.Join(records[3], ",") != "1970-01-01T00:00:03.000Z,20," {
+		t.Fatal(records)
+	}
+	data, err := getTimeSeriesJSONLines(getTestTimeSeriesData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 ||
+		lines[1] != `{"serverStatus/metrics/repl/apply/ops":10,"timestamp":"1970-01-01T00:00:02.000Z","wt_cache_used":2}` {
+		t.Fatal(lines)
+	}
+}
+
+func TestGetTimeSeriesOpenMetrics(t *testing.T) {
+	str := string(getTimeSeriesOpenMetrics(getTestTimeSeriesData(), "localhost"))
+	expected := `# TYPE mongodb_serverStatus_metrics_repl_apply_ops gauge
+mongodb_serverStatus_metrics_repl_apply_ops{host="localhost",target="serverStatus/metrics/repl/apply/ops"} 10 2
+mongodb_serverStatus_metrics_repl_apply_ops{host="localhost",target="serverStatus/metrics/repl/apply/ops"} 20 3
+# TYPE mongodb_wt_cache_used gauge
+mongodb_wt_cache_used{host="localhost",target="wt_cache_used"} 1.5 1
+mongodb_wt_cache_used{host="localhost",target="wt_cache_used"} 2 2
+# EOF
+`
+	if str != expected {
+		t.Fatal(str)
+	}
+}
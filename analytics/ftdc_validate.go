@@ -0,0 +1,68 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/simagix/gox"
+	"github.com/simagix/keyhole/ftdc"
+)
+
+// ValidateDiagnosticData summarizes health of FTDC files, chunks, samples, schema changes, and
+// chunks skipped with their offsets
+func (d *DiagnosticData) ValidateDiagnosticData(filenames []string) (string, error) {
+	fnames := []string{}
+	for _, filename := range GetMetricsFilenames(filenames) {
+		if strings.HasPrefix(filepath.Base(filename), "metrics.") {
+			fnames = append(fnames, filename)
+		}
+	}
+	if len(fnames) == 0 {
+		return "", errors.New("no valid data file found")
+	}
+	var lines []string
+	var details []string
+	unhealthy := 0
+	lines = append(lines, "\n--- FTDC File Health ---")
+	lines = append(lines, "+------------------------------------+----------+-------+--------+-------+-------+----------------------+----------------------+")
+	lines = append(lines, "| File                               | Size     | Chunks| Samples| Schema| Errors| From                 | To                   |")
+	lines = append(lines, "|------------------------------------+----------+-------+--------+-------+-------+----------------------+----------------------|")
+	for _, filename := range fnames {
+		basename := filepath.Base(filename)
+		r, err := gox.NewFileReader(filename)
+		if err != nil {
+			unhealthy++
+			details = append(details, fmt.Sprintf("%v: %v", basename, err))
+			continue
+		}
+		health := ftdc.Validate(r)
+		if len(health.Errors) > 0 || health.Chunks == 0 {
+			unhealthy++
+		}
+		if health.Chunks == 0 && len(health.Errors) == 0 {
+			details = append(details, fmt.Sprintf("%v: no metrics chunk found", basename))
+		}
+		for _, cerr := range health.Errors {
+			details = append(details, fmt.Sprintf("%v: %v", basename, cerr.Error()))
+		}
+		lines = append(lines, fmt.Sprintf("|%-36s|%10s|%7d|%8d|%7d|%7d|%-22s|%-22s|", basename,
+			gox.GetStorageSize(health.Size), health.Chunks, health.Samples, health.SchemaChanges, len(health.Errors),
+			formatHealthTime(health.From), formatHealthTime(health.To)))
+	}
+	lines = append(lines, "+------------------------------------+----------+-------+--------+-------+-------+----------------------+----------------------+")
+	lines = append(lines, fmt.Sprintf("%d of %d files healthy", len(fnames)-unhealthy, len(fnames)))
+	lines = append(lines, details...)
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+func formatHealthTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
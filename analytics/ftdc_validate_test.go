@@ -0,0 +1,48 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simagix/keyhole/ftdc"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestValidateDiagnosticData(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "diagnostic.data")
+	defer os.RemoveAll(dir)
+	var buffer bytes.Buffer
+	encoder := ftdc.NewEncoder(&buffer)
+	encoder.WriteMetadata(bson.D{{Key: "hostInfo", Value: bson.D{{Key: "system", Value: bson.D{{Key: "hostname", Value: "localhost"}}}}}})
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		doc := bson.D{{Key: "start", Value: start.Add(time.Duration(i) * time.Second)},
+			{Key: "serverStatus", Value: bson.D{{Key: "uptime", Value: int64(i)}}}}
+		if i >= 5 { // a new metric after a config change
+			doc = append(doc, bson.E{Key: "systemMetrics", Value: bson.D{{Key: "cpu", Value: bson.D{{Key: "user_ms", Value: int64(i)}}}}})
+		}
+		if err := encoder.Encode(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	encoder.Flush()
+	ioutil.WriteFile(dir+"/metrics.2020-01-01T00-00-00Z-00000", buffer.Bytes(), 0644)
+	ioutil.WriteFile(dir+"/metrics.2020-01-01T00-00-10Z-00000", buffer.Bytes()[:buffer.Len()-10], 0644)
+
+	str, err := NewDiagnosticData().ValidateDiagnosticData([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(str, "|      2|      10|      1|      0|2020-01-01T00:00:00Z  |2020-01-01T00:00:09Z  |") == false ||
+		strings.Contains(str, "1 of 2 files healthy") == false ||
+		strings.Contains(str, "metrics.2020-01-01T00-00-10Z-00000: chunk at offset") == false {
+		t.Fatal(str)
+	}
+	t.Log(str)
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/simagix/keyhole/ftdc"
+)
+
+// maxPrintedSeries is the max number of metric paths printed as time series columns
+const maxPrintedSeries = 8
+
+// SetMetricPaths sets glob patterns of metric paths to extract, e.g. serverStatus/wiredTiger/transaction/*
+func (d *DiagnosticData) SetMetricPaths(patterns []string) error {
+	var err error
+	if len(patterns) == 0 {
+		d.pathMatcher = nil
+		return err
+	}
+	d.pathMatcher, err = ftdc.NewPathMatcher(patterns)
+	return err
+}
+
+// addMetricSeries adds data points of matched metric paths of a chunk
+func (d *DiagnosticData) addMetricSeries(v ftdc.MetricsData) {
+	if d.pathMatcher == nil {
+		return
+	}
+	if d.MetricSeries == nil {
+		d.MetricSeries = map[string]TimeSeriesDoc{}
+	}
+	times := v.DataPointsMap["serverStatus/localTime"]
+	if len(times) == 0 {
+		times = v.DataPointsMap["start"]
+	}
+	for _, path := range d.pathMatcher.Select(v) {
+		values := v.DataPointsMap[path]
+		doc := d.MetricSeries[path]
+		doc.Target = path
+		for i := 0; i < len(values) && i < len(times); i += d.getStride() { // the first sample and all deltas
+			doc.DataPoints = append(doc.DataPoints, []float64{float64(int64(values[i])), float64(times[i])})
+		}
+		d.MetricSeries[path] = doc
+	}
+}
+
+// mergeMetricSeries appends data points of series after the last data point of each path
+func mergeMetricSeries(dst map[string]TimeSeriesDoc, src map[string]TimeSeriesDoc) {
+	for path, v := range src {
+		doc := dst[path]
+		doc.Target = path
+		if len(doc.DataPoints) == 0 {
+			doc.DataPoints = v.DataPoints
+		} else {
+			last := doc.DataPoints[len(doc.DataPoints)-1][1]
+			for i, point := range v.DataPoints {
+				if point[1] > last {
+					doc.DataPoints = append(doc.DataPoints, v.DataPoints[i:]...)
+					break
+				}
+			}
+		}
+		dst[path] = doc
+	}
+}
+
+// getMetricSeriesPaths returns sorted metric paths of series
+func getMetricSeriesPaths(series map[string]TimeSeriesDoc) []string {
+	paths := []string{}
+	for path := range series {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// PrintMetricSeries prints a summary of each metric path and sampled data points of the first few paths
+func PrintMetricSeries(series map[string]TimeSeriesDoc, span int) string {
+	var lines []string
+	paths := getMetricSeriesPaths(series)
+	if len(paths) == 0 {
+		return ""
+	}
+	lines = append(lines, "\n--- Metrics by Path ---")
+	lines = append(lines, "+----+--------+------------------+------------------+------------------+------------------+------------------+")
+	lines = append(lines, "| #  | points | first            | last             | min              | avg              | max              |")
+	lines = append(lines, "|----+--------+------------------+------------------+------------------+------------------+------------------|")
+	for i, path := range paths {
+		points := series[path].DataPoints
+		if len(points) == 0 {
+			continue
+		}
+		min, max, sum := math.MaxFloat64, -math.MaxFloat64, 0.0
+		for _, point := range points {
+			min = math.Min(min, point[0])
+			max = math.Max(max, point[0])
+			sum += point[0]
+		}
+		lines = append(lines, fmt.Sprintf("|%4d|%8d|%18.0f|%18.0f|%18.0f|%18.2f|%18.0f|", i+1, len(points),
+			points[0][0], points[len(points)-1][0], min, sum/float64(len(points)), max))
+	}
+	lines = append(lines, "+----+--------+------------------+------------------+------------------+------------------+------------------+")
+	for i, path := range paths {
+		lines = append(lines, fmt.Sprintf("#%d: %v", i+1, path))
+	}
+
+	// sampled data points, a column per path
+	if len(paths) > maxPrintedSeries {
+		lines = append(lines, fmt.Sprintf("* time series of the first %d paths are printed", maxPrintedSeries))
+		paths = paths[:maxPrintedSeries]
+	}
+	points := series[paths[0]].DataPoints
+	if len(points) == 0 {
+		return strings.Join(lines, "\n") + "\n"
+	}
+	if span < 0 {
+		span = int(points[len(points)-1][1]-points[0][1]) / 1000 / 20
+	}
+	border := "+-------------------------" + strings.Repeat("+------------------", len(paths)) + "+"
+	header := "| Date/Time               "
+	for i := range paths {
+		header += fmt.Sprintf("| %-17s", fmt.Sprintf("#%d", i+1))
+	}
+	lines = append(lines, border, header+"|", border)
+	indexes := make([]int, len(paths))
+	var last float64
+	for n, point := range points {
+		if n > 0 && n < len(points)-1 && (point[1]-last)/1000 < float64(span) {
+			continue
+		}
+		last = point[1]
+		line := fmt.Sprintf("|%-25s", time.Unix(0, int64(point[1])*int64(time.Millisecond)).In(loc).Format(time.RFC3339))
+		for i, path := range paths {
+			data := series[path].DataPoints
+			for indexes[i] < len(data)-1 && data[indexes[i]][1] < point[1] {
+				indexes[i]++
+			}
+			if len(data) > 0 && data[indexes[i]][1] == point[1] {
+				line += fmt.Sprintf("|%18.0f", data[indexes[i]][0])
+			} else {
+				line += fmt.Sprintf("|%18s", "-")
+			}
+		}
+		lines = append(lines, line+"|")
+	}
+	lines = append(lines, border)
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/simagix/keyhole/ftdc"
+)
+
+func getTestMetricsData(start uint64) ftdc.MetricsData {
+	return ftdc.MetricsData{NumDeltas: 3, DataPointsMap: map[string][]uint64{
+		"serverStatus/localTime": {start, start + 1000, start + 2000, start + 3000},
+		"serverStatus/wiredTiger/transaction/transaction checkpoints": {1, 1, 2, 2},
+		"serverStatus/metrics/repl/apply/ops":                         {10, 20, 30, 40},
+		"serverStatus/uptime":                                         {1, 2, 3, 4},
+	}}
+}
+
+func TestMetricSeries(t *testing.T) {
+	d := NewDiagnosticData()
+	if err := d.SetMetricPaths([]string{"serverStatus/wiredTiger/transaction/*", "serverStatus/metrics/repl/**"}); err != nil {
+		t.Fatal(err)
+	}
+	d.addMetricSeries(getTestMetricsData(1000000))
+	if len(d.MetricSeries) != 2 || len(d.MetricSeries["serverStatus/metrics/repl/apply/ops"].DataPoints) != 4 {
+		t.Fatal(d.MetricSeries)
+	}
+	series := map[string]TimeSeriesDoc{}
+	mergeMetricSeries(series, d.MetricSeries)
+	d.MetricSeries = nil
+	d.addMetricSeries(getTestMetricsData(1002000)) // overlaps 2 data points
+	mergeMetricSeries(series, d.MetricSeries)
+	points := series["serverStatus/metrics/repl/apply/ops"].DataPoints
+	if len(points) != 6 || points[5][0] != 40 || points[5][1] != 1005000 {
+		t.Fatal(points)
+	}
+	str := PrintMetricSeries(series, -1)
+	if strings.Contains(str, "#1: serverStatus/metrics/repl/apply/ops") == false ||
+		strings.Contains(str, "serverStatus/uptime") == true {
+		t.Fatal(str)
+	}
+	t.Log(str)
+}
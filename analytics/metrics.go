@@ -27,16 +27,24 @@ import (
 // Metrics stores metrics from FTDC data
 type Metrics struct {
 	sync.RWMutex
-	endpoints []string
-	ftdcStats FTDCStats
-	latest    int // latest n files
-	verbose   bool
+	comparison *Comparison
+	endpoints  []string
+	ftdcStats  FTDCStats             // of the first host
+	hostStats  map[string]*FTDCStats // by hostInfo.system.hostname
+	hosts      []string
+	latest     int // latest n files
+	paths      []string
+	rules      []AssessmentRule
+	verbose    bool
 }
 
 // FTDCStats FTDC stats
 type FTDCStats struct {
 	DiskStats         map[string]DiskStats
+	Events            []Event
+	Incidents         []Incident
 	MaxWTCache        float64
+	MetricSeries      map[string]TimeSeriesDoc
 	ReplicationLags   map[string]TimeSeriesDoc
 	ReplSetLegends    []string
 	ReplSetStatusList []ReplSetStatusDoc
@@ -84,6 +92,18 @@ func (m *Metrics) SetVerbose(verbose bool) { m.verbose = verbose }
 // SetLatest sets latest
 func (m *Metrics) SetLatest(latest int) { m.latest = latest }
 
+// SetAssessmentRules sets assessment rules
+func (m *Metrics) SetAssessmentRules(rules []AssessmentRule) { m.rules = rules }
+
+// SetComparison sets a comparison of a baseline window and a compared window
+func (m *Metrics) SetComparison(comparison *Comparison) { m.comparison = comparison }
+
+// SetMetricPaths sets glob patterns of metric paths to extract
+func (m *Metrics) SetMetricPaths(paths []string) error {
+	m.paths = paths
+	return NewDiagnosticData().SetMetricPaths(paths)
+}
+
 // ProcessFiles reads metrics files/data
 func (m *Metrics) ProcessFiles(filenames []string) error {
 	hostname, _ := os.Hostname()
@@ -96,25 +116,34 @@ func (m *Metrics) ProcessFiles(filenames []string) error {
 		// log.Println(fmt.Sprintf("http://localhost:%d%v", port, endpoint))
 		return errors.New("no valid data file found")
 	}
-	if m.latest > 0 && m.latest < len(filenames) {
-		filenames = filenames[len(filenames)-m.latest:]
-	}
 	if hostname == "ftdc" { // from docker-compose
 		port = 3030
-		if len(filenames) > 3 { // avoid OOM killer
+	}
+	endpoints := []string{}
+	for _, fnames := range getFilenamesByDirectory(filenames) { // a diagnostic.data directory per host
+		if m.latest > 0 && m.latest < len(fnames) {
+			fnames = fnames[len(fnames)-m.latest:]
+		}
+		if hostname == "ftdc" && len(fnames) > 3 { // avoid OOM killer
 			fmt.Println("* limits to latest 3 files in a Docker container")
-			filenames = filenames[len(filenames)-3:]
+			fnames = fnames[len(fnames)-3:]
+		}
+		diag := NewDiagnosticData()
+		if err := diag.SetMetricPaths(m.paths); err != nil {
+			return err
+		}
+		if err := diag.DecodeDiagnosticData(fnames); err != nil { // get summary
+			return err
+		}
+		endpoints = append(endpoints, diag.endpoints...)
+		m.AddFTDCDetailStats(diag)
+		for _, endpoint := range diag.endpoints {
+			log.Println(fmt.Sprintf("http://localhost:%d%v", port, endpoint))
 		}
 	}
-	diag := NewDiagnosticData()
-	if err := diag.DecodeDiagnosticData(filenames); err != nil { // get summary
-		return err
-	}
-	m.endpoints = diag.endpoints
-	m.AddFTDCDetailStats(diag)
-	for _, endpoint := range diag.endpoints {
-		log.Println(fmt.Sprintf("http://localhost:%d%v", port, endpoint))
-	}
+	m.Lock()
+	m.endpoints = endpoints
+	m.Unlock()
 	return nil
 }
 
@@ -155,10 +184,20 @@ func (m *Metrics) Handler(w http.ResponseWriter, r *http.Request) {
 		m.query(w, r)
 	} else if r.URL.Path == "/grafana/search" {
 		m.search(w, r)
+	} else if r.URL.Path == "/grafana/annotations" {
+		m.annotations(w, r)
+	} else if r.URL.Path == "/grafana/tag-keys" {
+		m.tagKeys(w, r)
+	} else if r.URL.Path == "/grafana/tag-values" {
+		m.tagValues(w, r)
 	} else if r.URL.Path == "/grafana/dir" {
 		m.readDirectory(w, r)
 	} else if strings.HasPrefix(r.URL.Path, "/scores/") {
-		fmt.Fprintf(w, GetFormulaHTML(r.URL.Path[9:]))
+		rules := m.rules
+		if len(rules) == 0 {
+			rules = DefaultAssessmentRules
+		}
+		fmt.Fprint(w, getFormulaHTML(rules))
 	} else {
 		json.NewEncoder(w).Encode(bson.M{"ok": 1, "message": "hello mongo-ftdc!"})
 	}
@@ -178,7 +217,10 @@ func (m *Metrics) readDirectory(w http.ResponseWriter, r *http.Request) {
 		if err := m.ProcessFiles(filenames); err != nil {
 			json.NewEncoder(w).Encode(bson.M{"ok": 0, "err": err.Error()})
 		} else {
-			json.NewEncoder(w).Encode(bson.M{"ok": 1, "endpoints": strings.Join(m.endpoints, ",")})
+			m.RLock()
+			endpoints := strings.Join(m.endpoints, ",")
+			m.RUnlock()
+			json.NewEncoder(w).Encode(bson.M{"ok": 1, "endpoints": endpoints})
 		}
 	default:
 		http.Error(w, "bad method; supported OPTIONS, POST", http.StatusBadRequest)
@@ -187,16 +229,60 @@ func (m *Metrics) readDirectory(w http.ResponseWriter, r *http.Request) {
 }
 
 func (m *Metrics) search(w http.ResponseWriter, r *http.Request) {
+	m.RLock()
+	defer m.RUnlock()
 	var list []string
 	for _, doc := range m.ftdcStats.TimeSeriesData {
 		list = append(list, doc.Target)
 	}
+	list = append(list, m.getHostTargets()...)
 
 	list = append(list, "host_info")
+	if m.comparison != nil {
+		list = append(list, "comparison")
+	}
 	json.NewEncoder(w).Encode(list)
 }
 
+// getHostTargets returns targets of each host, e.g. wt_cache_used@host:27017, and of all hosts,
+// e.g. wt_cache_used@*, if there are stats of more than one host
+func (m *Metrics) getHostTargets() []string {
+	var list []string
+	if len(m.hosts) < 2 {
+		return list
+	}
+	targets := map[string]bool{}
+	for _, host := range m.hosts {
+		for _, doc := range m.hostStats[host].TimeSeriesData {
+			list = append(list, doc.Target+"@"+host)
+			targets[doc.Target] = true
+		}
+	}
+	for target := range targets {
+		list = append(list, target+"@*")
+	}
+	sort.Strings(list)
+	return list
+}
+
+// getHostsOfTarget returns the target without the host and hosts of a per host target
+func (m *Metrics) getHostsOfTarget(target string) (string, []string) {
+	n := strings.LastIndex(target, "@")
+	if n < 0 || len(m.hostStats) == 0 {
+		return target, nil
+	}
+	host := target[n+1:]
+	if host == "*" {
+		return target[:n], m.hosts
+	} else if m.hostStats[host] != nil {
+		return target[:n], []string{host}
+	}
+	return target, nil
+}
+
 func (m *Metrics) query(w http.ResponseWriter, r *http.Request) {
+	m.RLock()
+	defer m.RUnlock()
 	var tsData []interface{}
 	decoder := json.NewDecoder(r.Body)
 	var qr QueryRequest
@@ -205,54 +291,22 @@ func (m *Metrics) query(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ftdc := m.ftdcStats
+	if stats := m.getAdhocHostStats(qr.AdhocFilters); stats != nil {
+		ftdc = *stats
+	}
 	for _, target := range qr.Targets {
 		if target.Type == "timeserie" {
-			if target.Target == "replication_lags" && len(ftdc.ReplicationLags) > 0 { // replaced with actual hostname
-				for k, v := range ftdc.ReplicationLags {
-					data := v
-					data.Target = k
-					tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
-				}
-			} else if target.Target == "disks_utils" && len(ftdc.DiskStats) > 0 {
-				for k, v := range ftdc.DiskStats {
-					data := v.Utilization
-					data.Target = k
-					tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
-				}
-			} else if target.Target == "disks_iops" && len(ftdc.DiskStats) > 0 {
-				for k, v := range ftdc.DiskStats {
-					data := v.IOPS
-					data.Target = k
-					tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
-				}
-			} else if target.Target == "disks_queue_length" && len(ftdc.DiskStats) > 0 {
-				for k, v := range ftdc.DiskStats {
-					data := v.IOInProgress
-					data.Target = k
-					tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
-				}
-			} else if target.Target == "read_time_ms" && len(ftdc.DiskStats) > 0 {
-				for k, v := range ftdc.DiskStats {
-					data := v.ReadTimeMS
-					data.Target = k
-					tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
-				}
-			} else if target.Target == "write_time_ms" && len(ftdc.DiskStats) > 0 {
-				for k, v := range ftdc.DiskStats {
-					data := v.WriteTimeMS
-					data.Target = k
-					tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
-				}
-			} else if target.Target == "io_queued_ms" && len(ftdc.DiskStats) > 0 {
-				for k, v := range ftdc.DiskStats {
-					data := v.IOQueuedMS
-					data.Target = k
-					tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
+			if tsTarget, hosts := m.getHostsOfTarget(target.Target); len(hosts) > 0 { // per host
+				for _, host := range hosts {
+					for _, data := range getTimeSeriesData(m.hostStats[host], tsTarget, qr) {
+						data.Target += "@" + host
+						tsData = append(tsData, data)
+					}
 				}
 			} else {
-				data := ftdc.TimeSeriesData[target.Target]
-				data.Target = GetShortLabel(target.Target)
-				tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
+				for _, data := range getTimeSeriesData(&ftdc, target.Target, qr) {
+					tsData = append(tsData, data)
+				}
 			}
 		} else if target.Type == "table" {
 			if target.Target == "host_info" {
@@ -274,19 +328,95 @@ func (m *Metrics) query(w http.ResponseWriter, r *http.Request) {
 				tsData = append(tsData, doc)
 			} else if target.Target == "assessment" {
 				as := NewAssessment(ftdc)
+				as.SetRules(m.rules)
 				as.SetVerbose(m.verbose)
 				tsData = append(tsData, as.GetAssessment(qr.Range.From, qr.Range.To))
+			} else if target.Target == "comparison" && m.comparison != nil {
+				tsData = append(tsData, m.comparison.GetComparison(&ftdc))
 			}
 		}
 	}
 	json.NewEncoder(w).Encode(tsData)
 }
 
-// AddFTDCDetailStats assign FTDC values
+// getTimeSeriesData returns data points of a target, or a series of each host of disks and replication lags
+func getTimeSeriesData(ftdc *FTDCStats, target string, qr QueryRequest) []TimeSeriesDoc {
+	var tsData []TimeSeriesDoc
+	if target == "replication_lags" && len(ftdc.ReplicationLags) > 0 { // replaced with actual hostname
+		for k, v := range ftdc.ReplicationLags {
+			data := v
+			data.Target = k
+			tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
+		}
+	} else if target == "disks_utils" && len(ftdc.DiskStats) > 0 {
+		for k, v := range ftdc.DiskStats {
+			data := v.Utilization
+			data.Target = k
+			tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
+		}
+	} else if target == "disks_iops" && len(ftdc.DiskStats) > 0 {
+		for k, v := range ftdc.DiskStats {
+			data := v.IOPS
+			data.Target = k
+			tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
+		}
+	} else if target == "disks_queue_length" && len(ftdc.DiskStats) > 0 {
+		for k, v := range ftdc.DiskStats {
+			data := v.IOInProgress
+			data.Target = k
+			tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
+		}
+	} else if target == "read_time_ms" && len(ftdc.DiskStats) > 0 {
+		for k, v := range ftdc.DiskStats {
+			data := v.ReadTimeMS
+			data.Target = k
+			tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
+		}
+	} else if target == "write_time_ms" && len(ftdc.DiskStats) > 0 {
+		for k, v := range ftdc.DiskStats {
+			data := v.WriteTimeMS
+			data.Target = k
+			tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
+		}
+	} else if target == "io_queued_ms" && len(ftdc.DiskStats) > 0 {
+		for k, v := range ftdc.DiskStats {
+			data := v.IOQueuedMS
+			data.Target = k
+			tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
+		}
+	} else {
+		data := ftdc.TimeSeriesData[target]
+		data.Target = GetShortLabel(target)
+		tsData = append(tsData, FilterTimeSeriesData(data, qr.Range.From, qr.Range.To))
+	}
+	return tsData
+}
+
+// AddFTDCDetailStats assign FTDC values, stats of each host are kept separately
 func (m *Metrics) AddFTDCDetailStats(diag *DiagnosticData) {
-	m.RLock()
-	defer m.RUnlock()
-	ftdc := &m.ftdcStats
+	m.Lock()
+	defer m.Unlock()
+	var info ServerInfoDoc
+	b, _ := json.Marshal(diag.ServerInfo)
+	json.Unmarshal(b, &info)
+	hostname := info.HostInfo.System.Hostname
+	if m.hostStats == nil {
+		m.hostStats = map[string]*FTDCStats{}
+	}
+	ftdc := m.hostStats[hostname]
+	if ftdc == nil {
+		ftdc = &FTDCStats{}
+		if len(m.hosts) == 0 {
+			ftdc = &m.ftdcStats
+		}
+		m.hostStats[hostname] = ftdc
+		m.hosts = append(m.hosts, hostname)
+	}
+	addFTDCDetailStats(ftdc, diag, m.verbose)
+}
+
+// addFTDCDetailStats appends FTDC values after the latest ones and computes time series data
+func addFTDCDetailStats(ftdc *FTDCStats, diag *DiagnosticData, verbose bool) {
 
 	sort.Slice(diag.ReplSetStatusList, func(i int, j int) bool {
 		return diag.ReplSetStatusList[i].Date.Before(diag.ReplSetStatusList[j].Date)
@@ -333,6 +463,14 @@ func (m *Metrics) AddFTDCDetailStats(diag *DiagnosticData) {
 		}
 	}
 
+	if len(diag.MetricSeries) > 0 {
+		if ftdc.MetricSeries == nil {
+			ftdc.MetricSeries = map[string]TimeSeriesDoc{}
+		}
+		mergeMetricSeries(ftdc.MetricSeries, diag.MetricSeries)
+	}
+	thinFTDCStats(ftdc) // of the same host from more than one directory
+
 	b, _ := json.Marshal(diag.ServerInfo)
 	btm := time.Now()
 	var wiredTigerTSD map[string]TimeSeriesDoc
@@ -372,16 +510,29 @@ func (m *Metrics) AddFTDCDetailStats(diag *DiagnosticData) {
 	for k, v := range systemMetricsTSD {
 		ftdc.TimeSeriesData[k] = v
 	}
+	for k, v := range ftdc.MetricSeries { // targets by metric path
+		ftdc.TimeSeriesData[k] = v
+	}
+	ftdc.Incidents = GetIncidents(ftdc.TimeSeriesData)
+	ftdc.Events = GetEvents(ftdc.ServerStatusList, ftdc.ReplSetStatusList)
 	json.Unmarshal(b, &ftdc.ServerInfo)
-	if len(m.ftdcStats.TimeSeriesData["wt_cache_max"].DataPoints) > 0 && len(m.ftdcStats.TimeSeriesData["wt_cache_max"].DataPoints[0]) > 0 {
-		m.ftdcStats.MaxWTCache = m.ftdcStats.TimeSeriesData["wt_cache_max"].DataPoints[0][0]
+	if len(ftdc.TimeSeriesData["wt_cache_max"].DataPoints) > 0 && len(ftdc.TimeSeriesData["wt_cache_max"].DataPoints[0]) > 0 {
+		ftdc.MaxWTCache = ftdc.TimeSeriesData["wt_cache_max"].DataPoints[0][0]
 	}
 	etm := time.Now()
-	if m.verbose == true {
-		log.Println("data points added for", m.ftdcStats.ServerInfo.HostInfo.System.Hostname, ", time spent:", etm.Sub(btm).String())
+	if verbose == true {
+		log.Println("data points added for", ftdc.ServerInfo.HostInfo.System.Hostname, ", time spent:", etm.Sub(btm).String())
 	}
 }
 
+// thinFTDCStats drops every other sample until at most maxDiagnosticSamples are kept
+func thinFTDCStats(ftdc *FTDCStats) {
+	d := DiagnosticData{ServerStatusList: ftdc.ServerStatusList, SystemMetricsList: ftdc.SystemMetricsList,
+		ReplSetStatusList: ftdc.ReplSetStatusList, MetricSeries: ftdc.MetricSeries}
+	d.thinSamples()
+	ftdc.ServerStatusList, ftdc.SystemMetricsList, ftdc.ReplSetStatusList = d.ServerStatusList, d.SystemMetricsList, d.ReplSetStatusList
+}
+
 // FilterTimeSeriesData returns partial data points if there are too many
 func FilterTimeSeriesData(tsData TimeSeriesDoc, from time.Time, to time.Time) TimeSeriesDoc {
 	seconds := 1800.0 // .5 hour, no gain to have a higher number.  Grafana aggregates
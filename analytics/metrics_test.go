@@ -0,0 +1,96 @@
+// Copyright 2019 Kuei-chun Chen. All rights reserved.
+
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getTestHostDiagnosticData(hostname string, resident int) *DiagnosticData {
+	d := NewDiagnosticData()
+	d.ServerInfo = bson.M{"hostInfo": bson.M{"system": bson.M{"hostname": hostname}}}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		var ss ServerStatusDoc
+		ss.LocalTime = now.Add(time.Duration(i) * time.Second)
+		ss.Mem.Resident = uint64(resident)
+		ss.Uptime = uint64(100 + i)
+		d.ServerStatusList = append(d.ServerStatusList, ss)
+		d.SystemMetricsList = append(d.SystemMetricsList, SystemMetricsDoc{Start: ss.LocalTime})
+	}
+	return d
+}
+
+func TestAddFTDCDetailStatsByHost(t *testing.T) {
+	m := &Metrics{}
+	m.AddFTDCDetailStats(getTestHostDiagnosticData("primary:27017", 1024))
+	if targets := m.getHostTargets(); len(targets) != 0 {
+		t.Fatal(targets)
+	}
+	m.AddFTDCDetailStats(getTestHostDiagnosticData("secondary:27017", 2048))
+	if len(m.hosts) != 2 || m.ftdcStats.ServerInfo.HostInfo.System.Hostname != "primary:27017" {
+		t.Fatal(m.hosts, m.ftdcStats.ServerInfo.HostInfo.System.Hostname)
+	}
+	targets := strings.Join(m.getHostTargets(), ",")
+	if strings.Contains(targets, "mem_resident@primary:27017") == false ||
+		strings.Contains(targets, "mem_resident@secondary:27017") == false ||
+		strings.Contains(targets, "mem_resident@*") == false {
+		t.Fatal(targets)
+	}
+	if target, hosts := m.getHostsOfTarget("mem_resident@secondary:27017"); target != "mem_resident" || len(hosts) != 1 {
+		t.Fatal(target, hosts)
+	}
+	if target, hosts := m.getHostsOfTarget("mem_resident"); target != "mem_resident" || len(hosts) != 0 {
+		t.Fatal(target, hosts)
+	}
+
+	qr := QueryRequest{Range: RangeDoc{From: time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC),
+		To: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		Targets: []TargetDoc{{Target: "mem_resident@*", Type: "timeserie"}, {Target: "mem_resident", Type: "timeserie"}}}
+	b, _ := json.Marshal(qr)
+	w := httptest.NewRecorder()
+	m.Handler(w, httptest.NewRequest(http.MethodPost, "/grafana/query", bytes.NewReader(b)))
+	var docs []TimeSeriesDoc
+	if err := json.Unmarshal(w.Body.Bytes(), &docs); err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 3 || docs[0].Target != "resident@primary:27017" || docs[1].Target != "resident@secondary:27017" ||
+		docs[2].Target != "resident" {
+		t.Fatal(w.Body.String())
+	}
+	if len(docs[1].DataPoints) == 0 || docs[1].DataPoints[0][0] == docs[0].DataPoints[0][0] {
+		t.Fatal(docs)
+	}
+}
+
+func TestAddFTDCDetailStatsConcurrently(t *testing.T) {
+	m := &Metrics{}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			m.AddFTDCDetailStats(getTestHostDiagnosticData(fmt.Sprintf("host%d:27017", i), 1024))
+		}(i)
+		go func() {
+			defer wg.Done()
+			b, _ := json.Marshal(TagRequest{Key: "host"})
+			m.Handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/grafana/tag-values", bytes.NewReader(b)))
+			m.Handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/grafana/search", nil))
+		}()
+	}
+	wg.Wait()
+	if len(m.hosts) != 4 || len(m.hostStats) != 4 {
+		t.Fatal(m.hosts)
+	}
+}
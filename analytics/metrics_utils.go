@@ -6,6 +6,8 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 )
@@ -34,6 +36,25 @@ func getFilenames(filenames []string) []string {
 	return fnames
 }
 
+// getFilenamesByDirectory returns filenames grouped by directories, in the order of directories
+func getFilenamesByDirectory(filenames []string) [][]string {
+	groups := map[string][]string{}
+	dirs := []string{}
+	for _, filename := range filenames {
+		dir := filepath.Dir(filename)
+		if groups[dir] == nil {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], filename)
+	}
+	sort.Strings(dirs)
+	list := [][]string{}
+	for _, dir := range dirs {
+		list = append(list, groups[dir])
+	}
+	return list
+}
+
 func parseTime(filename string) (time.Time, error) {
 	layout := "2006-01-02T15-04-05Z"
 	x := strings.Index(filename, "metrics.")
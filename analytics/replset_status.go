@@ -23,4 +23,5 @@ type MemberDoc struct {
 type ReplSetStatusDoc struct {
 	Date    time.Time   `json:"date" bson:"date"`
 	Members []MemberDoc `json:"members" bson:"members"`
+	Term    int64       `json:"term" bson:"term"`
 }
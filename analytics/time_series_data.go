@@ -33,11 +33,19 @@ type TargetDoc struct {
 	Type   string `json:"type"`
 }
 
+// AdhocFilter -
+type AdhocFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
 // QueryRequest -
 type QueryRequest struct {
-	Timezone string      `json:"timezone"`
-	Range    RangeDoc    `json:"range"`
-	Targets  []TargetDoc `json:"targets"`
+	AdhocFilters []AdhocFilter `json:"adhocFilters"`
+	Timezone     string        `json:"timezone"`
+	Range        RangeDoc      `json:"range"`
+	Targets      []TargetDoc   `json:"targets"`
 }
 
 var serverStatusChartsLegends = []string{
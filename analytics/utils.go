@@ -4,6 +4,7 @@ package analytics
 
 import (
 	"fmt"
+	"html/template"
 	"io/ioutil"
 	"math"
 	"os"
@@ -100,8 +101,13 @@ func GetShortLabel(label string) string {
 	return label
 }
 
-// GetFormulaHTML returns scoring formula
+// GetFormulaHTML returns scoring formula of default rules
 func GetFormulaHTML(metric string) string {
+	return getFormulaHTML(DefaultAssessmentRules)
+}
+
+// getFormulaHTML returns scoring formula of rules
+func getFormulaHTML(rules []AssessmentRule) string {
 	html := `
 	<!DOCTYPE html>
 	<html lang="en">
@@ -185,18 +191,13 @@ func GetFormulaHTML(metric string) string {
 	<tr><th>Metric</th><th>Formula</th><th>Low Watermark</th><th>High Watermark</th></tr>
 	`
 	p := message.NewPrinter(language.English)
-	keys := []string{}
-	for k := range FormulaMap {
-		keys = append(keys, k)
-	}
-	sort.Slice(keys, func(i int, j int) bool {
-		return keys[i] < keys[j]
+	rules = append([]AssessmentRule{}, rules...)
+	sort.Slice(rules, func(i int, j int) bool {
+		return rules[i].Metric < rules[j].Metric
 	})
-	for i := range keys {
-		k := keys[i]
-		value := FormulaMap[k]
+	for _, rule := range rules {
 		html += fmt.Sprintf(`<tr><td class='rowtitle'>%v</td><td>%v</td><td align='right'>%v</td><td align='right'>%v</td></tr>`,
-			value.label, value.formula, p.Sprintf(`%v`, value.low), p.Sprintf(`%v`, value.high))
+			template.HTMLEscapeString(rule.Label), template.HTMLEscapeString(rule.Formula()), p.Sprintf(`%v`, rule.Low), p.Sprintf(`%v`, rule.High))
 	}
 	html += `</table></body></html>`
 	return html
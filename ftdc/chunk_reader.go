@@ -0,0 +1,132 @@
+// Copyright 2018 Kuei-chun Chen. All rights reserved.
+
+package ftdc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxDocumentSize is the max size of a BSON document of FTDC files
+const maxDocumentSize = 16 * 1024 * 1024
+
+// ChunkReader reads FTDC data one decoded chunk at a time
+type ChunkReader struct {
+	Doc interface{} // metadata, type 0
+
+	done     bool // after an unrecoverable error
+	metadata int  // number of metadata documents read
+	metrics  *Metrics
+	offset   int64
+	reader   io.Reader
+}
+
+// ChunkError is an error of a skipped or a corrupted chunk at the offset of its document. Reading
+// continues with the next chunk, or ends with io.EOF if the rest of the data is unreadable.
+type ChunkError struct {
+	Err    error
+	Offset int64
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk at offset %d: %v", e.Offset, e.Err)
+}
+
+// NewChunkReader returns ChunkReader
+func NewChunkReader(reader io.Reader) *ChunkReader {
+	return &ChunkReader{metrics: NewMetrics(), reader: reader}
+}
+
+// Offset returns the offset of the next document to read
+func (c *ChunkReader) Offset() int64 {
+	return c.offset
+}
+
+// Next returns the next decoded metrics chunk, io.EOF if no more, or a *ChunkError of a chunk
+// skipped, and the next call continues with the following chunk
+func (c *ChunkReader) Next() (MetricsData, error) {
+	for {
+		if c.done {
+			return MetricsData{}, io.EOF
+		}
+		offset := c.offset
+		out, err := c.readDocument()
+		if err == io.EOF {
+			c.done = true
+			return MetricsData{}, err
+		} else if err != nil {
+			return MetricsData{}, &ChunkError{Err: err, Offset: offset}
+		}
+		if out["type"] == int32(0) {
+			c.Doc = out["doc"]
+			c.metadata++
+		} else if out["type"] == int32(1) {
+			md, err := c.decodeChunk(out)
+			if err != nil {
+				return md, &ChunkError{Err: err, Offset: offset}
+			}
+			return md, err
+		}
+	}
+}
+
+// decodeChunk decompresses and decodes a metrics chunk, type 1
+func (c *ChunkReader) decodeChunk(out bson.M) (MetricsData, error) {
+	var err error
+	bin, ok := out["data"].(primitive.Binary)
+	if !ok || len(bin.Data) < 4 {
+		return MetricsData{}, errors.New("invalid metrics chunk")
+	}
+	var r io.ReadCloser
+	if r, err = zlib.NewReader(bytes.NewReader(bin.Data[4:])); err != nil {
+		return MetricsData{}, err
+	}
+	var block []byte
+	block, err = ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return MetricsData{}, err
+	}
+	return c.metrics.decode(block)
+}
+
+// readDocument reads a BSON document, the rest of the data is unreadable after an invalid size
+func (c *ChunkReader) readDocument() (bson.M, error) {
+	var err error
+	header := make([]byte, 4)
+	if _, err = io.ReadFull(c.reader, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			c.done = true
+			return nil, errors.New("truncated FTDC document")
+		}
+		if err != io.EOF {
+			c.done = true
+		}
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint32(header)
+	if length < 5 || length > maxDocumentSize {
+		c.done = true
+		return nil, errors.New("invalid FTDC document size")
+	}
+	data := make([]byte, length)
+	copy(data, header)
+	if _, err = io.ReadFull(c.reader, data[4:]); err != nil {
+		c.done = true
+		return nil, errors.New("truncated FTDC document")
+	}
+	c.offset += int64(length)
+	var out = bson.M{}
+	if err = bson.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
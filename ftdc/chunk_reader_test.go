@@ -0,0 +1,196 @@
+// Copyright 2018 Kuei-chun Chen. All rights reserved.
+
+package ftdc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// getTestChunk returns a type 1 document of a reference document and deltas of all metrics
+func getTestChunk(t *testing.T, doc bson.D, deltas [][]uint64) []byte {
+	ref, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block bytes.Buffer
+	block.Write(ref)
+	binary.Write(&block, binary.LittleEndian, uint32(len(deltas)))
+	binary.Write(&block, binary.LittleEndian, uint32(len(deltas[0])))
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, list := range deltas {
+		for _, delta := range list {
+			if delta == 0 { // no zero runs
+				block.Write(buf[:binary.PutUvarint(buf, 0)])
+			}
+			block.Write(buf[:binary.PutUvarint(buf, delta)])
+		}
+	}
+	var compressed bytes.Buffer
+	binary.Write(&compressed, binary.LittleEndian, uint32(block.Len()))
+	w := zlib.NewWriter(&compressed)
+	w.Write(block.Bytes())
+	w.Close()
+	data, _ := bson.Marshal(bson.D{{Key: "_id", Value: primitive.DateTime(0)}, {Key: "type", Value: int32(1)},
+		{Key: "data", Value: primitive.Binary{Data: compressed.Bytes()}}})
+	return data
+}
+
+func getTestFTDCData(t *testing.T) []byte {
+	var buffer bytes.Buffer
+	metadata, _ := bson.Marshal(bson.D{{Key: "_id", Value: primitive.DateTime(0)}, {Key: "type", Value: int32(0)},
+		{Key: "doc", Value: bson.D{{Key: "hostInfo", Value: bson.D{{Key: "system", Value: bson.D{{Key: "hostname", Value: "localhost"}}}}}}}})
+	buffer.Write(metadata)
+	for i := 0; i < 3; i++ {
+		doc := bson.D{{Key: "serverStatus", Value: bson.D{{Key: "uptime", Value: int64(100 * i)},
+			{Key: "connections", Value: bson.D{{Key: "current", Value: int32(10)}}}}}}
+		buffer.Write(getTestChunk(t, doc, [][]uint64{{1, 1, 1}, {0, 2, 0}}))
+	}
+	return buffer.Bytes()
+}
+
+func TestChunkReader(t *testing.T) {
+	data := getTestFTDCData(t)
+	reader := NewChunkReader(bytes.NewReader(data))
+	chunks := 0
+	for {
+		md, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		uptime := md.DataPointsMap["serverStatus/uptime"]
+		current := md.DataPointsMap["serverStatus/connections/current"]
+		if md.NumDeltas != 3 || len(uptime) != 4 || uptime[3] != uint64(100*chunks+3) || current[2] != 12 {
+			t.Fatal(md.DataPointsMap)
+		}
+		chunks++
+	}
+	if chunks != 3 || reader.Offset() != int64(len(data)) || reader.Doc == nil {
+		t.Fatal(chunks, reader.Offset(), reader.Doc)
+	}
+
+	m := NewMetrics()
+	if err := m.ReadAllMetrics(&data); err != nil || len(m.Data) != 3 || m.Doc == nil {
+		t.Fatal(err, len(m.Data))
+	}
+	m = NewMetrics()
+	if err := m.ReadAllMetricsFrom(bytes.NewReader(data)); err != nil || len(m.Data) != 3 || m.Doc == nil {
+		t.Fatal(err, len(m.Data))
+	}
+
+	reader = NewChunkReader(bytes.NewReader(data[:len(data)-10])) // truncated
+	for chunks = 0; ; chunks++ {
+		if _, err := reader.Next(); err == io.EOF {
+			t.Fatal("expected truncated error")
+		} else if err != nil {
+			break
+		}
+	}
+	if chunks != 2 {
+		t.Fatal(chunks)
+	}
+}
+
+func TestPathMatcher(t *testing.T) {
+	matcher, err := NewPathMatcher([]string{"serverStatus/wiredTiger/transaction/*", "serverStatus/metrics/repl/**", " "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matcher.Patterns()) != 2 {
+		t.Fatal(matcher.Patterns())
+	}
+	for path, matched := range map[string]bool{
+		"serverStatus/wiredTiger/transaction/transaction checkpoints": true,
+		"serverStatus/wiredTiger/transaction/a/b":                     false,
+		"serverStatus/metrics/repl/apply/ops":                         true,
+		"serverStatus/metrics/replx":                                  false,
+		"serverStatus/tcmalloc/generic/heap_size":                     false,
+	} {
+		if matcher.Match(path) != matched {
+			t.Fatal(path, matched)
+		}
+	}
+	data := getTestFTDCData(t)
+	md, err := NewChunkReader(bytes.NewReader(data)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	matcher, _ = NewPathMatcher([]string{"serverStatus/**"})
+	if paths := matcher.Select(md); len(paths) != 2 || paths[0] != "serverStatus/connections/current" {
+		t.Fatal(paths)
+	}
+}
+
+func TestChunkReaderSkipsChunks(t *testing.T) {
+	var buffer bytes.Buffer
+	doc := bson.D{{Key: "start", Value: primitive.DateTime(1577836800000)},
+		{Key: "serverStatus", Value: bson.D{{Key: "uptime", Value: int64(100)}}}}
+	buffer.Write(getTestChunk(t, doc, [][]uint64{{1000, 1000}, {1, 1}}))
+	offset := int64(buffer.Len())
+	corrupted, _ := bson.Marshal(bson.D{{Key: "_id", Value: primitive.DateTime(0)}, {Key: "type", Value: int32(1)},
+		{Key: "data", Value: primitive.Binary{Data: []byte{1, 0, 0, 0, 'x', 'y', 'z'}}}})
+	buffer.Write(corrupted)
+	inconsistent := getTestChunk(t, doc, [][]uint64{{1000}, {1}, {1}}) // 3 metrics of 2
+	buffer.Write(inconsistent)
+	size, _ := primitive.ParseDecimal128("4096")
+	doc = bson.D{{Key: "start", Value: primitive.DateTime(1577836803000)}, // a new metric
+		{Key: "serverStatus", Value: bson.D{{Key: "uptime", Value: int64(103)},
+			{Key: "tcmalloc", Value: bson.D{{Key: "generic", Value: bson.D{{Key: "heap_size", Value: float64(1024)}}},
+				{Key: "tcmalloc", Value: bson.D{{Key: "release_rate", Value: 1.5}}}}},
+			{Key: "transportLayerStats", Value: bson.D{{Key: "numOpenSessions", Value: int32(3)}}}}},
+		{Key: "local.oplog.rs.stats", Value: bson.D{{Key: "ns", Value: "local.oplog.rs"},
+			{Key: "size", Value: size},
+			{Key: "latestOptime", Value: primitive.Timestamp{T: 1577836803, I: 1}}}}}
+	buffer.Write(getTestChunk(t, doc, [][]uint64{{1000}, {1}, {0}, {0}, {1}, {0}, {0}, {0}}))
+	data := buffer.Bytes()
+
+	reader := NewChunkReader(bytes.NewReader(data))
+	chunks := 0
+	errs := []*ChunkError{}
+	for {
+		md, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			cerr, ok := err.(*ChunkError)
+			if ok == false {
+				t.Fatal(err)
+			}
+			errs = append(errs, cerr)
+			continue
+		}
+		if chunks == 1 {
+			heapSize := md.DataPointsMap["serverStatus/tcmalloc/generic/heap_size"]
+			optime := md.DataPointsMap["local.oplog.rs.stats/latestOptime/t"]
+			if len(md.DataPointsMap) != 8 || len(heapSize) != 2 || md.DataPointsMap["local.oplog.rs.stats/size"][0] != 4096 ||
+				len(optime) != 2 || optime[1] != 1577836803 {
+				t.Fatal(md.DataPointsMap)
+			}
+		}
+		chunks++
+	}
+	if chunks != 2 || len(errs) != 2 || errs[0].Offset != offset || errs[1].Offset != offset+int64(len(corrupted)) ||
+		strings.Contains(errs[1].Error(), "inconsistent FTDC data") == false {
+		t.Fatal(chunks, errs)
+	}
+
+	health := Validate(bytes.NewReader(data))
+	if health.Chunks != 2 || health.Samples != 5 || health.SchemaChanges != 1 || len(health.Errors) != 2 ||
+		health.Size != int64(len(data)) || health.To.Equal(time.Unix(1577836804, 0)) == false {
+		t.Fatal(health)
+	}
+	health = Validate(bytes.NewReader(data[:len(data)-10])) // truncated
+	if health.Chunks != 1 || len(health.Errors) != 3 || health.Errors[2].Err.Error() != "truncated FTDC document" {
+		t.Fatal(health)
+	}
+}
@@ -5,6 +5,7 @@ package ftdc
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"strconv"
 
@@ -22,6 +23,9 @@ func (m *Metrics) decode(buffer []byte) (MetricsData, error) {
 
 	r := bytes.NewReader(buffer)
 	docSize := GetUint32(r) // first bson document length
+	if int64(docSize)+8 > int64(len(buffer)) {
+		return dp, errors.New("invalid reference document size")
+	}
 	r.Seek(int64(docSize), io.SeekStart)
 	numAttribs := GetUint32(r)  // 4 bytes # of keys
 	dp.NumDeltas = GetUint32(r) // 4 bytes # of deltas
@@ -37,11 +41,14 @@ func (m *Metrics) decode(buffer []byte) (MetricsData, error) {
 	// replSetGetStatus
 	// local.oplog.rs.stats
 	var docElem = bson.D{}
-	bson.Unmarshal(buffer[:docSize], &docElem) // first document
+	if err = bson.Unmarshal(buffer[:docSize], &docElem); err != nil { // first document
+		return dp, err
+	}
 	traverseDocElem(&attribsList, &dp.DataPointsMap, docElem, "")
 
 	if len(dp.DataPointsMap) != int(numAttribs) || len(attribsList) != int(numAttribs) {
-		return dp, errors.New("inconsistent FTDC data")
+		return dp, fmt.Errorf("inconsistent FTDC data, %d metrics in reference document, expected %d",
+			len(attribsList), numAttribs)
 	}
 
 	// deltas
@@ -97,15 +104,19 @@ func traverseDocElem(attribsList *[]string, attribsMap *map[string][]uint64, doc
 		}
 	case primitive.Timestamp:
 		tKey := parentPath + "/t"
-		(*attribsMap)[tKey] = []uint64{uint64(0)}
+		(*attribsMap)[tKey] = []uint64{uint64(value.T)}
 		(*attribsList) = append((*attribsList), tKey)
 		iKey := parentPath + "/i"
-		(*attribsMap)[iKey] = []uint64{uint64(0)}
+		(*attribsMap)[iKey] = []uint64{uint64(value.I)}
 		(*attribsList) = append((*attribsList), iKey)
 	case primitive.ObjectID: // ignore it
 	case string: // ignore it
-	case float64:
-		(*attribsMap)[parentPath] = []uint64{uint64(value)}
+	case float64: // same as mongod, truncated to int64
+		(*attribsMap)[parentPath] = []uint64{uint64(int64(value))}
+		(*attribsList) = append((*attribsList), parentPath)
+	case primitive.Decimal128:
+		f, _ := strconv.ParseFloat(value.String(), 64)
+		(*attribsMap)[parentPath] = []uint64{uint64(int64(f))}
 		(*attribsList) = append((*attribsList), parentPath)
 	case int:
 		(*attribsMap)[parentPath] = []uint64{uint64(value)}
@@ -0,0 +1,165 @@
+// Copyright 2018 Kuei-chun Chen. All rights reserved.
+
+package ftdc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultMaxSamples is the number of samples per metrics chunk, same as mongod
+const DefaultMaxSamples = 300
+
+// Encoder writes FTDC data, a metadata document (type 0) and metrics chunks (type 1).
+// A chunk is a reference document followed by deltas of all metrics, varint encoded
+// with runs of zeros compressed, and a new chunk begins when the schema changes.
+type Encoder struct {
+	keys       []string
+	maxSamples int
+	ref        []byte
+	refTime    primitive.DateTime
+	samples    [][]uint64 // values of keys of each sample
+	writer     io.Writer
+}
+
+// NewEncoder returns Encoder
+func NewEncoder(writer io.Writer) *Encoder {
+	return &Encoder{maxSamples: DefaultMaxSamples, writer: writer}
+}
+
+// SetMaxSamples sets max number of samples of a chunk
+func (e *Encoder) SetMaxSamples(maxSamples int) {
+	if maxSamples > 0 {
+		e.maxSamples = maxSamples
+	}
+}
+
+// WriteMetadata writes a metadata document, e.g. {hostInfo: {...}, buildInfo: {...}}
+func (e *Encoder) WriteMetadata(doc interface{}) error {
+	data, err := bson.Marshal(bson.D{{Key: "_id", Value: primitive.NewDateTimeFromTime(time.Now())},
+		{Key: "type", Value: int32(0)}, {Key: "doc", Value: doc}})
+	if err != nil {
+		return err
+	}
+	_, err = e.writer.Write(data)
+	return err
+}
+
+// Encode adds a sample, e.g. {start: ..., serverStatus: {...}, end: ...}, and writes a chunk
+// when it is full or the schema of the sample is different from samples of the chunk
+func (e *Encoder) Encode(doc interface{}) error {
+	var err error
+	var data []byte
+	var docElem bson.D
+	if data, err = bson.Marshal(doc); err != nil {
+		return err
+	}
+	if err = bson.Unmarshal(data, &docElem); err != nil {
+		return err
+	}
+	keys := []string{}
+	valuesMap := map[string][]uint64{}
+	traverseDocElem(&keys, &valuesMap, docElem, "")
+	if len(e.samples) > 0 && isSameKeys(keys, e.keys) == false {
+		if err = e.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(e.samples) == 0 {
+		e.keys = keys
+		e.ref = data
+		e.refTime = primitive.NewDateTimeFromTime(time.Now())
+		if len(docElem) > 0 {
+			if dt, ok := docElem[0].Value.(primitive.DateTime); ok == true { // start
+				e.refTime = dt
+			}
+		}
+	}
+	values := make([]uint64, len(keys))
+	for i, key := range keys {
+		values[i] = valuesMap[key][0]
+	}
+	e.samples = append(e.samples, values)
+	if len(e.samples) >= e.maxSamples {
+		return e.Flush()
+	}
+	return err
+}
+
+// Flush writes samples not yet written as a chunk
+func (e *Encoder) Flush() error {
+	if len(e.samples) == 0 {
+		return nil
+	}
+	data, err := e.getChunk()
+	e.samples = nil
+	if err != nil {
+		return err
+	}
+	_, err = e.writer.Write(data)
+	return err
+}
+
+// getChunk returns a type 1 document of samples
+func (e *Encoder) getChunk() ([]byte, error) {
+	var err error
+	var block bytes.Buffer
+	block.Write(e.ref)
+	binary.Write(&block, binary.LittleEndian, uint32(len(e.keys)))
+	binary.Write(&block, binary.LittleEndian, uint32(len(e.samples)-1))
+	buf := make([]byte, binary.MaxVarintLen64)
+	zeros := uint64(0) // a run of zeros spans metrics, written as 0 and the number of more zeros
+	for k := range e.keys {
+		for i := 1; i < len(e.samples); i++ {
+			delta := e.samples[i][k] - e.samples[i-1][k]
+			if delta == 0 {
+				zeros++
+				continue
+			}
+			if zeros > 0 {
+				block.Write(buf[:binary.PutUvarint(buf, 0)])
+				block.Write(buf[:binary.PutUvarint(buf, zeros-1)])
+				zeros = 0
+			}
+			block.Write(buf[:binary.PutUvarint(buf, delta)])
+		}
+	}
+	if zeros > 0 {
+		block.Write(buf[:binary.PutUvarint(buf, 0)])
+		block.Write(buf[:binary.PutUvarint(buf, zeros-1)])
+	}
+
+	var compressed bytes.Buffer
+	binary.Write(&compressed, binary.LittleEndian, uint32(block.Len()))
+	w := zlib.NewWriter(&compressed)
+	if _, err = w.Write(block.Bytes()); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	if compressed.Len() > maxDocumentSize {
+		return nil, errors.New("FTDC chunk too large")
+	}
+	return bson.Marshal(bson.D{{Key: "_id", Value: e.refTime}, {Key: "type", Value: int32(1)},
+		{Key: "data", Value: primitive.Binary{Data: compressed.Bytes()}}})
+}
+
+func isSameKeys(keys []string, others []string) bool {
+	if len(keys) != len(others) {
+		return false
+	}
+	for i, key := range keys {
+		if key != others[i] {
+			return false
+		}
+	}
+	return true
+}
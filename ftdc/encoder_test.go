@@ -0,0 +1,112 @@
+// Copyright 2018 Kuei-chun Chen. All rights reserved.
+
+package ftdc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func getTestSample(i int, members int) bson.D {
+	start := time.Date(2020, 1, 1, 0, 0, i, 0, time.UTC)
+	list := bson.A{}
+	for n := 0; n < members; n++ {
+		list = append(list, bson.D{{Key: "name", Value: "host"}, {Key: "state", Value: int32(n + 1)},
+			{Key: "optime", Value: primitive.Timestamp{T: uint32(1577836800 + i), I: uint32(n)}}})
+	}
+	return bson.D{{Key: "start", Value: primitive.NewDateTimeFromTime(start)},
+		{Key: "serverStatus", Value: bson.D{{Key: "host", Value: "localhost"},
+			{Key: "uptime", Value: float64(1000 + i)},
+			{Key: "localTime", Value: primitive.NewDateTimeFromTime(start)},
+			{Key: "connections", Value: bson.D{{Key: "current", Value: int32(10 + i%3)}}},
+			{Key: "opcounters", Value: bson.D{{Key: "insert", Value: int64(7)}, {Key: "query", Value: int64(1 << 40)}}},
+			{Key: "metrics", Value: bson.D{{Key: "delta", Value: int64(-i)}, {Key: "ok", Value: i%2 == 0}}}}},
+		{Key: "replSetGetStatus", Value: bson.D{{Key: "members", Value: list}}},
+		{Key: "end", Value: primitive.NewDateTimeFromTime(start.Add(time.Millisecond))}}
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	encoder := NewEncoder(&buffer)
+	encoder.SetMaxSamples(4)
+	if err := encoder.WriteMetadata(bson.D{{Key: "hostInfo", Value: bson.D{{Key: "system",
+		Value: bson.D{{Key: "hostname", Value: "localhost"}}}}}}); err != nil {
+		t.Fatal(err)
+	}
+	samples := []bson.D{}
+	for i := 0; i < 10; i++ {
+		members := 2
+		if i >= 6 { // schema changes, a new chunk begins
+			members = 3
+		}
+		samples = append(samples, getTestSample(i, members))
+		if err := encoder.Encode(samples[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewChunkReader(bytes.NewReader(buffer.Bytes()))
+	n := 0
+	chunks := 0
+	for {
+		md, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		chunks++
+		for i := 0; i <= int(md.NumDeltas); i++ {
+			keys := []string{}
+			expected := map[string][]uint64{}
+			traverseDocElem(&keys, &expected, samples[n], "")
+			if len(keys) != len(md.DataPointsMap) {
+				t.Fatal(n, keys)
+			}
+			for _, key := range keys {
+				if md.DataPointsMap[key][i] != expected[key][0] {
+					t.Fatal(n, key, md.DataPointsMap[key][i], expected[key][0])
+				}
+			}
+			n++
+		}
+	}
+	// 4 + 2 samples before the schema change, 4
+	if chunks != 3 || n != len(samples) || reader.Doc == nil {
+		t.Fatal(chunks, n, reader.Doc)
+	}
+}
+
+func TestEncoderZeroRuns(t *testing.T) {
+	var buffer bytes.Buffer
+	encoder := NewEncoder(&buffer)
+	for i := 0; i < DefaultMaxSamples; i++ { // zeros spanning metrics
+		if err := encoder.Encode(bson.D{{Key: "a", Value: int64(1)}, {Key: "b", Value: int64(2)},
+			{Key: "c", Value: int64(i / 100)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buffer.Len() == 0 {
+		t.Fatal("chunk not written when full")
+	}
+	m := NewMetrics()
+	data := buffer.Bytes()
+	if err := m.ReadAllMetrics(&data); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Data) != 1 || m.Data[0].NumDeltas != DefaultMaxSamples-1 {
+		t.Fatal(len(m.Data))
+	}
+	c := m.Data[0].DataPointsMap["c"]
+	if m.Data[0].DataPointsMap["b"][DefaultMaxSamples-1] != 2 || c[99] != 0 || c[100] != 1 || c[299] != 2 {
+		t.Fatal(c)
+	}
+}
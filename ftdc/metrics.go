@@ -4,54 +4,33 @@ package ftdc
 
 import (
 	"bytes"
-	"compress/zlib"
 	"io"
-	"io/ioutil"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// ReadAllMetrics reads all metrics
+// ReadAllMetrics reads all metrics, skips corrupted chunks, and returns the error of the first one
 func (m *Metrics) ReadAllMetrics(data *[]byte) error {
+	return m.ReadAllMetricsFrom(bytes.NewReader(*data))
+}
+
+// ReadAllMetricsFrom reads all metrics from a reader, one chunk at a time, skips corrupted chunks, and
+// returns the error of the first one
+func (m *Metrics) ReadAllMetricsFrom(r io.Reader) error {
 	var err error
-	var pos uint32
 	var metricsData = []MetricsData{}
-	var md MetricsData
-	var r io.ReadCloser
-	buffer := *data
-
+	reader := NewChunkReader(r)
 	for {
-		if pos >= uint32(len(buffer)) {
+		md, e := reader.Next()
+		if e == io.EOF {
 			break
-		}
-		bs := buffer[pos:(pos + 4)]
-		var length = GetUint32(bytes.NewReader(bs))
-		bs = buffer[pos:(pos + length)]
-		pos += length
-		var out = bson.M{}
-		if err = bson.Unmarshal(bs, &out); err != nil {
-			return err
-		} else if out["type"] == int32(0) {
-			m.Doc = out["doc"]
-		} else if out["type"] == int32(1) {
-			bytesBuf := bytes.NewReader((out["data"].(primitive.Binary)).Data[4:])
-			// zlib decompress
-			if r, err = zlib.NewReader(bytesBuf); err != nil {
-				return err
-			}
-			var block []byte
-			if block, err = ioutil.ReadAll(r); err != nil {
-				return err
-			}
-			if md, err = m.decode(block); err != nil {
-				return err
+		} else if e != nil {
+			if err == nil {
+				err = e
 			}
-			metricsData = append(metricsData, md)
-		} else {
-			// log.Println("==>", out["type"])
+			continue
 		}
+		metricsData = append(metricsData, md)
 	}
+	m.Doc = reader.Doc
 	m.Data = metricsData
 	return err
 }
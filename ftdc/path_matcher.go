@@ -0,0 +1,83 @@
+// Copyright 2018 Kuei-chun Chen. All rights reserved.
+
+package ftdc
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PathMatcher matches metric paths, keys joined by PathSeparator, with glob patterns.
+// * and ? don't match PathSeparator, ** matches any number of levels.
+type PathMatcher struct {
+	patterns []string
+	regexps  []*regexp.Regexp
+}
+
+// NewPathMatcher returns PathMatcher
+func NewPathMatcher(patterns []string) (*PathMatcher, error) {
+	p := &PathMatcher{}
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(globToRegex(pattern))
+		if err != nil {
+			return nil, errors.New("invalid metric path pattern " + pattern)
+		}
+		p.patterns = append(p.patterns, pattern)
+		p.regexps = append(p.regexps, re)
+	}
+	return p, nil
+}
+
+// Patterns returns glob patterns
+func (p *PathMatcher) Patterns() []string {
+	return p.patterns
+}
+
+// Match returns true if a metric path matches any pattern
+func (p *PathMatcher) Match(path string) bool {
+	for _, re := range p.regexps {
+		if re.MatchString(path) == true {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns sorted metric paths of a chunk matching any pattern
+func (p *PathMatcher) Select(md MetricsData) []string {
+	paths := []string{}
+	for path := range md.DataPointsMap {
+		if p.Match(path) == true {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func globToRegex(pattern string) string {
+	var buffer strings.Builder
+	buffer.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				buffer.WriteString(".*")
+				i++
+			} else {
+				buffer.WriteString("[^" + regexp.QuoteMeta(PathSeparator) + "]*")
+			}
+		case '?':
+			buffer.WriteString("[^" + regexp.QuoteMeta(PathSeparator) + "]")
+		default:
+			buffer.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buffer.WriteString("$")
+	return buffer.String()
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package ftdc
+
+import (
+	"io"
+	"sort"
+	"time"
+)
+
+// Health is a summary of health of FTDC data
+type Health struct {
+	Chunks        int          // metrics chunks decoded
+	Errors        []ChunkError // of chunks skipped
+	From          time.Time
+	Metadata      int // metadata documents
+	Samples       int
+	SchemaChanges int   // chunks of different metrics from the previous chunk
+	Size          int64 // bytes read
+	To            time.Time
+}
+
+// Validate reads all chunks and returns a summary of health of FTDC data
+func Validate(reader io.Reader) Health {
+	var health Health
+	var keys []string
+	r := NewChunkReader(reader)
+	for {
+		md, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			if cerr, ok := err.(*ChunkError); ok {
+				health.Errors = append(health.Errors, *cerr)
+			}
+			continue
+		}
+		health.Chunks++
+		health.Samples += int(md.NumDeltas) + 1
+		chunkKeys := make([]string, 0, len(md.DataPointsMap))
+		for key := range md.DataPointsMap {
+			chunkKeys = append(chunkKeys, key)
+		}
+		sort.Strings(chunkKeys)
+		if keys != nil && isSameKeys(keys, chunkKeys) == false {
+			health.SchemaChanges++
+		}
+		keys = chunkKeys
+		if start := md.DataPointsMap["start"]; len(start) > 0 {
+			from := time.Unix(0, int64(start[0])*int64(time.Millisecond))
+			to := time.Unix(0, int64(start[len(start)-1])*int64(time.Millisecond))
+			if health.From.IsZero() || from.Before(health.From) {
+				health.From = from
+			}
+			if to.After(health.To) {
+				health.To = to
+			}
+		}
+	}
+	health.Metadata = r.metadata
+	health.Size = r.Offset()
+	return health
+}
@@ -50,7 +50,7 @@ func (e *Explain) ExecuteAllPlans(client *mongo.Client, filename string) error {
 		buffer, _, rerr := reader.ReadLine()
 		if rerr != nil {
 			break
-		} else if strings.HasSuffix(string(buffer), "ms") == false {
+		} else if isSlowOpLog(string(buffer)) == false {
 			continue
 		}
 		if err = qe.ReadQueryShape(buffer); err != nil {
@@ -76,11 +76,26 @@ func (e *Explain) ExecuteAllPlans(client *mongo.Client, filename string) error {
 		scores := qe.GetIndexesScores(keys)
 		strs = append(strs, gox.Stringify(scores, "", "  "))
 		strs = append(strs, card.GetSummary(summary)+"\n")
+		lookups := []ExplainSummary{}
+		for _, lqe := range qe.GetLookupExplainers() {
+			strs = append(strs, "=> $lookup from "+lqe.NameSpace)
+			strs = append(strs, "=========================================")
+			lookupSummary, lerr := lqe.Explain()
+			if lerr != nil {
+				strs = append(strs, lerr.Error()+"\n")
+				continue
+			}
+			lookups = append(lookups, lookupSummary)
+			strs = append(strs, lqe.GetSummary(lookupSummary))
+		}
 		document := bson.M{}
 		document["ns"] = qe.NameSpace
 		document["cardinality"] = summary
 		document["explain"] = explainSummary
 		document["scores"] = scores
+		if len(lookups) > 0 {
+			document["lookups"] = lookups
+		}
 		if len(summary.List) > 0 {
 			recommendedIndex := GetIndexSuggestion(qe.ExplainCmd, summary.List)
 			document["recommendedIndex"] = recommendedIndex
@@ -0,0 +1,306 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/simagix/gox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// stages after which a $match or a $sort is not pushed down to the query layer
+var pushdownBlockers = []string{"$addFields", "$bucket", "$bucketAuto", "$facet", "$graphLookup", "$group",
+	"$limit", "$lookup", "$project", "$redact", "$replaceRoot", "$replaceWith", "$sample", "$set", "$skip",
+	"$sortByCount", "$unionWith", "$unset", "$unwind"}
+
+// isSlowOpLog returns true if a log line is of a slow op, a text log ending with ms or a logv2 log
+func isSlowOpLog(line string) bool {
+	return strings.HasSuffix(line, "ms") || strings.Contains(line, `"durationMillis":`)
+}
+
+// getCommand returns an explain command of a query shape
+func (cmd ExplainCommand) getCommand() bson.D {
+	filter := cmd.Filter
+	if filter == nil {
+		filter = bson.D{}
+	}
+	var explain bson.D
+	switch cmd.Command {
+	case cmdAggregate:
+		pipeline := bson.A{}
+		for _, stage := range cmd.Pipeline {
+			pipeline = append(pipeline, stage)
+		}
+		explain = bson.D{{Key: "aggregate", Value: cmd.Collection}, {Key: "pipeline", Value: pipeline},
+			{Key: "cursor", Value: bson.D{}}}
+		explain = appendHint(explain, cmd.Hint)
+	case cmdUpdate:
+		update := cmd.Update
+		if update == nil {
+			update = bson.D{}
+		}
+		stmt := appendHint(bson.D{{Key: "q", Value: filter}, {Key: "u", Value: update}, {Key: "multi", Value: cmd.Multi}}, cmd.Hint)
+		explain = bson.D{{Key: "update", Value: cmd.Collection}, {Key: "updates", Value: bson.A{stmt}}}
+	case cmdDelete:
+		stmt := appendHint(bson.D{{Key: "q", Value: filter}, {Key: "limit", Value: 0}}, cmd.Hint)
+		explain = bson.D{{Key: "delete", Value: cmd.Collection}, {Key: "deletes", Value: bson.A{stmt}}}
+	case cmdFindAndModify:
+		explain = bson.D{{Key: "findAndModify", Value: cmd.Collection}, {Key: "query", Value: filter}}
+		if len(cmd.Sort) > 0 {
+			explain = append(explain, bson.E{Key: "sort", Value: cmd.Sort})
+		}
+		if cmd.Remove {
+			explain = append(explain, bson.E{Key: "remove", Value: true})
+		} else if cmd.Update != nil {
+			explain = append(explain, bson.E{Key: "update", Value: cmd.Update})
+		} else {
+			explain = append(explain, bson.E{Key: "update", Value: bson.D{}})
+		}
+		explain = appendHint(explain, cmd.Hint)
+	case cmdCount:
+		explain = appendHint(bson.D{{Key: "count", Value: cmd.Collection}, {Key: "query", Value: filter}}, cmd.Hint)
+	case cmdDistinct: // hint is not supported
+		explain = bson.D{{Key: "distinct", Value: cmd.Collection}, {Key: "key", Value: cmd.Key}, {Key: "query", Value: filter}}
+	default:
+		explain = bson.D{{Key: "find", Value: cmd.Collection}, {Key: "filter", Value: filter}}
+		if len(cmd.Sort) > 0 {
+			explain = append(explain, bson.E{Key: "sort", Value: cmd.Sort})
+		}
+		explain = appendHint(explain, cmd.Hint)
+	}
+	return bson.D{{Key: "explain", Value: explain}}
+}
+
+func appendHint(doc bson.D, hint bson.D) bson.D {
+	if len(hint) > 0 {
+		doc = append(doc, bson.E{Key: "hint", Value: hint})
+	}
+	return doc
+}
+
+// readLogv2QueryShape sets the explain command from attributes of a logv2 slow op
+func (qe *QueryExplainer) readLogv2QueryShape(attr bson.M) error {
+	ns, _ := attr["ns"].(string)
+	pos := strings.Index(ns, ".")
+	if pos < 0 {
+		return errors.New("no namespace found")
+	}
+	command, ok := attr["command"].(bson.D)
+	if !ok {
+		return errors.New("no command found")
+	}
+	explainCmd := ExplainCommand{Collection: ns[pos+1:]}
+	switch attr["type"] {
+	case cmdUpdate:
+		explainCmd.Command = cmdUpdate
+		explainCmd.Filter = toBsonD(command.Map()["q"])
+		explainCmd.Update = command.Map()["u"]
+		explainCmd.Multi, _ = command.Map()["multi"].(bool)
+	case cmdRemove:
+		explainCmd.Command = cmdDelete
+		explainCmd.Filter = toBsonD(command.Map()["q"])
+	default:
+		if command.Map()[cmdGetMore] != nil {
+			if command, ok = attr["originatingCommand"].(bson.D); !ok {
+				return errors.New("no originating command found")
+			}
+		}
+		if err := readCommandShape(command.Map(), &explainCmd); err != nil {
+			return err
+		}
+	}
+	qe.ExplainCmd = explainCmd
+	qe.NameSpace = ns
+	if explainCmd.Command == cmdAggregate {
+		qe.ExplainCmd.Filter, qe.ExplainCmd.Sort, qe.pushdown, qe.lookups = getPipelinePushdown(explainCmd.Pipeline)
+	}
+	return nil
+}
+
+// readCommandShape sets an explain command from a logged command
+func readCommandShape(command bson.M, explainCmd *ExplainCommand) error {
+	if command[cmdFind] != nil {
+		explainCmd.Command = cmdFind
+		explainCmd.Filter = toBsonD(command["filter"])
+		explainCmd.Sort = toBsonD(command["sort"])
+		explainCmd.Hint = toBsonD(command["hint"])
+	} else if command[cmdAggregate] != nil {
+		explainCmd.Command = cmdAggregate
+		pipeline, _ := command["pipeline"].(primitive.A)
+		for _, stage := range pipeline {
+			explainCmd.Pipeline = append(explainCmd.Pipeline, toBsonD(stage))
+		}
+	} else if command["findAndModify"] != nil || command[cmdFindAndModify] != nil {
+		explainCmd.Command = cmdFindAndModify
+		explainCmd.Filter = toBsonD(command["query"])
+		explainCmd.Sort = toBsonD(command["sort"])
+		explainCmd.Update = command["update"]
+		explainCmd.Remove, _ = command["remove"].(bool)
+	} else if command[cmdUpdate] != nil { // after findAndModify of an update field
+		updates, _ := command["updates"].(primitive.A)
+		if len(updates) == 0 {
+			return errors.New("no update statement found")
+		}
+		stmt := toBsonD(updates[0]).Map()
+		explainCmd.Command = cmdUpdate
+		explainCmd.Filter = toBsonD(stmt["q"])
+		explainCmd.Update = stmt["u"]
+		explainCmd.Multi, _ = stmt["multi"].(bool)
+	} else if command[cmdDelete] != nil {
+		deletes, _ := command["deletes"].(primitive.A)
+		if len(deletes) == 0 {
+			return errors.New("no delete statement found")
+		}
+		stmt := toBsonD(deletes[0]).Map()
+		explainCmd.Command = cmdDelete
+		explainCmd.Filter = toBsonD(stmt["q"])
+	} else if command[cmdCount] != nil {
+		explainCmd.Command = cmdCount
+		explainCmd.Filter = toBsonD(command["query"])
+	} else if command[cmdDistinct] != nil {
+		explainCmd.Command = cmdDistinct
+		explainCmd.Key, _ = command["key"].(string)
+		explainCmd.Filter = toBsonD(command["query"])
+	} else {
+		return errors.New("unsupported command")
+	}
+	return nil
+}
+
+// toBsonD converts a document of any type to bson.D, nil if not a document
+func toBsonD(v interface{}) bson.D {
+	if v == nil {
+		return nil
+	}
+	var doc bson.D
+	b, err := bson.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	bson.Unmarshal(b, &doc)
+	return doc
+}
+
+// getPipelinePushdown returns the filter and the sort pushed down to the query layer, notes of stages, and
+// explain commands of $lookup sub-pipelines
+func getPipelinePushdown(pipeline []bson.D) (bson.D, bson.D, []string, []ExplainCommand) {
+	var filter, sort bson.D
+	notes := []string{}
+	lookups := []ExplainCommand{}
+	blocker := ""
+	for i, stage := range pipeline {
+		if len(stage) == 0 {
+			continue
+		}
+		name := stage[0].Key
+		switch name {
+		case "$match":
+			match := toBsonD(stage[0].Value)
+			if blocker != "" {
+				notes = append(notes, fmt.Sprintf("$match at stage %d not pushed down, after %v", i+1, blocker))
+			} else if strings.Contains(gox.Stringify(match), "$expr") {
+				notes = append(notes, fmt.Sprintf("$match at stage %d pushed down, $expr may not use indexes", i+1))
+				filter = mergeFilter(filter, match)
+			} else {
+				notes = append(notes, fmt.Sprintf("$match at stage %d pushed down", i+1))
+				filter = mergeFilter(filter, match)
+			}
+		case "$sort":
+			if blocker != "" {
+				notes = append(notes, fmt.Sprintf("$sort at stage %d not pushed down, after %v, in-memory sort", i+1, blocker))
+			} else if sort == nil {
+				notes = append(notes, fmt.Sprintf("$sort at stage %d pushed down", i+1))
+				sort = toBsonD(stage[0].Value)
+			}
+		case "$lookup":
+			if lookup, ok := getLookupCommand(toBsonD(stage[0].Value)); ok {
+				lookups = append(lookups, lookup)
+			}
+		}
+		if blocker == "" && contains(pushdownBlockers, name) {
+			blocker = name
+		}
+	}
+	return filter, sort, notes, lookups
+}
+
+// mergeFilter merges filters of $match stages
+func mergeFilter(filter bson.D, match bson.D) bson.D {
+	if filter == nil {
+		return match
+	}
+	for _, e := range match {
+		for _, f := range filter {
+			if f.Key == e.Key {
+				return bson.D{{Key: "$and", Value: bson.A{filter, match}}}
+			}
+		}
+	}
+	return append(filter, match...)
+}
+
+// getLookupCommand returns an explain command of the foreign collection of a $lookup, an equality of
+// foreignField or the sub-pipeline without stages referencing let variables
+func getLookupCommand(lookup bson.D) (ExplainCommand, bool) {
+	m := lookup.Map()
+	from, ok := m["from"].(string)
+	if !ok {
+		return ExplainCommand{}, false
+	}
+	if foreignField, ok := m["foreignField"].(string); ok {
+		return ExplainCommand{Command: cmdFind, Collection: from,
+			Filter: bson.D{{Key: foreignField, Value: nil}}}, true
+	}
+	pipeline, _ := m["pipeline"].(primitive.A)
+	cmd := ExplainCommand{Command: cmdAggregate, Collection: from}
+	for _, stage := range pipeline {
+		doc := toBsonD(stage)
+		if strings.Contains(gox.Stringify(doc), "$$") {
+			continue
+		}
+		cmd.Pipeline = append(cmd.Pipeline, doc)
+	}
+	cmd.Filter, cmd.Sort, _, _ = getPipelinePushdown(cmd.Pipeline)
+	return cmd, len(cmd.Pipeline) > 0
+}
+
+// GetLookupExplainers returns explainers of $lookup sub-pipelines of an aggregate
+func (qe *QueryExplainer) GetLookupExplainers() []*QueryExplainer {
+	explainers := []*QueryExplainer{}
+	db := strings.Split(qe.NameSpace, ".")[0]
+	for _, cmd := range qe.lookups {
+		o := NewQueryExplainer(qe.client)
+		o.SetVerbose(qe.verbose)
+		o.ExplainCmd = cmd
+		o.NameSpace = db + "." + cmd.Collection
+		explainers = append(explainers, o)
+	}
+	return explainers
+}
+
+// getCursorExplain returns the explain of the query layer of an aggregate, the $cursor stage or the
+// first shard, and the shard name
+func getCursorExplain(doc bson.D) (bson.D, string) {
+	m := doc.Map()
+	if m["queryPlanner"] != nil {
+		return doc, ""
+	}
+	if shards, ok := m["shards"].(bson.D); ok && len(shards) > 0 {
+		if shard, ok := shards[0].Value.(bson.D); ok {
+			cursor, _ := getCursorExplain(shard)
+			return cursor, shards[0].Key
+		}
+	}
+	if stages, ok := m["stages"].(primitive.A); ok && len(stages) > 0 {
+		if stage, ok := stages[0].(bson.D); ok {
+			if cursor, ok := stage.Map()["$cursor"].(bson.D); ok {
+				return cursor, ""
+			}
+		}
+	}
+	return doc, ""
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/simagix/gox"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getTestLogv2Line(attr string) string {
+	return `{"t":{"$date":"2020-09-28T11:13:09.234+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":` +
+		attr + `}`
+}
+
+func TestReadLogv2QueryShape(t *testing.T) {
+	tests := map[string]string{
+		cmdFind: `{"type":"command","ns":"db.c","command":{"find":"c","filter":{"a":1},"sort":{"b":-1,"a":1}},"durationMillis":150}`,
+		cmdUpdate: `{"type":"update","ns":"db.c","command":{"q":{"a":1},"u":{"$set":{"b":1}},"multi":true},` +
+			`"durationMillis":150}`,
+		cmdDelete:        `{"type":"remove","ns":"db.c","command":{"q":{"a":1},"limit":0},"durationMillis":150}`,
+		cmdFindAndModify: `{"type":"command","ns":"db.c","command":{"findAndModify":"c","query":{"a":1},"remove":true},"durationMillis":150}`,
+		cmdCount:         `{"type":"command","ns":"db.c","command":{"count":"c","query":{"a":1}},"durationMillis":150}`,
+		cmdDistinct:      `{"type":"command","ns":"db.c","command":{"distinct":"c","key":"b","query":{"a":1}},"durationMillis":150}`,
+	}
+	expected := map[string]string{
+		cmdFind:          `{"explain":{"find":"c","filter":{"a":1},"sort":{"b":-1,"a":1}}}`,
+		cmdUpdate:        `{"explain":{"update":"c","updates":[{"q":{"a":1},"u":{"$set":{"b":1}},"multi":true}]}}`,
+		cmdDelete:        `{"explain":{"delete":"c","deletes":[{"q":{"a":1},"limit":0}]}}`,
+		cmdFindAndModify: `{"explain":{"findAndModify":"c","query":{"a":1},"remove":true}}`,
+		cmdCount:         `{"explain":{"count":"c","query":{"a":1}}}`,
+		cmdDistinct:      `{"explain":{"distinct":"c","key":"b","query":{"a":1}}}`,
+	}
+	for command, attr := range tests {
+		line := getTestLogv2Line(attr)
+		if isSlowOpLog(line) == false {
+			t.Fatal("expected slow op", line)
+		}
+		qe := NewQueryExplainer(nil)
+		if err := qe.ReadQueryShape([]byte(line)); err != nil {
+			t.Fatal(command, err)
+		}
+		b, _ := bson.MarshalExtJSON(qe.ExplainCmd.getCommand(), false, false)
+		if qe.NameSpace != "db.c" || qe.ExplainCmd.Command != command || string(b) != expected[command] {
+			t.Fatal(command, string(b))
+		}
+	}
+}
+
+func TestReadLogv2Aggregate(t *testing.T) {
+	line := getTestLogv2Line(`{"type":"command","ns":"db.orders","command":{"aggregate":"orders","pipeline":[` +
+		`{"$match":{"status":"A"}},{"$sort":{"date":-1}},{"$match":{"qty":{"$gt":1}}},` +
+		`{"$lookup":{"from":"items","localField":"sku","foreignField":"sku","as":"items"}},` +
+		`{"$lookup":{"from":"stocks","let":{"s":"$sku"},"pipeline":[{"$match":{"$expr":{"$eq":["$sku","$$s"]}}},{"$match":{"wh":"A"}}],"as":"stocks"}},` +
+		`{"$group":{"_id":"$cust"}},{"$match":{"count":{"$gt":1}}},{"$sort":{"count":-1}}],"cursor":{}},"durationMillis":150}`)
+	qe := NewQueryExplainer(nil)
+	if err := qe.ReadQueryShape([]byte(line)); err != nil {
+		t.Fatal(err)
+	}
+	if gox.Stringify(qe.ExplainCmd.Filter) != `[{"Key":"status","Value":"A"},{"Key":"qty","Value":[{"Key":"$gt","Value":1}]}]` ||
+		gox.Stringify(qe.ExplainCmd.Sort) != `[{"Key":"date","Value":-1}]` || len(qe.ExplainCmd.Pipeline) != 8 {
+		t.Fatal(gox.Stringify(qe.ExplainCmd))
+	}
+	if len(qe.pushdown) != 5 || qe.pushdown[3] != "$match at stage 7 not pushed down, after $lookup" ||
+		qe.pushdown[4] != "$sort at stage 8 not pushed down, after $lookup, in-memory sort" {
+		t.Fatal(qe.pushdown)
+	}
+	explainers := qe.GetLookupExplainers()
+	if len(explainers) != 2 || explainers[0].NameSpace != "db.items" || explainers[0].ExplainCmd.Filter[0].Key != "sku" ||
+		explainers[1].NameSpace != "db.stocks" || len(explainers[1].ExplainCmd.Pipeline) != 1 ||
+		explainers[1].ExplainCmd.Filter[0].Key != "wh" {
+		t.Fatal(gox.Stringify(explainers[1].ExplainCmd))
+	}
+	if str := qe.GetSummary(ExplainSummary{}); strings.Contains(str, "=> Pipeline Pushdown") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestReadLogv2GetMore(t *testing.T) {
+	line := getTestLogv2Line(`{"type":"command","ns":"db.c","command":{"getMore":1,"collection":"c"},` +
+		`"originatingCommand":{"aggregate":"c","pipeline":[{"$match":{"a":1}}],"cursor":{}},"durationMillis":150}`)
+	qe := NewQueryExplainer(nil)
+	if err := qe.ReadQueryShape([]byte(line)); err != nil || qe.ExplainCmd.Command != cmdAggregate ||
+		qe.ExplainCmd.Filter[0].Key != "a" {
+		t.Fatal(gox.Stringify(qe.ExplainCmd), err)
+	}
+	line = getTestLogv2Line(`{"type":"command","ns":"db.c","command":{"insert":"c"},"durationMillis":150}`)
+	if err := qe.ReadQueryShape([]byte(line)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestGetCursorExplain(t *testing.T) {
+	queryPlanner := bson.D{{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "IXSCAN"}}}}
+	cursor := bson.D{{Key: "queryPlanner", Value: queryPlanner}}
+	doc := bson.D{{Key: "stages", Value: bson.A{bson.D{{Key: "$cursor", Value: cursor}}, bson.D{{Key: "$group", Value: bson.D{}}}}}}
+	if d, shard := getCursorExplain(doc); d.Map()["queryPlanner"] == nil || shard != "" {
+		t.Fatal(d)
+	}
+	sharded := bson.D{{Key: "shards", Value: bson.D{{Key: "shard01", Value: doc}}}}
+	if d, shard := getCursorExplain(sharded); d.Map()["queryPlanner"] == nil || shard != "shard01" {
+		t.Fatal(d, shard)
+	}
+}
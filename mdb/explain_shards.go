@@ -0,0 +1,208 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ShardStats stores the winning plan and execution stats of a shard
+type ShardStats struct {
+	ShardName           string `json:"shardName"`
+	Plan                string `json:"plan"`
+	NReturned           int    `json:"nReturned"`
+	TotalKeysExamined   int    `json:"totalKeysExamined"`
+	TotalDocsExamined   int    `json:"totalDocsExamined"`
+	ExecutionTimeMillis int    `json:"executionTimeMillis"`
+}
+
+// MergeStats stores the merge stage of shards results
+type MergeStats struct {
+	Stage               string `json:"stage"` // SINGLE_SHARD, SHARD_MERGE, SHARD_MERGE_SORT, or mergeType of an aggregate
+	NReturned           int    `json:"nReturned"`
+	ExecutionTimeMillis int    `json:"executionTimeMillis"`
+	TotalChildMillis    int    `json:"totalChildMillis"`
+}
+
+// getShardsBreakdown returns stats of each shard and the merge stage of a sharded explain, of find and
+// write commands or of an aggregate
+func getShardsBreakdown(doc bson.M) ([]ShardStats, MergeStats) {
+	shards := []ShardStats{}
+	merge := MergeStats{}
+	if aggShards, ok := doc["shards"].(bson.D); ok { // aggregate
+		merge.Stage, _ = doc["mergeType"].(string)
+		for _, e := range aggShards {
+			shardDoc, ok := e.Value.(bson.D)
+			if !ok {
+				continue
+			}
+			cursor, _ := getCursorExplain(shardDoc)
+			stats := ShardStats{ShardName: e.Key}
+			if queryPlanner, ok := cursor.Map()["queryPlanner"].(bson.D); ok {
+				winningPlan, _ := queryPlanner.Map()["winningPlan"].(bson.D)
+				stats.Plan = getPlanSummary(winningPlan)
+			}
+			if executionStats, ok := cursor.Map()["executionStats"].(bson.D); ok {
+				setShardExecutionStats(&stats, executionStats.Map())
+			}
+			shards = append(shards, stats)
+		}
+		return shards, merge
+	}
+	plans := map[string]string{}
+	if queryPlanner, ok := doc["queryPlanner"].(bson.D); ok {
+		winningPlan, _ := queryPlanner.Map()["winningPlan"].(bson.D)
+		merge.Stage, _ = winningPlan.Map()["stage"].(string)
+		list, _ := winningPlan.Map()["shards"].(primitive.A)
+		for _, elem := range list {
+			shard := toBsonD(elem).Map()
+			name, _ := shard["shardName"].(string)
+			plan, _ := shard["winningPlan"].(bson.D)
+			plans[name] = getPlanSummary(plan)
+			shards = append(shards, ShardStats{ShardName: name, Plan: plans[name]})
+		}
+	}
+	executionStats, ok := doc["executionStats"].(bson.D)
+	if !ok {
+		return shards, merge
+	}
+	stages, _ := executionStats.Map()["executionStages"].(bson.D)
+	m := stages.Map()
+	merge.NReturned = toInt(m["nReturned"])
+	merge.ExecutionTimeMillis = toInt(m["executionTimeMillis"])
+	merge.TotalChildMillis = toInt(m["totalChildMillis"])
+	list, _ := m["shards"].(primitive.A)
+	for _, elem := range list {
+		shard := toBsonD(elem).Map()
+		name, _ := shard["shardName"].(string)
+		for i := range shards {
+			if shards[i].ShardName == name {
+				setShardExecutionStats(&shards[i], shard)
+			}
+		}
+	}
+	return shards, merge
+}
+
+func setShardExecutionStats(stats *ShardStats, m bson.M) {
+	stats.NReturned = toInt(m["nReturned"])
+	stats.TotalKeysExamined = toInt(m["totalKeysExamined"])
+	stats.TotalDocsExamined = toInt(m["totalDocsExamined"])
+	stats.ExecutionTimeMillis = toInt(m["executionTimeMillis"])
+	if stats.ExecutionTimeMillis == 0 {
+		stats.ExecutionTimeMillis = toInt(m["executionTimeMillisEstimate"])
+	}
+}
+
+// getTargetingVerdict returns whether a query was targeted to shards or broadcast to all shards owning chunks
+// of the namespace, broadcast if the filter does not satisfy the shard key, with the shard key fields missing
+func getTargetingVerdict(targeted int, total int, shardKey bson.D, filter bson.D) string {
+	if targeted == 0 {
+		return "unknown, no shard explained"
+	}
+	if total == 0 || total < targeted {
+		total = targeted
+	}
+	if len(shardKey) == 0 {
+		if targeted < total {
+			return fmt.Sprintf("targeted, %d of %d shard(s)", targeted, total)
+		} else if targeted == 1 {
+			return "targeted, single shard"
+		}
+		return fmt.Sprintf("broadcast, all %d shards", total)
+	}
+	fields := GetKeys(filter)
+	missing := []string{}
+	for _, e := range shardKey {
+		if contains(fields, e.Key) == false {
+			missing = append(missing, e.Key)
+		}
+	}
+	verdict := fmt.Sprintf("broadcast, all %d shards", total)
+	if contains(missing, shardKey[0].Key) { // no prefix of the shard key
+		return verdict + fmt.Sprintf(", filter missing shard key field(s) %v of %v", strings.Join(missing, ", "),
+			getKeyString(shardKey))
+	} else if toInt(shardKey[0].Value) == 0 && isEqualityOf(filter, shardKey[0].Key) == false { // hashed
+		return verdict + fmt.Sprintf(", hashed shard key %v requires equality", getKeyString(shardKey))
+	} else if targeted < total {
+		return fmt.Sprintf("targeted, %d of %d shard(s)", targeted, total)
+	} else if targeted == 1 {
+		return "targeted, single shard"
+	}
+	return fmt.Sprintf("targeted by shard key %v, filter spans chunks of all %d shards", getKeyString(shardKey), total)
+}
+
+// isEqualityOf returns true if a field of a filter is of a value, or of $eq or $in
+func isEqualityOf(filter bson.D, field string) bool {
+	for _, e := range filter {
+		if e.Key == "$and" {
+			list, _ := e.Value.(primitive.A)
+			for _, elem := range list {
+				if isEqualityOf(toBsonD(elem), field) {
+					return true
+				}
+			}
+		} else if e.Key == field {
+			doc, ok := e.Value.(bson.D)
+			if ok == false || len(doc) == 0 || strings.HasPrefix(doc[0].Key, "$") == false {
+				return true
+			}
+			return doc[0].Key == "$eq" || doc[0].Key == "$in"
+		}
+	}
+	return false
+}
+
+// setTargeting sets the targeting verdict of a sharded explain from the shard key and number of shards
+// owning chunks of the namespace
+func (qe *QueryExplainer) setTargeting(summary *ExplainSummary) {
+	ctx := context.Background()
+	var coll struct {
+		Key  bson.D           `bson:"key"`
+		UUID primitive.Binary `bson:"uuid"`
+	}
+	if err := qe.client.Database("config").Collection("collections").FindOne(ctx,
+		bson.M{"_id": qe.NameSpace}).Decode(&coll); err != nil && err != mongo.ErrNoDocuments && qe.verbose {
+		fmt.Println(err.Error())
+	}
+	filter := bson.M{"ns": qe.NameSpace}
+	if len(coll.UUID.Data) > 0 { // chunks are of uuid since 5.0
+		filter = bson.M{"$or": bson.A{bson.M{"ns": qe.NameSpace}, bson.M{"uuid": coll.UUID}}}
+	}
+	total := 0
+	if shards, err := qe.client.Database("config").Collection("chunks").Distinct(ctx, "shard", filter); err == nil {
+		total = len(shards)
+	} else if qe.verbose {
+		fmt.Println(err.Error())
+	}
+	summary.Targeting = getTargetingVerdict(len(summary.Shards), total, coll.Key, qe.ExplainCmd.Filter)
+}
+
+// getShardsSummaryString returns the targeting, the merge stage, and stats of each shard
+func getShardsSummaryString(summary ExplainSummary) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("\n=> Shards Breakdown\n")
+	buffer.WriteString("=========================================\n")
+	if summary.Targeting != "" {
+		buffer.WriteString("Targeting: " + summary.Targeting + "\n")
+	}
+	merge := summary.Merge
+	buffer.WriteString(fmt.Sprintf("Merge: %v, nReturned: %d, executionTimeMillis: %d, totalChildMillis: %d\n",
+		merge.Stage, merge.NReturned, merge.ExecutionTimeMillis, merge.TotalChildMillis))
+	for _, s := range summary.Shards {
+		buffer.WriteString(fmt.Sprintf("Shard %v:\n", s.ShardName))
+		buffer.WriteString(fmt.Sprintf("├─winningPlan: %v\n", s.Plan))
+		buffer.WriteString(fmt.Sprintf("├─nReturned: %v\n", s.NReturned))
+		buffer.WriteString(fmt.Sprintf("├─totalKeysExamined: %v\n", s.TotalKeysExamined))
+		buffer.WriteString(fmt.Sprintf("├─totalDocsExamined: %v\n", s.TotalDocsExamined))
+		buffer.WriteString(fmt.Sprintf("└─executionTimeMillis: %v\n", s.ExecutionTimeMillis))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getTestShardExecution(name string, keys int32, docs int32) bson.D {
+	return bson.D{{Key: "shardName", Value: name}, {Key: "nReturned", Value: int32(5)},
+		{Key: "executionTimeMillis", Value: int32(3)}, {Key: "totalKeysExamined", Value: keys},
+		{Key: "totalDocsExamined", Value: docs},
+		{Key: "executionStages", Value: bson.D{{Key: "stage", Value: "FETCH"}, {Key: "advanced", Value: int32(5)},
+			{Key: "works", Value: int32(6)}, {Key: "executionTimeMillisEstimate", Value: int32(1)},
+			{Key: "inputStage", Value: bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "advanced", Value: int32(5)},
+				{Key: "works", Value: int32(6)}, {Key: "executionTimeMillisEstimate", Value: int32(0)},
+				{Key: "keyPattern", Value: bson.D{{Key: "a", Value: int32(1)}}}}}}}}
+}
+
+func getTestShardedExplain() bson.D {
+	plan := bson.D{{Key: "stage", Value: "FETCH"}, {Key: "inputStage", Value: bson.D{{Key: "stage", Value: "IXSCAN"},
+		{Key: "keyPattern", Value: bson.D{{Key: "a", Value: int32(1)}}}}}}
+	shard01 := getTestShardExecution("shard01", 5, 5)
+	shard02 := getTestShardExecution("shard02", 8, 7)
+	return bson.D{
+		{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "SHARD_MERGE"},
+			{Key: "shards", Value: bson.A{
+				bson.D{{Key: "shardName", Value: "shard01"}, {Key: "winningPlan", Value: plan}},
+				bson.D{{Key: "shardName", Value: "shard02"}, {Key: "winningPlan", Value: plan}}}}}}}},
+		{Key: "executionStats", Value: bson.D{{Key: "nReturned", Value: int32(10)},
+			{Key: "totalKeysExamined", Value: int32(13)}, {Key: "totalDocsExamined", Value: int32(12)},
+			{Key: "executionStages", Value: bson.D{{Key: "stage", Value: "SHARD_MERGE"}, {Key: "nReturned", Value: int32(10)},
+				{Key: "executionTimeMillis", Value: int32(4)}, {Key: "totalChildMillis", Value: int64(6)},
+				{Key: "advanced", Value: int32(10)}, {Key: "works", Value: int32(12)},
+				{Key: "executionTimeMillisEstimate", Value: int32(4)},
+				{Key: "shards", Value: bson.A{shard01, shard02}}}},
+			{Key: "allPlansExecution", Value: bson.A{
+				bson.D{{Key: "shardName", Value: "shard01"}, {Key: "allPlans", Value: bson.A{}}},
+				bson.D{{Key: "shardName", Value: "shard02"}, {Key: "allPlans", Value: bson.A{}}}}}}},
+	}
+}
+
+func TestGetShardsBreakdown(t *testing.T) {
+	qe := NewQueryExplainer(nil)
+	qe.ExplainCmd.Filter = bson.D{{Key: "a", Value: int32(1)}}
+	summary := qe.GetExplainDetails(getTestShardedExplain().Map())
+	if len(summary.Shards) != 2 || summary.Shards[1].ShardName != "shard02" || summary.Shards[1].Plan != "FETCH > IXSCAN { a: 1 }" ||
+		summary.Shards[1].TotalKeysExamined != 8 || summary.Shards[1].TotalDocsExamined != 7 {
+		t.Fatal(summary.Shards)
+	}
+	if summary.Merge.Stage != "SHARD_MERGE" || summary.Merge.NReturned != 10 || summary.Merge.TotalChildMillis != 6 {
+		t.Fatal(summary.Merge)
+	}
+	summary.Targeting = getTargetingVerdict(len(summary.Shards), 2, bson.D{{Key: "cust", Value: int32(1)}}, qe.ExplainCmd.Filter)
+	str := qe.GetSummary(summary)
+	if strings.Contains(str, "Targeting: broadcast, all 2 shards, filter missing shard key field(s) cust of { cust: 1 }") == false ||
+		strings.Contains(str, "Merge: SHARD_MERGE, nReturned: 10, executionTimeMillis: 4, totalChildMillis: 6") == false ||
+		strings.Contains(str, "Shard shard02:\n├─winningPlan: FETCH > IXSCAN { a: 1 }") == false {
+		t.Fatal(str)
+	}
+
+	cursor := bson.D{{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}}}},
+		{Key: "executionStats", Value: bson.D{{Key: "nReturned", Value: int32(3)}, {Key: "totalDocsExamined", Value: int32(100)}}}}
+	agg := bson.D{{Key: "mergeType", Value: "mongos"}, {Key: "shards", Value: bson.D{
+		{Key: "shard01", Value: bson.D{{Key: "stages", Value: bson.A{bson.D{{Key: "$cursor", Value: cursor}}}}}}}}}
+	shards, merge := getShardsBreakdown(agg.Map())
+	if len(shards) != 1 || shards[0].Plan != "COLLSCAN" || shards[0].TotalDocsExamined != 100 || merge.Stage != "mongos" {
+		t.Fatal(shards, merge)
+	}
+}
+
+func TestGetTargetingVerdict(t *testing.T) {
+	key := bson.D{{Key: "cust", Value: int32(1)}, {Key: "date", Value: int32(1)}}
+	hashed := bson.D{{Key: "cust", Value: "hashed"}}
+	tests := []struct {
+		targeted int
+		total    int
+		key      bson.D
+		filter   bson.D
+		expected string
+	}{
+		{1, 3, key, bson.D{{Key: "cust", Value: 1}}, "targeted, 1 of 3 shard(s)"},
+		{1, 0, key, bson.D{{Key: "cust", Value: 1}}, "targeted, single shard"},
+		{3, 3, key, bson.D{{Key: "a", Value: 1}}, "broadcast, all 3 shards, filter missing shard key field(s) cust, date of { cust: 1, date: 1 }"},
+		{3, 3, hashed, bson.D{{Key: "cust", Value: bson.D{{Key: "$gt", Value: 1}}}}, "broadcast, all 3 shards, hashed shard key { cust: hashed } requires equality"},
+		{3, 3, nil, bson.D{{Key: "a", Value: 1}}, "broadcast, all 3 shards"},
+		{0, 3, key, nil, "unknown, no shard explained"},
+		{2, 2, key, bson.D{{Key: "a", Value: 1}}, "broadcast, all 2 shards, filter missing shard key field(s) cust, date of { cust: 1, date: 1 }"},
+		{2, 4, key, bson.D{{Key: "date", Value: 1}}, "broadcast, all 4 shards, filter missing shard key field(s) cust of { cust: 1, date: 1 }"},
+		{1, 3, hashed, bson.D{{Key: "cust", Value: bson.D{{Key: "$in", Value: bson.A{1, 2}}}}}, "targeted, 1 of 3 shard(s)"},
+		{1, 3, hashed, bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "cust", Value: 1}}}}}, "targeted, 1 of 3 shard(s)"},
+		{3, 3, key, bson.D{{Key: "cust", Value: bson.D{{Key: "$gt", Value: 1}}}},
+			"targeted by shard key { cust: 1, date: 1 }, filter spans chunks of all 3 shards"},
+	}
+	for _, test := range tests {
+		if verdict := getTargetingVerdict(test.targeted, test.total, test.key, test.filter); verdict != test.expected {
+			t.Fatal(verdict)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IndexAdvice is an index to drop or to keep with justifications
+type IndexAdvice struct {
+	Database   string
+	Collection string
+	Index      Index
+	Reasons    []string
+}
+
+// IndexAdvisor advises indexes to drop, indexes unused on all hosts or prefix redundant, indexes
+// superseded by compound indexes to review, and keeps _id, TTL, unique, and shard key indexes
+type IndexAdvisor struct {
+	databases []Database
+	drops     []IndexAdvice
+	filename  string        // prefix of script files
+	keeps     []IndexAdvice // candidates must be kept
+	missing   []string      // hosts of which index usage is missing
+	reviews   []IndexAdvice // used and superseded, or usage missing, to hide and observe, never dropped by the script
+	since     time.Time
+}
+
+// NewIndexAdvisor returns IndexAdvisor
+func NewIndexAdvisor(databases []Database) *IndexAdvisor {
+	hostname, _ := os.Hostname()
+	return &IndexAdvisor{databases: databases, filename: hostname}
+}
+
+// SetSince sets the date since which an unused index has no ops on all hosts
+func (a *IndexAdvisor) SetSince(since time.Time) {
+	a.since = since
+}
+
+// SetMissingMembers sets hosts of which index usage is missing, unused indexes are to review, not to drop
+func (a *IndexAdvisor) SetMissingMembers(hosts []string) {
+	a.missing = hosts
+}
+
+// GetDropCandidates returns indexes to drop with justifications
+func (a *IndexAdvisor) GetDropCandidates() []IndexAdvice {
+	a.advise()
+	return a.drops
+}
+
+// advise classifies indexes of all collections
+func (a *IndexAdvisor) advise() {
+	a.drops = []IndexAdvice{}
+	a.keeps = []IndexAdvice{}
+	a.reviews = []IndexAdvice{}
+	for _, db := range a.databases {
+		for _, coll := range db.Collections {
+			unused := map[string]bool{}
+			for _, o := range coll.Indexes {
+				if o.KeyString != "{ _id: 1 }" && a.isUnused(o) {
+					unused[o.Name] = true
+				}
+			}
+			for _, o := range coll.Indexes {
+				if o.KeyString == "{ _id: 1 }" {
+					continue
+				}
+				reasons := []string{}
+				droppable := unused[o.Name] && len(a.missing) == 0
+				if unused[o.Name] {
+					reasons = append(reasons, a.getUnusedReason(o))
+					if len(a.missing) > 0 {
+						reasons = append(reasons, fmt.Sprintf("usage missing of host(s) %v, review", strings.Join(a.missing, ", ")))
+					}
+				}
+				if reason := getRedundantReason(o, coll.Indexes, unused); reason != "" {
+					reasons = append(reasons, reason)
+					droppable = droppable || strings.HasPrefix(reason, "prefix redundant")
+				}
+				if len(reasons) == 0 {
+					continue
+				}
+				advice := IndexAdvice{Database: db.Name, Collection: coll.Name, Index: o, Reasons: reasons}
+				if keeps := getKeepReasons(o); len(keeps) > 0 {
+					advice.Reasons = append(keeps, reasons...)
+					a.keeps = append(a.keeps, advice)
+				} else if droppable == false {
+					a.reviews = append(a.reviews, advice)
+				} else {
+					a.drops = append(a.drops, advice)
+				}
+			}
+		}
+	}
+}
+
+// isUnused returns true if an index has usage of all hosts and no ops on any of them, since the date if set
+func (a *IndexAdvisor) isUnused(o Index) bool {
+	if len(o.Usage) == 0 || o.TotalOps > 0 {
+		return false
+	}
+	for _, u := range o.Usage {
+		if u.Accesses.Ops > 0 || (a.since.IsZero() == false && u.Accesses.Since.After(a.since)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *IndexAdvisor) getUnusedReason(o Index) string {
+	since := o.Usage[0].Accesses.Since
+	for _, u := range o.Usage {
+		if u.Accesses.Since.After(since) { // all hosts are counted since the latest
+			since = u.Accesses.Since
+		}
+	}
+	return fmt.Sprintf("unused, 0 ops on %d host(s) since %v", len(o.Usage), since.UTC().Format(time.RFC3339))
+}
+
+// getKeepReasons returns reasons an index must be kept
+func getKeepReasons(o Index) []string {
+	reasons := []string{}
+	if o.IsShardKey {
+		reasons = append(reasons, "keep, shard key")
+	} else if o.HasShardKey {
+		reasons = append(reasons, "keep, starts with shard key")
+	}
+	if o.Unique {
+		reasons = append(reasons, "keep, unique constraint")
+	}
+	if o.ExpireAfterSeconds >= 0 {
+		reasons = append(reasons, fmt.Sprintf("keep, TTL of %d seconds", o.ExpireAfterSeconds))
+	}
+	return reasons
+}
+
+// getRedundantReason returns a reason if an index is a prefix of, or superseded by, a compound index,
+// not an unused one, that has more fields and the same options
+func getRedundantReason(o Index, list []Index, unused map[string]bool) string {
+	if isSpecialIndex(o) {
+		return ""
+	}
+	var prefixed, superseded *Index // the longest ones, not redundant themselves
+	for i, other := range list {
+		if other.Name == o.Name || unused[other.Name] || len(other.Key) <= len(o.Key) || isSpecialIndex(other) ||
+			hasSameOptions(o, other) == false {
+			continue
+		}
+		if isPrefixOf(o, other) {
+			if prefixed == nil || len(other.Key) > len(prefixed.Key) {
+				prefixed = &list[i]
+			}
+		} else if o.IsDupped && checkIfDupped(o, []Index{other}) {
+			if superseded == nil || len(other.Key) > len(superseded.Key) {
+				superseded = &list[i]
+			}
+		}
+	}
+	if prefixed != nil {
+		return fmt.Sprintf("prefix redundant, %v is a prefix of %v (%v)", o.KeyString, prefixed.KeyString, prefixed.Name)
+	} else if superseded != nil {
+		return fmt.Sprintf("superseded by compound %v (%v), same leading field and covers all fields, review sort orders",
+			superseded.KeyString, superseded.Name)
+	}
+	return ""
+}
+
+// isPrefixOf returns true if keys of an index are the leading keys of the other, in the same or all
+// reversed directions
+func isPrefixOf(o Index, other Index) bool {
+	same, reversed := true, true
+	for i, e := range o.Key {
+		if e.Key != other.Key[i].Key {
+			return false
+		}
+		v, ov := toInt(e.Value), toInt(other.Key[i].Value)
+		same = same && v == ov
+		reversed = reversed && v == -ov
+	}
+	return same || reversed
+}
+
+// isSpecialIndex returns true if an index has keys other than ascending or descending, e.g. 2dsphere, text, or hashed
+func isSpecialIndex(o Index) bool {
+	for _, e := range o.Key {
+		if v := toInt(e.Value); v != 1 && v != -1 {
+			return true
+		}
+	}
+	return len(o.Key) == 0
+}
+
+// hasSameOptions returns true if both indexes are of the same partial filter, sparse, and collation
+func hasSameOptions(o Index, other Index) bool {
+	return o.Sparse == other.Sparse && fmt.Sprint(o.PartialFilterExpression) == fmt.Sprint(other.PartialFilterExpression) &&
+		fmt.Sprint(o.Collation) == fmt.Sprint(other.Collation)
+}
+
+// GetReviewCandidates returns used indexes superseded by compound indexes, to review before hiding
+func (a *IndexAdvisor) GetReviewCandidates() []IndexAdvice {
+	if a.reviews == nil {
+		a.advise()
+	}
+	return a.reviews
+}
+
+// GetAdvice returns a report of indexes to drop, to review, and to keep with justifications
+func (a *IndexAdvisor) GetAdvice() string {
+	if a.drops == nil {
+		a.advise()
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString("\n--- Index Drop Candidates ---\n")
+	if len(a.drops) == 0 {
+		buffer.WriteString("No index to drop\n")
+	}
+	writeAdvices(&buffer, a.drops)
+	if len(a.reviews) > 0 {
+		buffer.WriteString("\n--- Indexes to Review, only in the hide script ---\n")
+	}
+	writeAdvices(&buffer, a.reviews)
+	if len(a.keeps) > 0 {
+		buffer.WriteString("\n--- Indexes Kept ---\n")
+	}
+	writeAdvices(&buffer, a.keeps)
+	return buffer.String()
+}
+
+func writeAdvices(buffer *bytes.Buffer, advices []IndexAdvice) {
+	for _, advice := range advices {
+		buffer.WriteString(fmt.Sprintf("%v.%v %v (%v), ops: %d\n", advice.Database, advice.Collection,
+			advice.Index.KeyString, advice.Index.Name, advice.Index.TotalOps))
+		for _, reason := range advice.Reasons {
+			buffer.WriteString("\t" + reason + "\n")
+		}
+	}
+}
+
+// GetDropScript returns a mongo shell script to drop candidates, unused or prefix redundant indexes only
+func (a *IndexAdvisor) GetDropScript() string {
+	if a.drops == nil {
+		a.advise()
+	}
+	return getScript(a.drops, "dropIndex", "")
+}
+
+// GetHideScript returns a mongo shell script to hide candidates and indexes to review (4.4+) before
+// dropping them, with unhideIndex to roll back
+func (a *IndexAdvisor) GetHideScript() string {
+	if a.drops == nil {
+		a.advise()
+	}
+	return getScript(append(append([]IndexAdvice{}, a.drops...), a.reviews...), "hideIndex", "unhideIndex")
+}
+
+func getScript(advices []IndexAdvice, command string, rollback string) string {
+	drops := append([]IndexAdvice{}, advices...)
+	sort.SliceStable(drops, func(i int, j int) bool {
+		if drops[i].Database != drops[j].Database {
+			return drops[i].Database < drops[j].Database
+		}
+		return drops[i].Collection < drops[j].Collection
+	})
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("// %v of %d index(es), review before running\n", command, len(drops)))
+	for _, advice := range drops {
+		coll := fmt.Sprintf(`db.getSiblingDB(%q).getCollection(%q)`, advice.Database, advice.Collection)
+		buffer.WriteString(fmt.Sprintf("\n// %v %v\n", advice.Index.KeyString, strings.Join(advice.Reasons, "; ")))
+		if rollback != "" {
+			buffer.WriteString(fmt.Sprintf("// rollback: %v.%v(%q);\n", coll, rollback, advice.Index.Name))
+		}
+		buffer.WriteString(fmt.Sprintf("%v.%v(%q);\n", coll, command, advice.Index.Name))
+	}
+	return buffer.String()
+}
+
+// OutputScripts writes dropIndex and hideIndex scripts to files
+func (a *IndexAdvisor) OutputScripts() error {
+	var err error
+	outdir := "./out/"
+	os.Mkdir(outdir, 0755)
+	prefix := outdir + strings.Replace(a.filename, ":", "_", -1)
+	scripts := []string{a.GetDropScript(), a.GetHideScript()}
+	for i, suffix := range []string{"-drop-indexes.js", "-hide-indexes.js"} {
+		if err = ioutil.WriteFile(prefix+suffix, []byte(scripts[i]), 0644); err != nil {
+			return err
+		}
+		fmt.Println("script written to", prefix+suffix)
+	}
+	return err
+}
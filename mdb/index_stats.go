@@ -21,6 +21,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
 )
 
 // IndexStats holder indexes reader struct
@@ -28,10 +30,14 @@ type IndexStats struct {
 	Databases []Database `bson:"databases"`
 	Logger    *Logger    `bson:"keyhole"`
 
-	filename string
-	nocolor  bool
-	verbose  bool
-	version  string
+	connString connstring.ConnString
+	filename   string
+	members    []*mongo.Client // usage of every member, $indexStats only counts the node it runs on
+	memberHost []string        // of members
+	missing    map[string]bool // hosts of which index usage is missing
+	nocolor    bool
+	verbose    bool
+	version    string
 }
 
 // Accesses stores index accesses
@@ -64,6 +70,7 @@ type Index struct {
 	Fields       []string     `json:"fields" bson:"fields"`
 	IsDupped     bool         `json:"isDupped" bson:"isDupped"`
 	IsShardKey   bool         `json:"isShardkey" bson:"isShardkey"`
+	HasShardKey  bool         `json:"hasShardKey" bson:"hasShardKey"` // key starts with the shard key
 	KeyString    string       `json:"keyString" bson:"keyString"`
 	TotalOps     int          `json:"totalOps" bson:"totalOps"`
 	Usage        []IndexUsage `json:"usage" bson:"usage"`
@@ -76,6 +83,11 @@ func NewIndexStats(version string) *IndexStats {
 		filename: hostname + "-index.bson.gz", Databases: []Database{}}
 }
 
+// SetConnString sets connection string to collect index usage from all members
+func (ix *IndexStats) SetConnString(connString connstring.ConnString) {
+	ix.connString = connString
+}
+
 // SetFilename sets output file name
 func (ix *IndexStats) SetFilename(filename string) {
 	ix.filename = strings.Replace(filename, ":", "_", -1)
@@ -124,6 +136,8 @@ func (ix *IndexStats) GetIndexes(client *mongo.Client) ([]Database, error) {
 	if dbNames, err = ListDatabaseNames(client); err != nil {
 		return databases, err
 	}
+	ix.connectMembers(client)
+	defer ix.disconnectMembers()
 	cnt := 0
 	for _, name := range dbNames {
 		if name == "admin" || name == "config" || name == "local" {
@@ -148,6 +162,126 @@ func (ix *IndexStats) GetIndexes(client *mongo.Client) ([]Database, error) {
 	return ix.Databases, err
 }
 
+// connectMembers connects to all members if the connection string is set
+func (ix *IndexStats) connectMembers(client *mongo.Client) {
+	ix.members = []*mongo.Client{}
+	ix.memberHost = []string{}
+	ix.missing = map[string]bool{}
+	if len(ix.connString.Hosts) == 0 {
+		return
+	}
+	uris, err := GetMemberURIs(client, ix.connString)
+	if err != nil {
+		ix.Logger.Warn(fmt.Sprintf(`index usage of the connected node only, %v`, err))
+		ix.missing["unlisted members"] = true
+		return
+	}
+	for _, uri := range uris {
+		var mclient *mongo.Client
+		host := getMemberHost(uri)
+		if mclient, err = NewMongoClient(uri, ix.connString.SSLCaFile, ix.connString.SSLClientCertificateKeyFile); err != nil {
+			ix.Logger.Warn(fmt.Sprintf(`index usage of member %v is missing, %v`, host, err))
+			ix.missing[host] = true
+			continue
+		}
+		ix.members = append(ix.members, mclient)
+		ix.memberHost = append(ix.memberHost, host)
+	}
+}
+
+func (ix *IndexStats) disconnectMembers() {
+	for _, mclient := range ix.members {
+		mclient.Disconnect(context.Background())
+	}
+	ix.members = nil
+	ix.memberHost = nil
+}
+
+// getMemberHost returns host:port of a member URI
+func getMemberHost(uri string) string {
+	cs, err := connstring.Parse(uri)
+	if err != nil || len(cs.Hosts) == 0 {
+		return "unknown member"
+	}
+	return strings.Join(cs.Hosts, ",")
+}
+
+// GetMissingMembers returns hosts of which index usage is missing, failed to connect or to run $indexStats
+func (ix *IndexStats) GetMissingMembers() []string {
+	hosts := []string{}
+	for host := range ix.missing {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func (ix *IndexStats) setMissing(host string) {
+	if ix.missing == nil {
+		ix.missing = map[string]bool{}
+	}
+	ix.missing[host] = true
+}
+
+// getIndexUsage returns $indexStats of a collection
+func getIndexUsage(collection *mongo.Collection) ([]IndexUsage, error) {
+	var err error
+	var ctx = context.Background()
+	var cur *mongo.Cursor
+	var list = []IndexUsage{}
+	if cur, err = collection.Aggregate(ctx, MongoPipeline(`{"$indexStats": {}}`)); err != nil {
+		return list, err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var result IndexUsage
+		if err = cur.Decode(&result); err != nil {
+			log.Println(err)
+			continue
+		}
+		list = append(list, result)
+	}
+	return list, nil
+}
+
+// getMembersIndexUsage appends $indexStats of all members, hosts already counted are skipped
+func (ix *IndexStats) getMembersIndexUsage(collection *mongo.Collection, indexStats []IndexUsage) []IndexUsage {
+	counted := map[string]bool{}
+	for _, result := range indexStats {
+		counted[result.Host+"/"+result.Name] = true
+	}
+	opts := options.Collection().SetReadPreference(readpref.Nearest())
+	for i, mclient := range ix.members {
+		coll := mclient.Database(collection.Database().Name()).Collection(collection.Name(), opts)
+		list, err := getIndexUsage(coll)
+		if err != nil {
+			ix.Logger.Warn(fmt.Sprintf(`index usage of member %v is missing, %v`, ix.memberHost[i], err))
+			ix.setMissing(ix.memberHost[i])
+			continue
+		}
+		for _, result := range list {
+			if counted[result.Host+"/"+result.Name] == false {
+				counted[result.Host+"/"+result.Name] = true
+				indexStats = append(indexStats, result)
+			}
+		}
+	}
+	return indexStats
+}
+
+// hasShardKeyPrefix returns true if an index key starts with all fields of the shard key
+func hasShardKeyPrefix(key bson.D, shardKey bson.D) bool {
+	if len(shardKey) == 0 || len(key) < len(shardKey) {
+		return false
+	}
+	for i, e := range shardKey {
+		if key[i].Key != e.Key || fmt.Sprint(key[i].Value) != fmt.Sprint(e.Value) {
+			return false
+		}
+	}
+	return true
+}
+
 // GetIndexesFromDB list all indexes of collections of a database
 func (ix *IndexStats) GetIndexesFromDB(client *mongo.Client, db string) ([]Collection, error) {
 	var err error
@@ -194,27 +328,22 @@ func (ix *IndexStats) GetIndexesFromDB(client *mongo.Client, db string) ([]Colle
 func (ix *IndexStats) GetIndexesFromCollection(client *mongo.Client, collection *mongo.Collection) ([]Index, error) {
 	var err error
 	var ctx = context.Background()
-	var pipeline = MongoPipeline(`{"$indexStats": {}}`)
 	var list []Index
 	var icur *mongo.Cursor
-	var scur *mongo.Cursor
 	db := collection.Database().Name()
 	ix.Logger.Add(fmt.Sprintf(`GetIndexesFromCollection from %v.%v`, db, collection.Name()))
 
-	var indexStats = []IndexUsage{}
-	if scur, err = collection.Aggregate(ctx, pipeline); err != nil {
+	var indexStats []IndexUsage
+	if indexStats, err = getIndexUsage(collection); err != nil {
 		log.Println(err)
-	} else {
-		for scur.Next(ctx) {
-			var result IndexUsage
-			if err = scur.Decode(&result); err != nil {
-				log.Println(err)
-				continue
-			}
-			indexStats = append(indexStats, result)
-		}
-		scur.Close(ctx)
+		ix.setMissing("connected node")
+	}
+	indexStats = ix.getMembersIndexUsage(collection, indexStats)
+	ns := db + "." + collection.Name()
+	var shardKey struct {
+		Key bson.D `bson:"key"`
 	}
+	client.Database("config").Collection("collections").FindOne(ctx, bson.M{"_id": ns}).Decode(&shardKey)
 
 	cmd := bson.D{{Key: "listIndexes", Value: collection.Name()}}
 	if icur, err = client.Database(db).RunCommandCursor(ctx, cmd); err != nil {
@@ -247,14 +376,11 @@ func (ix *IndexStats) GetIndexesFromCollection(client *mongo.Client, collection
 		o.Fields = fields
 		o.KeyString = strbuf.String()
 		// Check shard keys
-		var v map[string]interface{}
-		ns := collection.Database().Name() + "." + collection.Name()
 		if ix.verbose {
 			log.Println("GetIndexesFromCollection", ns, o.KeyString)
 		}
-		if err = client.Database("config").Collection("collections").FindOne(ctx, bson.M{"_id": ns, "key": o.Key}).Decode(&v); err == nil {
-			o.IsShardKey = true
-		}
+		o.HasShardKey = hasShardKeyPrefix(o.Key, shardKey.Key)
+		o.IsShardKey = o.HasShardKey && len(o.Key) == len(shardKey.Key)
 		o.EffectiveKey = strings.Replace(o.KeyString[2:len(o.KeyString)-2], ": -1", ": 1", -1)
 		o.Usage = []IndexUsage{}
 		for i, result := range indexStats {
@@ -275,7 +401,6 @@ func (ix *IndexStats) GetIndexesFromCollection(client *mongo.Client, collection
 	if len(indexesFound) != len(indexStats) {
 		for i := 0; i < len(indexStats); i++ {
 			if indexesFound[i] != true {
-				ns := collection.Database().Name() + "." + collection.Name()
 				ix.Logger.Warn(fmt.Sprintf(`inconsistent index '%v' of namespace '%v' on shard '%v'`,
 					indexStats[i].Name, ns, indexStats[i].Shard))
 			}
@@ -0,0 +1,84 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"math"
+	"sort"
+)
+
+// sketchGamma bounds the relative error of quantiles to about 1%
+const sketchGamma = 1.02
+
+// LatencySketch is a mergeable log-bucketed histogram of latencies in milliseconds
+type LatencySketch struct {
+	Bins  []SketchBin `bson:"bins"`
+	Count int         `bson:"count"`
+	Zeros int         `bson:"zeros"` // number of 0ms ops
+}
+
+// SketchBin holds count of a bucket
+type SketchBin struct {
+	Index int `bson:"i"`
+	Count int `bson:"n"`
+}
+
+// NewLatencySketch returns LatencySketch
+func NewLatencySketch() *LatencySketch {
+	return &LatencySketch{Bins: []SketchBin{}}
+}
+
+// Add adds a latency in milliseconds
+func (s *LatencySketch) Add(milli int) {
+	s.Count++
+	if milli <= 0 {
+		s.Zeros++
+		return
+	}
+	s.addBin(SketchBin{Index: int(math.Ceil(math.Log(float64(milli)) / math.Log(sketchGamma))), Count: 1})
+}
+
+// Merge merges another sketch
+func (s *LatencySketch) Merge(other *LatencySketch) {
+	if other == nil {
+		return
+	}
+	s.Count += other.Count
+	s.Zeros += other.Zeros
+	for _, bin := range other.Bins {
+		s.addBin(bin)
+	}
+}
+
+func (s *LatencySketch) addBin(bin SketchBin) {
+	i := sort.Search(len(s.Bins), func(i int) bool { return s.Bins[i].Index >= bin.Index })
+	if i < len(s.Bins) && s.Bins[i].Index == bin.Index {
+		s.Bins[i].Count += bin.Count
+		return
+	}
+	s.Bins = append(s.Bins, SketchBin{})
+	copy(s.Bins[i+1:], s.Bins[i:])
+	s.Bins[i] = bin
+}
+
+// Quantile returns the latency in milliseconds at q (0 to 1)
+func (s *LatencySketch) Quantile(q float64) int {
+	if s == nil || s.Count == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q * float64(s.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	total := s.Zeros
+	if rank <= total {
+		return 0
+	}
+	for _, bin := range s.Bins {
+		total += bin.Count
+		if rank <= total {
+			return int(math.Round(2 * math.Pow(sketchGamma, float64(bin.Index)) / (sketchGamma + 1)))
+		}
+	}
+	return int(math.Round(math.Pow(sketchGamma, float64(s.Bins[len(s.Bins)-1].Index))))
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLatencySketchQuantile(t *testing.T) {
+	sketch := NewLatencySketch()
+	for i := 1; i <= 1000; i++ {
+		sketch.Add(i)
+	}
+	for _, q := range []float64{.5, .95, .99} {
+		expected := q * 1000
+		if v := float64(sketch.Quantile(q)); math.Abs(v-expected)/expected > .02 {
+			t.Fatal(q, v, expected)
+		}
+	}
+}
+
+func TestLatencySketchMerge(t *testing.T) {
+	a := NewLatencySketch()
+	b := NewLatencySketch()
+	for i := 0; i < 98; i++ {
+		a.Add(2)
+	}
+	b.Add(0)
+	b.Add(30000)
+	a.Merge(b)
+	if a.Count != 100 || a.Zeros != 1 {
+		t.Fatal(a.Count, a.Zeros)
+	}
+	if a.Quantile(.5) != 2 {
+		t.Fatal(a.Quantile(.5))
+	}
+	if v := a.Quantile(1); math.Abs(float64(v)-30000)/30000 > .02 {
+		t.Fatal(v)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AuditLog stores an audit event of JSON or BSON audit logs
+type AuditLog struct {
+	AuditType string `json:"atype"`
+	Param     struct {
+		ClientMetadata map[string]interface{} `json:"clientMetadata"`
+		Command        string                 `json:"command"`
+		DB             string                 `json:"db"`
+		Mechanism      string                 `json:"mechanism"`
+		User           string                 `json:"user"`
+	} `json:"param"`
+	Result    int                    `json:"result"`
+	Timestamp map[string]interface{} `json:"ts"`
+	Users     []struct {
+		DB   string `json:"db"`
+		User string `json:"user"`
+	} `json:"users"`
+}
+
+// AuditEvent counts audit events of a user, action, and result
+type AuditEvent struct {
+	Action string `bson:"action"`
+	Count  int    `bson:"count"`
+	Result int    `bson:"result"`
+	User   string `bson:"user"`
+}
+
+// auditParser parses MongoDB audit logs, events are added to the audit summary of li.Connections
+// and not to slow ops, audit events have no durations
+type auditParser struct{}
+
+func (p *auditParser) Detect(str string) bool {
+	return strings.HasPrefix(str, "{") && strings.Contains(str, `"atype"`)
+}
+
+func (p *auditParser) Name() string {
+	return "audit"
+}
+
+func (p *auditParser) Parse(li *LogInfo, str string) (LogStats, error) {
+	var err error
+	var stat LogStats
+	var doc AuditLog
+	if err = json.Unmarshal([]byte(str), &doc); err != nil {
+		return stat, err
+	}
+	if doc.AuditType == "" {
+		return stat, errors.New("unsupported audit event")
+	}
+	date, _ := doc.Timestamp["$date"].(string)
+	if li.from.IsZero() == false || li.to.IsZero() == false {
+		t, err := parseLogTime(date)
+		if err != nil {
+			return stat, err
+		} else if li.isInTimeRange(t) == false {
+			return stat, errors.New("out of time range")
+		}
+	}
+	if li.Connections == nil {
+		return stat, errors.New("audit event")
+	}
+	param := doc.Param
+	switch doc.AuditType {
+	case "authenticate":
+		if doc.Result != 0 {
+			li.Connections.addAuthFailure(param.User+"@"+param.DB, param.Mechanism)
+		}
+	case "clientMetadata":
+		if driver, ok := param.ClientMetadata["driver"].(map[string]interface{}); ok {
+			name, _ := driver["name"].(string)
+			version, _ := driver["version"].(string)
+			li.Connections.addDriver(name, version)
+		}
+	}
+	li.Connections.addAuditEvents(AuditEvent{Action: getAuditAction(doc), Count: 1, Result: doc.Result, User: getAuditUser(doc)})
+	return stat, errors.New("audit event")
+}
+
+// getAuditAction returns the audit type and the command checked, e.g. authCheck find
+func getAuditAction(doc AuditLog) string {
+	if doc.Param.Command != "" {
+		return doc.AuditType + " " + doc.Param.Command
+	}
+	return doc.AuditType
+}
+
+// getAuditUser returns users of an audit event, or the user of the param, e.g. of authenticate
+func getAuditUser(doc AuditLog) string {
+	users := []string{}
+	for _, v := range doc.Users {
+		users = append(users, v.User+"@"+v.DB)
+	}
+	if len(users) == 0 && doc.Param.User != "" {
+		users = append(users, doc.Param.User+"@"+doc.Param.DB)
+	}
+	if len(users) == 0 {
+		return "N/A"
+	}
+	return strings.Join(users, ",")
+}
+
+func getAuditKey(event AuditEvent) string {
+	return fmt.Sprintf("%v %v %v", event.User, event.Action, event.Result)
+}
+
+// addAuditEvents adds counts of audit events of a user, action, and result
+func (ci *ConnectionsInfo) addAuditEvents(event AuditEvent) {
+	key := getAuditKey(event)
+	audit := ci.audits[key]
+	audit.Action, audit.Result, audit.User = event.Action, event.Result, event.User
+	audit.Count += event.Count
+	ci.audits[key] = audit
+}
+
+// printAuditSummary prints audit events by user, action, and result, failed results are in red
+func (ci *ConnectionsInfo) printAuditSummary(nocolor bool) string {
+	red := codeRed
+	tail := codeDefault
+	if nocolor {
+		red = ""
+		tail = ""
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString("\nAudit events:\n")
+	buffer.WriteString("+----------------------------------------+------------------------------+--------+----------+\n")
+	buffer.WriteString("| User                                   | Action                       | Result |  Count   |\n")
+	buffer.WriteString("|----------------------------------------+------------------------------+--------+----------|\n")
+	for _, v := range ci.AuditEvents {
+		if v.Result != 0 {
+			buffer.WriteString(fmt.Sprintf("|%v %-38s | %-28s | %6d | %8d %v|\n", red, v.User, v.Action, v.Result, v.Count, tail))
+		} else {
+			buffer.WriteString(fmt.Sprintf("| %-38s | %-28s | %6d | %8d |\n", v.User, v.Action, v.Result, v.Count))
+		}
+	}
+	buffer.WriteString("+----------------------------------------+------------------------------+--------+----------+\n")
+	return buffer.String()
+}
+
+// isBSONDocument returns true if data begin with a BSON document, e.g. of a BSON audit log
+func isBSONDocument(data []byte) bool {
+	if len(data) < 5 || data[0] == '{' {
+		return false
+	}
+	size := binary.LittleEndian.Uint32(data)
+	return size >= 5 && size < 16*1024*1024 && data[3] == 0
+}
+
+// parseBSONDocuments parses BSON documents, each as a line in relaxed extended JSON
+func (li *LogInfo) parseBSONDocuments(reader *bufio.Reader) error {
+	var err error
+	header := make([]byte, 4)
+	for {
+		if _, err = io.ReadFull(reader, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size := binary.LittleEndian.Uint32(header)
+		if size < 5 {
+			return errors.New("invalid BSON document")
+		}
+		data := make([]byte, size)
+		copy(data, header)
+		if _, err = io.ReadFull(reader, data[4:]); err != nil {
+			return err
+		}
+		var buf []byte
+		if buf, err = bson.MarshalExtJSON(bson.Raw(data), false, false); err != nil {
+			return err
+		}
+		li.parseLine(string(buf))
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var auditLogs = []string{
+	`{ "atype" : "authenticate", "ts" : { "$date" : "2020-05-12T12:48:14.398-0400" }, "local" : { "ip" : "127.0.0.1", "port" : 27017 }, "remote" : { "ip" : "10.0.0.1", "port" : 50036 }, "users" : [], "roles" : [], "param" : { "user" : "root", "db" : "admin", "mechanism" : "SCRAM-SHA-256" }, "result" : 18 }`,
+	`{ "atype" : "authCheck", "ts" : { "$date" : "2020-05-12T12:48:15.398-0400" }, "local" : { "ip" : "127.0.0.1", "port" : 27017 }, "remote" : { "ip" : "10.0.0.1", "port" : 50036 }, "users" : [ { "user" : "app", "db" : "admin" } ], "roles" : [], "param" : { "command" : "find", "ns" : "test.orders", "args" : { "find" : "orders", "filter" : { "status" : "A", "qty" : { "$gt" : 10 } }, "$db" : "test" } }, "result" : 0 }`,
+	`{ "atype" : "authCheck", "ts" : { "$date" : "2020-05-12T12:48:16.398-0400" }, "local" : { "ip" : "127.0.0.1", "port" : 27017 }, "remote" : { "ip" : "10.0.0.1", "port" : 50036 }, "users" : [ { "user" : "app", "db" : "admin" } ], "roles" : [], "param" : { "command" : "find", "ns" : "test.orders", "args" : { "find" : "orders", "filter" : { "status" : "B", "qty" : { "$gt" : 5 } }, "$db" : "test" } }, "result" : 0 }`,
+	`{ "atype" : "authCheck", "ts" : { "$date" : "2020-05-12T12:48:17.398-0400" }, "local" : { "ip" : "127.0.0.1", "port" : 27017 }, "remote" : { "ip" : "10.0.0.1", "port" : 50036 }, "users" : [ { "user" : "app", "db" : "admin" } ], "roles" : [], "param" : { "command" : "update", "ns" : "test.orders", "args" : { "update" : "orders", "updates" : [ { "q" : { "_id" : 1 }, "u" : { "$set" : { "status" : "C" } } } ], "$db" : "test" } }, "result" : 0 }`,
+}
+
+func assertAuditLogInfo(t *testing.T, li *LogInfo) {
+	if li.LogType != "audit" || len(li.OpPatterns) != 0 || len(li.SlowOps) != 0 {
+		t.Fatal(li.LogType, li.OpPatterns, li.SlowOps)
+	}
+	events := li.Connections.AuditEvents
+	if len(events) != 3 || events[0].User != "app@admin" || events[0].Action != "authCheck find" ||
+		events[0].Result != 0 || events[0].Count != 2 {
+		t.Fatal(events)
+	}
+	for _, v := range events[1:] {
+		if v.Count != 1 || (v.Action == "authenticate" && (v.User != "root@admin" || v.Result != 18)) {
+			t.Fatal(v)
+		}
+	}
+	if len(li.Connections.AuthFailures) != 1 || li.Connections.AuthFailures[0].User != "root@admin" {
+		t.Fatal(li.Connections.AuthFailures)
+	}
+	if str := li.Connections.printAuditSummary(true); strings.Contains(str, "authCheck update") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestAuditParserJSON(t *testing.T) {
+	li := NewLogInfo("test")
+	li.SetSilent(true)
+	if err := li.Parse(bufio.NewReader(strings.NewReader(strings.Join(auditLogs, "\n")))); err != nil {
+		t.Fatal(err)
+	}
+	assertAuditLogInfo(t, li)
+
+	data, err := bson.Marshal(li)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewLogInfo("test")
+	if err = bson.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.Connections.load()
+	loaded.Merge(li)
+	if events := loaded.Connections.AuditEvents; len(events) != 3 || events[0].Count != 4 {
+		t.Fatal(events)
+	}
+}
+
+func TestAuditParserBSON(t *testing.T) {
+	var buffer bytes.Buffer
+	for _, str := range auditLogs {
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON([]byte(str), false, &doc); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := bson.Marshal(doc)
+		buffer.Write(data)
+	}
+	li := NewLogInfo("test")
+	li.SetSilent(true)
+	if err := li.Parse(bufio.NewReader(&buffer)); err != nil {
+		t.Fatal(err)
+	}
+	assertAuditLogInfo(t, li)
+}
@@ -0,0 +1,469 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ConnectionsInfo stores connections, authentication, replication, and audit events from logs
+type ConnectionsInfo struct {
+	Accepted     int              `bson:"accepted"`
+	AuditEvents  []AuditEvent     `bson:"auditEvents"`
+	AuthFailures []AuthFailure    `bson:"authFailures"`
+	Clients      []ClientCount    `bson:"clients"`
+	Drivers      []DriverCount    `bson:"drivers"`
+	Ended        int              `bson:"ended"`
+	Histogram    []ConnsHistogram `bson:"histogram"`
+	ReplEvents   []ReplEvent      `bson:"replEvents"`
+
+	audits       map[string]AuditEvent
+	authFailures map[string]AuthFailure
+	clients      map[string]int
+	drivers      map[string]DriverCount
+	hists        map[string]ConnsHistogram
+}
+
+// AuthFailure counts authentication failures of a user and mechanism
+type AuthFailure struct {
+	Count     int    `bson:"count"`
+	Mechanism string `bson:"mechanism"`
+	User      string `bson:"user"`
+}
+
+// ClientCount counts connections accepted from a client IP
+type ClientCount struct {
+	Count int    `bson:"count"`
+	IP    string `bson:"ip"`
+}
+
+// DriverCount counts client metadata of a driver name and version
+type DriverCount struct {
+	Count   int    `bson:"count"`
+	Name    string `bson:"name"`
+	Version string `bson:"version"`
+}
+
+// ConnsHistogram stores connections accepted and ended
+type ConnsHistogram struct {
+	Accepted int    `bson:"accepted"`
+	Ended    int    `bson:"ended"`
+	UTC      string `bson:"utc"`
+}
+
+// ReplEvent stores an election, stepdown, or rollback event
+type ReplEvent struct {
+	Date    string `bson:"date"`
+	Message string `bson:"message"`
+	Type    string `bson:"type"`
+}
+
+const maxReplEvents = 100
+
+// NewConnectionsInfo returns ConnectionsInfo
+func NewConnectionsInfo() *ConnectionsInfo {
+	return &ConnectionsInfo{audits: map[string]AuditEvent{}, authFailures: map[string]AuthFailure{}, clients: map[string]int{},
+		drivers: map[string]DriverCount{}, hists: map[string]ConnsHistogram{}}
+}
+
+var reTextConnAccepted = regexp.MustCompile(`^(\S+) .* connection accepted from (\S+):\d+ `)
+var reTextConnEnded = regexp.MustCompile(`^(\S+) .* end connection (\S+):\d+ `)
+var reTextDriver = regexp.MustCompile(`driver: { name: "([^"]*)", version: "([^"]*)"`)
+var reTextAuthFailed = regexp.MustCompile(` (\S+) authentication failed for (\S+) on (\S+) from`)
+var reTextAuthFailedLegacy = regexp.MustCompile(` Failed to authenticate (\S+) from client \S+ with mechanism (\S+):`)
+var reTextRepl = regexp.MustCompile(`^(\S+) \S+\s+(REPL|ELECTION|ROLLBACK)\s+\[\S+\] (.*)$`)
+
+// parseConnection adds connections, authentication, and replication events within the time window,
+// returns true if the line is consumed
+func (li *LogInfo) parseConnection(str string) bool {
+	if li.from.IsZero() == false || li.to.IsZero() == false {
+		if t, err := parseLogTime(getLogTimestamp(str)); err == nil && li.isInTimeRange(t) == false {
+			return true
+		}
+	}
+	return li.Connections.parseConnection(str)
+}
+
+// getLogTimestamp returns the timestamp string of a text or logv2 line
+func getLogTimestamp(str string) string {
+	if strings.HasPrefix(str, "{") {
+		key := `"$date":"`
+		if idx := strings.Index(str, key); idx >= 0 {
+			str = str[idx+len(key):]
+			if idx = strings.Index(str, `"`); idx >= 0 {
+				return str[:idx]
+			}
+		}
+		return ""
+	}
+	if idx := strings.Index(str, " "); idx > 0 {
+		return str[:idx]
+	}
+	return str
+}
+
+// parseConnection parses connections, authentication, and replication events; returns false if not such a line
+func (ci *ConnectionsInfo) parseConnection(str string) bool {
+	if strings.HasPrefix(str, "{") {
+		return ci.parseConnectionv2(str)
+	}
+	if strings.Contains(str, " NETWORK ") {
+		if strings.Contains(str, "connection accepted from") {
+			if res := reTextConnAccepted.FindStringSubmatch(str); res != nil {
+				ci.addAccepted(getLogUTC(res[1]), res[2])
+				return true
+			}
+		} else if strings.Contains(str, "end connection") {
+			if res := reTextConnEnded.FindStringSubmatch(str); res != nil {
+				ci.addEnded(getLogUTC(res[1]))
+				return true
+			}
+		} else if strings.Contains(str, "received client metadata") {
+			if res := reTextDriver.FindStringSubmatch(str); res != nil {
+				ci.addDriver(res[1], res[2])
+			}
+			return true
+		}
+	} else if strings.Contains(str, " ACCESS ") {
+		if res := reTextAuthFailed.FindStringSubmatch(str); res != nil {
+			ci.addAuthFailure(res[2]+"@"+res[3], res[1])
+			return true
+		} else if res := reTextAuthFailedLegacy.FindStringSubmatch(str); res != nil {
+			ci.addAuthFailure(res[1], res[2])
+			return true
+		}
+	} else if strings.Contains(str, " REPL ") || strings.Contains(str, " ELECTION ") || strings.Contains(str, " ROLLBACK ") {
+		if res := reTextRepl.FindStringSubmatch(str); res != nil {
+			return ci.addReplEvent(res[1], res[2], res[3])
+		}
+	}
+	return false
+}
+
+// parseConnectionv2 parses logv2 connections, authentication, and replication events
+func (ci *ConnectionsInfo) parseConnectionv2(str string) bool {
+	if strings.Index(str, `"c":"NETWORK"`) < 0 && strings.Index(str, `"c":"ACCESS"`) < 0 &&
+		strings.Index(str, `"c":"REPL"`) < 0 && strings.Index(str, `"c":"ELECTION"`) < 0 &&
+		strings.Index(str, `"c":"ROLLBACK"`) < 0 {
+		return false
+	}
+	var doc struct {
+		Attributes struct {
+			Doc struct {
+				Driver struct {
+					Name    string `json:"name"`
+					Version string `json:"version"`
+				} `json:"driver"`
+			} `json:"doc"`
+			Mechanism     string `json:"mechanism"`
+			NewState      string `json:"newState"`
+			OldState      string `json:"oldState"`
+			PrincipalName string `json:"principalName"`
+			AuthDB        string `json:"authenticationDatabase"`
+			Remote        string `json:"remote"`
+		} `json:"attr"`
+		Component string            `json:"c"`
+		Message   string            `json:"msg"`
+		Timestamp map[string]string `json:"t"`
+	}
+	if err := json.Unmarshal([]byte(str), &doc); err != nil {
+		return false
+	}
+	utc := getLogUTC(doc.Timestamp["$date"])
+	attr := doc.Attributes
+	switch {
+	case doc.Message == "Connection accepted":
+		ci.addAccepted(utc, getIP(attr.Remote))
+	case doc.Message == "Connection ended":
+		ci.addEnded(utc)
+	case doc.Message == "client metadata":
+		ci.addDriver(attr.Doc.Driver.Name, attr.Doc.Driver.Version)
+	case doc.Message == "Authentication failed":
+		ci.addAuthFailure(attr.PrincipalName+"@"+attr.AuthDB, attr.Mechanism)
+	case doc.Component == "REPL" || doc.Component == "ELECTION" || doc.Component == "ROLLBACK":
+		message := doc.Message
+		if attr.NewState != "" {
+			message += ", transition to " + attr.NewState + " from " + attr.OldState
+		}
+		return ci.addReplEvent(doc.Timestamp["$date"], doc.Component, message)
+	default:
+		return false
+	}
+	return true
+}
+
+// getLogUTC returns the 10-minute bucket of a timestamp, same as LogStats.utc
+func getLogUTC(timestamp string) string {
+	if len(timestamp) < 15 {
+		return timestamp
+	}
+	return timestamp[:15] + `0:00Z`
+}
+
+func getIP(remote string) string {
+	if idx := strings.LastIndex(remote, ":"); idx > 0 {
+		return remote[:idx]
+	}
+	return remote
+}
+
+func (ci *ConnectionsInfo) addAccepted(utc string, ip string) {
+	ci.Accepted++
+	ci.clients[ip]++
+	hist := ci.hists[utc]
+	hist.UTC = utc
+	hist.Accepted++
+	ci.hists[utc] = hist
+}
+
+func (ci *ConnectionsInfo) addEnded(utc string) {
+	ci.Ended++
+	hist := ci.hists[utc]
+	hist.UTC = utc
+	hist.Ended++
+	ci.hists[utc] = hist
+}
+
+func (ci *ConnectionsInfo) addDriver(name string, version string) {
+	name = strings.Trim(name, `"`)
+	version = strings.Trim(version, `"`)
+	if name == "" {
+		return
+	}
+	key := name + " " + version
+	driver := ci.drivers[key]
+	driver.Name = name
+	driver.Version = version
+	driver.Count++
+	ci.drivers[key] = driver
+}
+
+func (ci *ConnectionsInfo) addAuthFailure(user string, mechanism string) {
+	key := user + " " + mechanism
+	failure := ci.authFailures[key]
+	failure.User = user
+	failure.Mechanism = mechanism
+	failure.Count++
+	ci.authFailures[key] = failure
+}
+
+// addReplEvent adds elections, stepdowns, and rollbacks; other replication messages are ignored.
+// Only a transition from PRIMARY to SECONDARY is a stepdown, not a startup or a resync.
+func (ci *ConnectionsInfo) addReplEvent(date string, component string, message string) bool {
+	msg := strings.ToLower(message)
+	eventType := ""
+	if component == "ROLLBACK" || strings.Index(msg, "rollback") >= 0 || strings.Index(msg, "rolling back") >= 0 {
+		eventType = "rollback"
+	} else if strings.Index(msg, "stepping down") >= 0 || strings.Index(msg, "stepdown") >= 0 ||
+		strings.Index(msg, "transition to secondary from primary") >= 0 {
+		eventType = "stepdown"
+	} else if strings.Index(msg, "election") >= 0 || strings.Index(msg, "transition to primary") >= 0 {
+		eventType = "election"
+	} else {
+		return false
+	}
+	if len(ci.ReplEvents) < maxReplEvents {
+		if len(message) > 120 {
+			message = message[:120]
+		}
+		ci.ReplEvents = append(ci.ReplEvents, ReplEvent{Date: date, Message: message, Type: eventType})
+	}
+	return true
+}
+
+// merge merges another ConnectionsInfo
+func (ci *ConnectionsInfo) merge(other *ConnectionsInfo) {
+	if other == nil {
+		return
+	}
+	ci.Accepted += other.Accepted
+	ci.Ended += other.Ended
+	for _, v := range other.Clients {
+		ci.clients[v.IP] += v.Count
+	}
+	for _, v := range other.Drivers {
+		key := v.Name + " " + v.Version
+		driver := ci.drivers[key]
+		driver.Name, driver.Version = v.Name, v.Version
+		driver.Count += v.Count
+		ci.drivers[key] = driver
+	}
+	for _, v := range other.AuthFailures {
+		key := v.User + " " + v.Mechanism
+		failure := ci.authFailures[key]
+		failure.User, failure.Mechanism = v.User, v.Mechanism
+		failure.Count += v.Count
+		ci.authFailures[key] = failure
+	}
+	for _, v := range other.AuditEvents {
+		ci.addAuditEvents(v)
+	}
+	for _, v := range other.Histogram {
+		hist := ci.hists[v.UTC]
+		hist.UTC = v.UTC
+		hist.Accepted += v.Accepted
+		hist.Ended += v.Ended
+		ci.hists[v.UTC] = hist
+	}
+	for _, event := range other.ReplEvents {
+		if len(ci.ReplEvents) >= maxReplEvents {
+			break
+		}
+		ci.ReplEvents = append(ci.ReplEvents, event)
+	}
+	sort.SliceStable(ci.ReplEvents, func(i, j int) bool { return ci.ReplEvents[i].Date < ci.ReplEvents[j].Date })
+	ci.finalize()
+}
+
+// combine adds counts and events parsed by another ConnectionsInfo, e.g. by a parser worker
+func (ci *ConnectionsInfo) combine(other *ConnectionsInfo) {
+	ci.Accepted += other.Accepted
+	ci.Ended += other.Ended
+	for k, v := range other.clients {
+		ci.clients[k] += v
+	}
+	for k, v := range other.drivers {
+		driver := ci.drivers[k]
+		driver.Name, driver.Version = v.Name, v.Version
+		driver.Count += v.Count
+		ci.drivers[k] = driver
+	}
+	for k, v := range other.authFailures {
+		failure := ci.authFailures[k]
+		failure.User, failure.Mechanism = v.User, v.Mechanism
+		failure.Count += v.Count
+		ci.authFailures[k] = failure
+	}
+	for _, v := range other.audits {
+		ci.addAuditEvents(v)
+	}
+	for k, v := range other.hists {
+		hist := ci.hists[k]
+		hist.UTC = v.UTC
+		hist.Accepted += v.Accepted
+		hist.Ended += v.Ended
+		ci.hists[k] = hist
+	}
+	for _, event := range other.ReplEvents {
+		if len(ci.ReplEvents) >= maxReplEvents {
+			break
+		}
+		ci.ReplEvents = append(ci.ReplEvents, event)
+	}
+}
+
+// load rebuilds counting maps from exported lists, e.g. after reading from a -log.bson.gz
+func (ci *ConnectionsInfo) load() {
+	other := *ci
+	*ci = *NewConnectionsInfo()
+	ci.merge(&other)
+}
+
+// finalize builds sorted lists from counting maps
+func (ci *ConnectionsInfo) finalize() {
+	ci.Clients = []ClientCount{}
+	for k, v := range ci.clients {
+		ci.Clients = append(ci.Clients, ClientCount{Count: v, IP: k})
+	}
+	sort.Slice(ci.Clients, func(i, j int) bool {
+		if ci.Clients[i].Count == ci.Clients[j].Count {
+			return ci.Clients[i].IP < ci.Clients[j].IP
+		}
+		return ci.Clients[i].Count > ci.Clients[j].Count
+	})
+	ci.Drivers = []DriverCount{}
+	for _, v := range ci.drivers {
+		ci.Drivers = append(ci.Drivers, v)
+	}
+	sort.Slice(ci.Drivers, func(i, j int) bool {
+		if ci.Drivers[i].Count == ci.Drivers[j].Count {
+			return ci.Drivers[i].Name+ci.Drivers[i].Version < ci.Drivers[j].Name+ci.Drivers[j].Version
+		}
+		return ci.Drivers[i].Count > ci.Drivers[j].Count
+	})
+	ci.AuthFailures = []AuthFailure{}
+	for _, v := range ci.authFailures {
+		ci.AuthFailures = append(ci.AuthFailures, v)
+	}
+	sort.Slice(ci.AuthFailures, func(i, j int) bool {
+		if ci.AuthFailures[i].Count == ci.AuthFailures[j].Count {
+			return ci.AuthFailures[i].User < ci.AuthFailures[j].User
+		}
+		return ci.AuthFailures[i].Count > ci.AuthFailures[j].Count
+	})
+	ci.AuditEvents = []AuditEvent{}
+	for _, v := range ci.audits {
+		ci.AuditEvents = append(ci.AuditEvents, v)
+	}
+	sort.Slice(ci.AuditEvents, func(i, j int) bool {
+		if ci.AuditEvents[i].Count == ci.AuditEvents[j].Count {
+			return getAuditKey(ci.AuditEvents[i]) < getAuditKey(ci.AuditEvents[j])
+		}
+		return ci.AuditEvents[i].Count > ci.AuditEvents[j].Count
+	})
+	ci.Histogram = []ConnsHistogram{}
+	for _, v := range ci.hists {
+		ci.Histogram = append(ci.Histogram, v)
+	}
+	sort.Slice(ci.Histogram, func(i, j int) bool { return ci.Histogram[i].UTC < ci.Histogram[j].UTC })
+}
+
+// isEmpty returns true if no connection, authentication, or replication event found
+func (ci *ConnectionsInfo) isEmpty() bool {
+	return ci == nil || (ci.Accepted == 0 && ci.Ended == 0 && len(ci.Drivers) == 0 &&
+		len(ci.AuthFailures) == 0 && len(ci.ReplEvents) == 0)
+}
+
+// printConnectionsSummary prints connections summary
+func (ci *ConnectionsInfo) printConnectionsSummary(nocolor bool) string {
+	red := codeRed
+	tail := codeDefault
+	if nocolor {
+		red = ""
+		tail = ""
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("\nConnections: %d accepted, %d ended\n", ci.Accepted, ci.Ended))
+	if len(ci.Histogram) > 0 {
+		buffer.WriteString("+----------------------+----------+----------+\n")
+		buffer.WriteString("| Time (10 minutes)    | Accepted |  Ended   |\n")
+		buffer.WriteString("|----------------------+----------+----------|\n")
+		for _, v := range ci.Histogram {
+			buffer.WriteString(fmt.Sprintf("| %-20s | %8d | %8d |\n", v.UTC, v.Accepted, v.Ended))
+		}
+		buffer.WriteString("+----------------------+----------+----------+\n")
+	}
+	if len(ci.Clients) > 0 {
+		buffer.WriteString("Top client IPs:\n")
+		for i, v := range ci.Clients {
+			if i >= topN {
+				break
+			}
+			buffer.WriteString(fmt.Sprintf("  %-40s %8d\n", v.IP, v.Count))
+		}
+	}
+	if len(ci.Drivers) > 0 {
+		buffer.WriteString("Drivers:\n")
+		for _, v := range ci.Drivers {
+			buffer.WriteString(fmt.Sprintf("  %-40s %8d\n", v.Name+" "+v.Version, v.Count))
+		}
+	}
+	if len(ci.AuthFailures) > 0 {
+		buffer.WriteString("Authentication failures:\n")
+		for _, v := range ci.AuthFailures {
+			buffer.WriteString(fmt.Sprintf("  %v%-40s %-16s %8d%v\n", red, v.User, v.Mechanism, v.Count, tail))
+		}
+	}
+	if len(ci.ReplEvents) > 0 {
+		buffer.WriteString("Replication events:\n")
+		for _, v := range ci.ReplEvents {
+			buffer.WriteString(fmt.Sprintf("  %-29s %-8s %v\n", v.Date, v.Type, v.Message))
+		}
+	}
+	return buffer.String()
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseConnection(t *testing.T) {
+	lines := []string{
+		`2020-05-12T12:48:14.398-0400 I  NETWORK  [listener] connection accepted from 10.0.0.1:50036 #1 (1 connection now open)`,
+		`2020-05-12T12:48:14.399-0400 I  NETWORK  [conn1] received client metadata from 10.0.0.1:50036 conn1: { driver: { name: "nodejs", version: "3.6.0" }, os: { type: "Darwin" } }`,
+		`2020-05-12T12:48:15.001-0400 I  ACCESS   [conn1] SASL SCRAM-SHA-256 authentication failed for root on admin from client 10.0.0.1:50036 ; AuthenticationFailed: SCRAM authentication failed, storedKey mismatch`,
+		`2020-05-12T12:58:15.001-0400 I  NETWORK  [conn1] end connection 10.0.0.1:50036 (0 connections now open)`,
+		`2020-05-12T12:59:15.001-0400 I  REPL     [replexec-0] transition to PRIMARY from SECONDARY`,
+		`2020-05-12T12:59:15.002-0400 I  REPL     [replexec-0] Member is now in state SECONDARY`,
+		`{"t":{"$date":"2020-05-12T17:10:01.100+00:00"},"s":"I","c":"NETWORK","id":22943,"ctx":"listener","msg":"Connection accepted","attr":{"remote":"10.0.0.2:50100","connectionId":2,"connectionCount":1}}`,
+		`{"t":{"$date":"2020-05-12T17:10:01.101+00:00"},"s":"I","c":"NETWORK","id":51800,"ctx":"conn2","msg":"client metadata","attr":{"remote":"10.0.0.2:50100","client":"conn2","doc":{"driver":{"name":"mongo-go-driver","version":"v1.3.7"}}}}`,
+		`{"t":{"$date":"2020-05-12T17:10:02.000+00:00"},"s":"I","c":"ACCESS","id":20249,"ctx":"conn2","msg":"Authentication failed","attr":{"mechanism":"SCRAM-SHA-256","principalName":"root","authenticationDatabase":"admin","client":"10.0.0.2:50100","result":"UserNotFound"}}`,
+		`{"t":{"$date":"2020-05-12T17:10:03.000+00:00"},"s":"I","c":"REPL","id":21358,"ctx":"ReplCoord-0","msg":"Stepping down from primary","attr":{}}`,
+		`{"t":{"$date":"2020-05-12T17:10:04.000+00:00"},"s":"I","c":"ROLLBACK","id":21606,"ctx":"BackgroundSync","msg":"Finding common point","attr":{}}`,
+		`2020-05-12T13:20:00.000-0400 I  REPL     [replexec-0] transition to SECONDARY from STARTUP2`,
+		`2020-05-12T13:21:00.000-0400 I  REPL     [replexec-0] transition to SECONDARY from PRIMARY`,
+		`{"t":{"$date":"2020-05-12T17:30:00.000+00:00"},"s":"I","c":"REPL","id":21358,"ctx":"ReplCoord-0","msg":"Replica set state transition","attr":{"newState":"SECONDARY","oldState":"STARTUP2"}}`,
+		`{"t":{"$date":"2020-05-12T17:31:00.000+00:00"},"s":"I","c":"REPL","id":21358,"ctx":"ReplCoord-0","msg":"Replica set state transition","attr":{"newState":"SECONDARY","oldState":"PRIMARY"}}`,
+	}
+	li := NewLogInfo("test")
+	li.SetSilent(true)
+	if err := li.Parse(bufio.NewReader(strings.NewReader(strings.Join(lines, "\n")))); err != nil {
+		t.Fatal(err)
+	}
+	ci := li.Connections
+	if ci.Accepted != 2 || ci.Ended != 1 {
+		t.Fatal("accepted", ci.Accepted, "ended", ci.Ended)
+	}
+	if len(ci.Clients) != 2 || len(ci.Histogram) != 3 {
+		t.Fatal(ci.Clients, ci.Histogram)
+	}
+	if len(ci.Drivers) != 2 || ci.Drivers[0].Name != "mongo-go-driver" || ci.Drivers[1].Version != "3.6.0" {
+		t.Fatal(ci.Drivers)
+	}
+	if len(ci.AuthFailures) != 1 || ci.AuthFailures[0].Count != 2 || ci.AuthFailures[0].User != "root@admin" {
+		t.Fatal(ci.AuthFailures)
+	}
+	if len(ci.ReplEvents) != 5 || ci.ReplEvents[0].Type != "election" ||
+		ci.ReplEvents[1].Type != "stepdown" || ci.ReplEvents[2].Type != "rollback" ||
+		ci.ReplEvents[3].Type != "stepdown" || ci.ReplEvents[4].Type != "stepdown" {
+		t.Fatal(ci.ReplEvents)
+	}
+
+	data, err := bson.Marshal(li)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewLogInfo("test")
+	if err = bson.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.Connections.load()
+	loaded.Merge(li)
+	if loaded.Connections.Accepted != 4 || loaded.Connections.AuthFailures[0].Count != 4 || len(loaded.Connections.Clients) != 2 {
+		t.Fatal(loaded.Connections)
+	}
+	t.Log(loaded.Connections.printConnectionsSummary(true))
+}
+
+func TestMergeReplEvents(t *testing.T) {
+	ci := NewConnectionsInfo()
+	other := NewConnectionsInfo()
+	for i := 0; i < maxReplEvents; i++ {
+		ci.addReplEvent("2020-05-12T12:00:00.000Z", "REPL", "Stepping down from primary")
+		other.addReplEvent("2020-05-12T13:00:00.000Z", "REPL", "Stepping down from primary")
+	}
+	ci.merge(other)
+	if len(ci.ReplEvents) != maxReplEvents {
+		t.Fatal(len(ci.ReplEvents))
+	}
+}
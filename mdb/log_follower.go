@@ -0,0 +1,152 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+const followPollInterval = 500 * time.Millisecond
+
+// logTailer reads lines appended to a log file and survives log rotation and truncation
+type logTailer struct {
+	filename string
+	file     *os.File
+	offset   int64
+	partial  string
+}
+
+// newLogTailer opens a log file for tailing, starting from the end unless fromStart is set
+func newLogTailer(filename string, fromStart bool) (*logTailer, error) {
+	var err error
+	tailer := logTailer{filename: filename}
+	if tailer.file, err = os.Open(filename); err != nil {
+		return nil, err
+	}
+	if fromStart == false {
+		if tailer.offset, err = tailer.file.Seek(0, io.SeekEnd); err != nil {
+			tailer.file.Close()
+			return nil, err
+		}
+	}
+	return &tailer, nil
+}
+
+// readLines returns complete lines appended since the last read
+func (t *logTailer) readLines() ([]string, error) {
+	var err error
+	var lines []string
+	var fi, cur os.FileInfo
+	if cur, err = t.file.Stat(); err != nil {
+		return lines, err
+	}
+	if fi, err = os.Stat(t.filename); err != nil { // rotated, new file not created yet
+		return t.read()
+	}
+	if os.SameFile(fi, cur) == false { // rotated, drain the old file then switch
+		if lines, err = t.read(); err != nil {
+			return lines, err
+		}
+		var file *os.File
+		if file, err = os.Open(t.filename); err != nil {
+			return lines, err
+		}
+		t.file.Close()
+		t.file = file
+		t.offset = 0
+		t.partial = ""
+	} else if cur.Size() < t.offset { // truncated
+		t.offset = 0
+		t.partial = ""
+	}
+	var more []string
+	more, err = t.read()
+	return append(lines, more...), err
+}
+
+// read reads from the current offset to the end of the file
+func (t *logTailer) read() ([]string, error) {
+	var err error
+	var lines []string
+	var n int
+	buf := make([]byte, 64*1024)
+	for {
+		if n, err = t.file.ReadAt(buf, t.offset); n > 0 {
+			t.offset += int64(n)
+			t.partial += string(buf[:n])
+		}
+		if err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			break
+		}
+	}
+	if idx := strings.LastIndex(t.partial, "\n"); idx >= 0 {
+		for _, line := range strings.Split(t.partial[:idx], "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		t.partial = t.partial[idx+1:]
+	}
+	return lines, err
+}
+
+// close closes the tailed file
+func (t *logTailer) close() {
+	t.file.Close()
+}
+
+// Follow tails a growing log file and refreshes the summary until interrupted
+func (li *LogInfo) Follow(filename string) error {
+	var err error
+	var tailer *logTailer
+	if strings.HasSuffix(filename, ".gz") {
+		return errors.New("cannot follow a compressed file " + filename)
+	}
+	li.filename = filename
+	if tailer, err = newLogTailer(filename, false); err != nil {
+		return err
+	}
+	defer tailer.close()
+	li.reset()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	poll := time.NewTicker(followPollInterval)
+	defer poll.Stop()
+	refresh := time.NewTicker(time.Duration(li.refresh) * time.Second)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-interrupt:
+			li.Histogram = append(li.Histogram, li.hist)
+			li.OpPatterns = li.getOpPatterns()
+			li.Connections.finalize()
+			return nil
+		case <-refresh.C:
+			li.OpPatterns = li.getOpPatterns()
+			li.Connections.finalize()
+			if li.silent == false {
+				fmt.Print("\x1b[H\x1b[2J")
+			}
+			fmt.Printf("%v following %v, refreshed every %d seconds\n", time.Now().Format(time.RFC3339), filename, li.refresh)
+			li.Print()
+		case <-poll.C:
+			var lines []string
+			if lines, err = tailer.readLines(); err != nil {
+				return err
+			}
+			for _, line := range lines {
+				li.parseLine(line)
+			}
+		}
+	}
+}
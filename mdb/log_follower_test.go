@@ -0,0 +1,51 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogTailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keyhole")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "mongod.log")
+	ioutil.WriteFile(filename, []byte("old line\n"), 0644)
+	tailer, err := newLogTailer(filename, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tailer.close()
+
+	file, _ := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString("line 1\nline ")
+	file.Close()
+	lines, err := tailer.readLines()
+	if err != nil || len(lines) != 1 || lines[0] != "line 1" {
+		t.Fatal(lines, err)
+	}
+
+	file, _ = os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString("2\n")
+	file.Close()
+	if lines, err = tailer.readLines(); err != nil || len(lines) != 1 || lines[0] != "line 2" {
+		t.Fatal(lines, err)
+	}
+
+	os.Rename(filename, filename+".1") // rotated
+	ioutil.WriteFile(filename, []byte("line 3\n"), 0644)
+	if lines, err = tailer.readLines(); err != nil || len(lines) != 1 || lines[0] != "line 3" {
+		t.Fatal(lines, err)
+	}
+
+	ioutil.WriteFile(filename, []byte("4\n"), 0644) // truncated
+	if lines, err = tailer.readLines(); err != nil || len(lines) != 1 || lines[0] != "4" {
+		t.Fatal(lines, err)
+	}
+}
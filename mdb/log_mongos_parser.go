@@ -0,0 +1,88 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mongosParser parses slow ops of mongos, in text or logv2
+type mongosParser struct{}
+
+var reNShards = regexp.MustCompile(`"?nShards"?:\s*(\d+)`)
+var reRemoteOpWait = regexp.MustCompile(`"?remoteOpWaitMillis"?:\s*(\d+)`)
+var reShardSide = regexp.MustCompile(`"?(locks|planSummary)"?:\s*`)
+
+// Detect returns true if a line targets shards and is not of a shard, mongos acquires no locks and has
+// no plan, whereas a shard dispatching to other shards, e.g. of $lookup, also logs nShards
+func (p *mongosParser) Detect(str string) bool {
+	return strings.Contains(str, "nShards") && reShardSide.MatchString(str) == false
+}
+
+func (p *mongosParser) Name() string {
+	return "mongos"
+}
+
+func (p *mongosParser) Parse(li *LogInfo, str string) (LogStats, error) {
+	var err error
+	var stat LogStats
+	if strings.HasPrefix(str, "{") {
+		stat, err = li.ParseLogv2(str)
+	} else {
+		stat, err = li.ParseLog(str)
+	}
+	if err != nil || stat.op == "" || stat.op == dollarCmd {
+		return stat, err
+	}
+	getMongosMetrics(str, &stat)
+	return stat, err
+}
+
+// getMongosMetrics gets number of shards targeted, time waiting for shards, and where results are merged
+func getMongosMetrics(str string, stat *LogStats) {
+	if res := reNShards.FindStringSubmatch(str); res != nil {
+		stat.nShards, _ = strconv.Atoi(res[1])
+		if stat.nShards > 1 {
+			stat.merger = "mongos"
+		}
+	}
+	if res := reRemoteOpWait.FindStringSubmatch(str); res != nil {
+		stat.remoteOpWaitMillis, _ = strconv.Atoi(res[1])
+	}
+}
+
+// getShardMerger sets merger of a shard line, merging part of a split pipeline runs on a shard
+func getShardMerger(str string, stat *LogStats) {
+	if strings.Contains(str, "$mergeCursors") {
+		stat.merger = "shard"
+	}
+}
+
+// getShardedSummary returns shards targeted, merger, and time waiting for shards,
+// true if most of the time is spent waiting for shards
+func getShardedSummary(op OpPattern) (string, bool) {
+	if op.NShards == 0 && op.Merger == "" && op.RemoteOpWaitMillis == 0 {
+		return "", false
+	}
+	summaries := []string{}
+	if op.NShards > 0 {
+		summaries = append(summaries, fmt.Sprintf("nShards %d", op.NShards))
+	}
+	if op.Merger != "" {
+		summaries = append(summaries, "merged on "+op.Merger)
+	}
+	isHigh := false
+	if op.RemoteOpWaitMillis > 0 && op.Count > 0 {
+		ratio := 0.0
+		if op.TotalMilli > 0 {
+			ratio = float64(op.RemoteOpWaitMillis) / float64(op.TotalMilli)
+		}
+		isHigh = ratio > .5
+		summaries = append(summaries, fmt.Sprintf("remote wait %.1fms/op (%.0f%%)",
+			float64(op.RemoteOpWaitMillis)/float64(op.Count), 100*ratio))
+	}
+	return strings.Join(summaries, ", "), isHigh
+}
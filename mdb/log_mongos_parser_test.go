@@ -0,0 +1,64 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestGetLogParser(t *testing.T) {
+	lines := map[string]string{
+		`2020-05-12T12:48:14.398-0400 I  COMMAND  [conn1] command test.c command: find { find: "c", filter: { a: 1 } } planSummary: COLLSCAN 120ms`:                                        "text",
+		`{"t":{"$date":"2020-05-12T17:10:01.100+00:00"},"s":"I","c":"COMMAND","msg":"Slow query","attr":{"durationMillis":120}}`:                                                           "logv2",
+		`{"t":{"$date":"2020-05-12T17:10:01.100+00:00"},"s":"I","c":"COMMAND","msg":"Slow query","attr":{"nShards":2,"durationMillis":120}}`:                                               "mongos",
+		`{ "atype" : "authCheck", "ts" : { "$date" : "2020-05-12T17:10:01.100+00:00" } }`:                                                                                                  "audit",
+		`{"t":{"$date":"2020-05-12T17:10:01.100+00:00"},"s":"I","c":"COMMAND","msg":"Slow query","attr":{"nShards":2,"locks":{},"durationMillis":120}}`:                                    "logv2",
+		`2020-05-12T12:48:14.398-0400 I  COMMAND  [conn1] command test.c command: aggregate { aggregate: "c", pipeline: [ { $mergeCursors: {} } ] } planSummary: COLLSCAN nShards:2 120ms`: "text",
+	}
+	for line, name := range lines {
+		if parser := GetLogParser(line); parser == nil || parser.Name() != name {
+			t.Fatal(name, parser)
+		}
+	}
+}
+
+func TestMongosParser(t *testing.T) {
+	lines := []string{
+		`{"t":{"$date":"2020-05-12T17:10:01.100+00:00"},"s":"I","c":"NETWORK","id":4615611,"ctx":"initandlisten","msg":"mongos started","attr":{}}`,
+		`{"t":{"$date":"2020-05-12T17:10:01.100+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"test.orders","command":{"find":"orders","filter":{"status":"A"},"$db":"test"},"nShards":3,"nreturned":5,"remoteOpWaitMillis":90,"durationMillis":100}}`,
+		`{"t":{"$date":"2020-05-12T17:10:02.100+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"test.orders","command":{"find":"orders","filter":{"status":"B"},"$db":"test"},"nShards":2,"nreturned":5,"remoteOpWaitMillis":50,"durationMillis":100}}`,
+		`2020-05-12T12:48:14.398-0400 I  COMMAND  [conn1] command test.orders command: find { find: "orders", filter: { status: "C" }, $db: "test" } nShards:2 cursorExhausted:1 numYields:0 nreturned:5 reslen:300 protocol:op_msg 100ms`,
+	}
+	li := NewLogInfo("test")
+	li.SetSilent(true)
+	if err := li.Parse(bufio.NewReader(strings.NewReader(strings.Join(lines, "\n")))); err != nil {
+		t.Fatal(err)
+	}
+	if li.LogType != "logv2" || len(li.OpPatterns) == 0 {
+		t.Fatal(li.LogType, li.OpPatterns)
+	}
+	var op OpPattern
+	for _, v := range li.OpPatterns {
+		if v.Command == cmdFind && v.Count == 2 {
+			op = v
+		}
+	}
+	if op.NShards != 3 || op.RemoteOpWaitMillis != 140 || op.Merger != "mongos" {
+		t.Fatal(li.OpPatterns)
+	}
+	if summary, isHigh := getShardedSummary(op); isHigh == false || summary != "nShards 3, merged on mongos, remote wait 70.0ms/op (70%)" {
+		t.Fatal(summary, isHigh)
+	}
+
+	var stat LogStats
+	getMongosMetrics(lines[3], &stat)
+	if stat.nShards != 2 || stat.merger != "mongos" {
+		t.Fatal(stat)
+	}
+	getShardMerger(`{"attr":{"command":{"aggregate":"orders","pipeline":[{"$mergeCursors":{}}]}}}`, &stat)
+	if stat.merger != "shard" {
+		t.Fatal(stat)
+	}
+}
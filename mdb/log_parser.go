@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/simagix/gox"
 )
@@ -27,6 +28,14 @@ func (li *LogInfo) ParseLog(str string) (LogStats, error) {
 			return stat, err
 		}
 		result := matched.FindStringSubmatch(str)
+		if li.from.IsZero() == false || li.to.IsZero() == false {
+			var t time.Time
+			if t, err = parseLogTime(result[1]); err != nil {
+				return stat, err
+			} else if li.isInTimeRange(t) == false {
+				return stat, errors.New("out of time range")
+			}
+		}
 		c := result[2]
 		if c != "COMMAND" && c != "QUERY" && c != "WRITE" {
 			return stat, errors.New("unsupported command")
@@ -221,11 +230,57 @@ func (li *LogInfo) ParseLog(str string) (LogStats, error) {
 		filter += aggStages
 		milli, _ := strconv.Atoi(ms)
 		stat = LogStats{filter: filter, index: index, milli: milli, ns: ns, op: op, scan: scan, utc: utc}
+		getLogMetrics(str, &stat)
 		return stat, nil
 	}
 	return stat, errors.New("unrecognized log")
 }
 
+var reLogMetric = regexp.MustCompile(`\b(keysExamined|docsExamined|numYields|nreturned|nMatched|ndeleted|planCacheKey|queryHash|bytesRead|timeReadingMicros):\s?(\w+)`)
+var reLockWait = regexp.MustCompile(`timeAcquiringMicros: { ([^}]*) }`)
+
+// getLogMetrics gets examined, returned, yields, lock waits, storage, and plan cache info from a text log
+func getLogMetrics(str string, stat *LogStats) {
+	for _, res := range reLogMetric.FindAllStringSubmatch(str, -1) {
+		n, _ := strconv.Atoi(res[2])
+		switch res[1] {
+		case "keysExamined":
+			stat.keysExamined = n
+		case "docsExamined":
+			stat.docsExamined = n
+		case "numYields":
+			stat.numYields = n
+		case "nreturned", "nMatched", "ndeleted":
+			stat.nreturned += n
+		case "planCacheKey":
+			stat.planCacheKey = res[2]
+		case "queryHash":
+			stat.queryHash = res[2]
+		case "bytesRead":
+			stat.bytesRead = n
+		case "timeReadingMicros":
+			stat.readMicros = n
+		}
+	}
+	for _, res := range reLockWait.FindAllStringSubmatch(str, -1) {
+		for _, kv := range strings.Split(res[1], ",") {
+			if tokens := strings.Split(kv, ":"); len(tokens) == 2 {
+				n, _ := strconv.Atoi(strings.TrimSpace(tokens[1]))
+				stat.lockWaitMicros += n
+			}
+		}
+	}
+}
+
+// parseLogTime parses timestamps of text logs, e.g. 2020-05-12T12:48:14.398-0400
+func parseLogTime(str string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02T15:04:05.000-0700", str)
+	if err != nil {
+		return time.Parse(time.RFC3339, str)
+	}
+	return t, err
+}
+
 func getDocByField(str string, key string) string {
 	ml := gox.NewMongoLog(str)
 	return ml.Get(key)
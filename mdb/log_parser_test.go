@@ -3,6 +3,8 @@
 package mdb
 
 import (
+	"bufio"
+	"strings"
 	"testing"
 )
 
@@ -23,3 +25,38 @@ func TestRemoveInElements(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestParseLogTimeRange(t *testing.T) {
+	str := `2018-05-18T21:55:08.926+0000 I COMMAND  [conn3709635] command taterstore.recentlyWatched command: find { find: "recentlyWatched", filter: { tveUserId: 16114978 } } planSummary: IXSCAN { tveUserId: 1 } keysExamined:29 docsExamined:29 protocol:op_command 139ms`
+	loginfo := NewLogInfo("utest-xxxxxx")
+	loginfo.SetTimeRange("2018-05-18T22:00:00Z", "")
+	if _, err := loginfo.ParseLog(str); err == nil {
+		t.Fatal("expected out of time range")
+	}
+	loginfo.SetTimeRange("", "2018-05-18")
+	if _, err := loginfo.ParseLog(str); err != nil {
+		t.Fatal(err)
+	}
+	if err := loginfo.Parse(bufio.NewReader(strings.NewReader(str))); err != nil {
+		t.Fatal(err)
+	}
+	ctime := `Fri May 18 21:55:08.926 I COMMAND  [conn3709635] command taterstore.recentlyWatched command: find { find: "recentlyWatched" } 139ms`
+	err := loginfo.Parse(bufio.NewReader(strings.NewReader(ctime)))
+	if err == nil || strings.Contains(err.Error(), "unsupported timestamp format for --from/--to") == false {
+		t.Fatal(err)
+	}
+	loginfo.SetTimeRange("", "")
+	if err = loginfo.Parse(bufio.NewReader(strings.NewReader(ctime))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetLogMetrics(t *testing.T) {
+	str := `2020-03-30T09:18:46.696-0400 I  WRITE    [conn487] remove _KEYHOLE_88800.examples command: { q: { _search: "38334d64ad93602d" }, limit: 0 } planSummary: COLLSCAN keysExamined:0 docsExamined:1200 ndeleted:2 numYields:3 queryHash:4B53BE76 planCacheKey:DA8BAFF8 locks:{ Collection: { acquireCount: { w: 1 }, acquireWaitCount: { w: 1 }, timeAcquiringMicros: { w: 148447 } } } storage:{ data: { bytesRead: 1024, timeReadingMicros: 12 } } 148ms`
+	var stat LogStats
+	getLogMetrics(str, &stat)
+	if stat.docsExamined != 1200 || stat.nreturned != 2 || stat.numYields != 3 || stat.lockWaitMicros != 148447 ||
+		stat.bytesRead != 1024 || stat.readMicros != 12 || stat.queryHash != "4B53BE76" || stat.planCacheKey != "DA8BAFF8" {
+		t.Fatal(stat)
+	}
+}
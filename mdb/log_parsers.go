@@ -0,0 +1,72 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+)
+
+// LogParser parses lines of a log format into LogStats
+type LogParser interface {
+	Detect(str string) bool                          // returns true if a line is of the format
+	Name() string                                    // log type, e.g. text or logv2
+	Parse(li *LogInfo, str string) (LogStats, error) // parses a line
+}
+
+var logParsers = []LogParser{}
+
+// RegisterLogParser registers a log parser, parsers registered later are detected first
+func RegisterLogParser(parser LogParser) {
+	logParsers = append([]LogParser{parser}, logParsers...)
+}
+
+// GetLogParser returns the parser of a line, or nil if none detected
+func GetLogParser(str string) LogParser {
+	for _, parser := range logParsers {
+		if parser.Detect(str) == true {
+			return parser
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterLogParser(&textParser{})
+	RegisterLogParser(&logv2Parser{})
+	RegisterLogParser(&auditParser{})
+	RegisterLogParser(&mongosParser{})
+}
+
+// textParser parses text logs before v4.4
+type textParser struct{}
+
+func (p *textParser) Detect(str string) bool {
+	return strings.HasPrefix(str, "{") == false
+}
+
+func (p *textParser) Name() string {
+	return "text"
+}
+
+func (p *textParser) Parse(li *LogInfo, str string) (LogStats, error) {
+	stat, err := li.ParseLog(str)
+	getShardMerger(str, &stat)
+	return stat, err
+}
+
+// logv2Parser parses logv2 from v4.4
+type logv2Parser struct{}
+
+func (p *logv2Parser) Detect(str string) bool {
+	return strings.HasPrefix(str, "{") && strings.HasSuffix(str, "}")
+}
+
+func (p *logv2Parser) Name() string {
+	return "logv2"
+}
+
+func (p *logv2Parser) Parse(li *LogInfo, str string) (LogStats, error) {
+	stat, err := li.ParseLogv2(str)
+	getShardMerger(str, &stat)
+	return stat, err
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// logBatchSize is the number of lines a parser worker parses at a time
+const logBatchSize = 1000
+
+// logBatch is a batch of lines and their parsed results
+type logBatch struct {
+	conns  *ConnectionsInfo
+	index  int
+	lines  []string
+	parsed []parsedLine
+}
+
+// fileProgress reports the offset of a file being read, compressed or not
+type fileProgress struct {
+	file *os.File
+	size int64
+}
+
+func (p *fileProgress) percent() int {
+	offset, err := p.file.Seek(0, io.SeekCurrent)
+	if err != nil || p.size <= 0 {
+		return 0
+	}
+	return int(100 * offset / p.size)
+}
+
+// printProgress prints the percentage of lines, or of bytes if lines are not counted
+func (li *LogInfo) printProgress(index int, lineCounts int) {
+	if li.silent == true {
+		return
+	}
+	if lineCounts > 0 {
+		fmt.Fprintf(os.Stderr, "\r%3d%% \r", (100*index)/lineCounts)
+	} else if li.progress != nil {
+		fmt.Fprintf(os.Stderr, "\r%3d%% \r", li.progress.percent())
+	}
+}
+
+// readLogLine reads a line, joining a line longer than the buffer
+func readLogLine(reader *bufio.Reader) (string, error) {
+	buf, isPrefix, err := reader.ReadLine() // 0x0A separator = newline
+	if err != nil {
+		return "", err
+	}
+	str := string(buf)
+	for isPrefix == true {
+		var bbuf []byte
+		if bbuf, isPrefix, err = reader.ReadLine(); err != nil {
+			break
+		}
+		str += string(bbuf)
+	}
+	return str, nil
+}
+
+// parseParallel parses lines with a reader goroutine and parser workers, and aggregates
+// stats in the order of lines so that results are the same as parsing sequentially
+func (li *LogInfo) parseParallel(reader *bufio.Reader, lineCounts int) {
+	batches := make(chan *logBatch, li.workers)
+	results := make(chan *logBatch, li.workers)
+	inflight := make(chan struct{}, 4*li.workers) // bounds batches waiting to be aggregated
+	template := *li                               // read-only settings for parsers
+
+	go func() { // reader
+		defer close(batches)
+		index := 0
+		count := 0
+		lines := make([]string, 0, logBatchSize)
+		for {
+			str, err := readLogLine(reader)
+			if err == nil {
+				count++
+				if len(str) > 0 {
+					lines = append(lines, str)
+				}
+			}
+			if len(lines) == logBatchSize || (err != nil && len(lines) > 0) {
+				inflight <- struct{}{}
+				batches <- &logBatch{index: index, lines: lines}
+				li.printProgress(count, lineCounts)
+				index++
+				lines = make([]string, 0, logBatchSize)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < li.workers; i++ {
+		wg.Add(1)
+		go func() { // parser
+			defer wg.Done()
+			for batch := range batches {
+				worker := template
+				if worker.Connections != nil {
+					worker.Connections = NewConnectionsInfo()
+				}
+				batch.parsed = make([]parsedLine, len(batch.lines))
+				for n, str := range batch.lines {
+					batch.parsed[n] = worker.parse(str)
+				}
+				batch.conns = worker.Connections
+				batch.lines = nil
+				results <- batch
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := map[int]*logBatch{} // reducer
+	next := 0
+	for batch := range results {
+		pending[batch.index] = batch
+		for batch, ok := pending[next]; ok == true; batch, ok = pending[next] {
+			delete(pending, next)
+			for _, line := range batch.parsed {
+				li.apply(line)
+			}
+			if batch.conns != nil {
+				li.Connections.combine(batch.conns)
+			}
+			<-inflight
+			next++
+		}
+	}
+}
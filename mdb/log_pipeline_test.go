@@ -0,0 +1,56 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func getTestLogLines(n int) string {
+	lines := []string{}
+	for i := 0; i < n; i++ {
+		ts := fmt.Sprintf("2020-09-28T%02d:%02d:%02d.000+00:00", 10+(i/3000)%10, (i/50)%60, i%60)
+		switch i % 7 {
+		case 0:
+			lines = append(lines, fmt.Sprintf(`{"t":{"$date":"%v"},"s":"I","c":"NETWORK","id":22943,"ctx":"listener","msg":"Connection accepted","attr":{"remote":"10.0.0.%d:5000","connectionId":%d,"connectionCount":1}}`, ts, i%5, i))
+		case 1:
+			lines = append(lines, fmt.Sprintf(`{"t":{"$date":"%v"},"s":"I","c":"REPL","id":21358,"ctx":"ReplCoord-0","msg":"Stepping down from primary %d","attr":{}}`, ts, i))
+		case 2:
+			lines = append(lines, "")
+		default:
+			lines = append(lines, fmt.Sprintf(`{"t":{"$date":"%v"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"keyhole.cars%d","command":{"find":"cars","filter":{"color":"Red","f%d":1},"$db":"keyhole"},"planSummary":"COLLSCAN","docsExamined":%d,"nreturned":1,"durationMillis":%d}}`, ts, i%3, i%11, i, i%997))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestParseParallel(t *testing.T) {
+	logs := getTestLogLines(10 * logBatchSize)
+	results := [][]byte{}
+	for _, workers := range []int{1, 4} {
+		li := NewLogInfo("test")
+		li.SetSilent(true)
+		li.SetWorkers(workers)
+		if err := li.Parse(bufio.NewReader(strings.NewReader(logs))); err != nil {
+			t.Fatal(err)
+		}
+		li.Logger = nil
+		data, err := bson.Marshal(li)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(li.OpPatterns) != 33 || li.Connections.Accepted == 0 || len(li.Connections.ReplEvents) != maxReplEvents {
+			t.Fatal(len(li.OpPatterns), li.Connections.Accepted, len(li.Connections.ReplEvents))
+		}
+		results = append(results, data)
+	}
+	if bytes.Equal(results[0], results[1]) == false {
+		t.Fatal("parallel parsing results differ from sequential parsing")
+	}
+}
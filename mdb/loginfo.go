@@ -6,13 +6,16 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 
@@ -24,31 +27,70 @@ const COLLSCAN = "COLLSCAN"
 
 // LogInfo keeps loginfo struct
 type LogInfo struct {
-	Collscan   bool        `bson:"collscan"`
-	Histogram  []Histogram `bson:"histogram"`
-	Logger     *Logger     `bson:"keyhole"`
-	LogType    string      `bson:"type"`
-	Regex      string      `bson:"regex"`
-	OpPatterns []OpPattern `bson:"opPatterns"`
-	Redaction  bool        `bson:"redact"`
-	SlowOps    []SlowOps   `bson:"slowOps"`
+	Collscan    bool             `bson:"collscan"`
+	Connections *ConnectionsInfo `bson:"connections"`
+	Histogram   []Histogram      `bson:"histogram"`
+	Logger      *Logger          `bson:"keyhole"`
+	LogType     string           `bson:"type"`
+	Regex       string           `bson:"regex"`
+	OpPatterns  []OpPattern      `bson:"opPatterns"`
+	Redaction   bool             `bson:"redact"`
+	SlowOps     []SlowOps        `bson:"slowOps"`
 
 	filename string
+	format   string
+	from     time.Time
+	groupBy  string
+	hist     Histogram
+	opsMap   map[string]OpPattern
+	progress *fileProgress
+	refresh  int
 	regex    string
 	silent   bool
+	sortBy   string
+	to       time.Time
+	ts       string
 	verbose  bool
+	workers  int
 }
 
 // OpPattern stores performance data
 type OpPattern struct {
-	Command    string `bson:"command"`    // count, delete, find, remove, and update
-	Count      int    `bson:"count"`      // number of ops
-	Filter     string `bson:"filter"`     // query pattern
-	MaxMilli   int    `bson:"maxmilli"`   // max millisecond
-	Namespace  string `bson:"ns"`         // database.collectin
-	Scan       string `bson:"scan"`       // COLLSCAN
-	TotalMilli int    `bson:"totalmilli"` // total milliseconds
-	Index      string `bson:"index"`      // index used
+	Command    string         `bson:"command"`    // count, delete, find, remove, and update
+	Count      int            `bson:"count"`      // number of ops
+	Filter     string         `bson:"filter"`     // query pattern
+	MaxMilli   int            `bson:"maxmilli"`   // max millisecond
+	Namespace  string         `bson:"ns"`         // database.collectin
+	Scan       string         `bson:"scan"`       // COLLSCAN
+	TotalMilli int            `bson:"totalmilli"` // total milliseconds
+	Index      string         `bson:"index"`      // index used
+	Latency    *LatencySketch `bson:"latency"`    // latency distribution
+	P50        int            `bson:"p50"`        // median milliseconds
+	P95        int            `bson:"p95"`        // 95th percentile milliseconds
+	P99        int            `bson:"p99"`        // 99th percentile milliseconds
+
+	BytesRead      int    `bson:"bytesRead"`      // total storage bytes read
+	DocsExamined   int    `bson:"docsExamined"`   // total documents examined
+	KeysExamined   int    `bson:"keysExamined"`   // total index keys examined
+	LockWaitMicros int    `bson:"lockWaitMicros"` // total microseconds waiting for locks
+	NReturned      int    `bson:"nreturned"`      // total documents returned or modified
+	NumYields      int    `bson:"numYields"`      // total yields
+	PlanCacheKey   string `bson:"planCacheKey"`   // last seen plan cache key
+	QueryHash      string `bson:"queryHash"`      // last seen query hash
+	ReadMicros     int    `bson:"readMicros"`     // total microseconds reading from storage
+
+	Plans []PlanUsage `bson:"plans"` // distinct plans chosen
+
+	Merger             string `bson:"merger"`             // where results are merged, mongos or shard
+	NShards            int    `bson:"nShards"`            // max number of shards targeted
+	RemoteOpWaitMillis int    `bson:"remoteOpWaitMillis"` // total milliseconds waiting for shards
+}
+
+// PlanUsage counts how often a plan was chosen for a query shape
+type PlanUsage struct {
+	Count        int    `bson:"count"`
+	PlanCacheKey string `bson:"planCacheKey"`
+	PlanSummary  string `bson:"planSummary"`
 }
 
 // SlowOps holds slow ops log and time
@@ -66,6 +108,20 @@ type LogStats struct {
 	op     string
 	scan   string
 	utc    string
+
+	bytesRead      int
+	docsExamined   int
+	keysExamined   int
+	lockWaitMicros int
+	nreturned      int
+	numYields      int
+	planCacheKey   string
+	queryHash      string
+	readMicros     int
+
+	merger             string
+	nShards            int
+	remoteOpWaitMillis int
 }
 
 // Histogram stores ops info
@@ -78,7 +134,8 @@ const dollarCmd = "$cmd"
 
 // NewLogInfo -
 func NewLogInfo(version string) *LogInfo {
-	li := LogInfo{Logger: NewLogger(version, "-loginfo"), Collscan: false, silent: false, verbose: false}
+	li := LogInfo{Logger: NewLogger(version, "-loginfo"), Collscan: false, refresh: 10, silent: false, verbose: false,
+		workers: runtime.NumCPU()}
 	li.regex = `^(\S+) \S+\s+(\w+)\s+\[\w+\] (\w+) (\S+) \S+: (.*) (\d+)ms$` // SERVER-37743
 	return &li
 }
@@ -93,6 +150,65 @@ func (li *LogInfo) SetRedaction(redaction bool) {
 	li.Redaction = redaction
 }
 
+// SetGroupByQueryHash groups query shapes by queryHash when available (4.2+)
+func (li *LogInfo) SetGroupByQueryHash(groupByHash bool) {
+	li.groupBy = ""
+	if groupByHash {
+		li.groupBy = "queryHash"
+	}
+}
+
+// SetRefreshInterval sets seconds between summary refreshes in follow mode
+func (li *LogInfo) SetRefreshInterval(refresh int) {
+	if refresh > 0 {
+		li.refresh = refresh
+	}
+}
+
+// SetTimeRange sets the time window of logs to analyze; an empty string leaves the bound open
+func (li *LogInfo) SetTimeRange(from string, to string) error {
+	var err error
+	if li.from, err = ParseTimeBound(from, false); err != nil {
+		return err
+	}
+	if li.to, err = ParseTimeBound(to, true); err != nil {
+		return err
+	}
+	if li.from.IsZero() == false && li.to.IsZero() == false && li.to.Before(li.from) {
+		return errors.New("time range 'to' is before 'from'")
+	}
+	return err
+}
+
+// isInTimeRange returns true if a log timestamp falls into the time window
+func (li *LogInfo) isInTimeRange(t time.Time) bool {
+	if li.from.IsZero() == false && t.Before(li.from) {
+		return false
+	}
+	if li.to.IsZero() == false && t.After(li.to) == true {
+		return false
+	}
+	return true
+}
+
+// SetSortBy sets sorting of op patterns, avg, count, max, total, p50, p95, or p99
+func (li *LogInfo) SetSortBy(sortBy string) error {
+	switch sortBy {
+	case "", "avg", "count", "max", "total", "p50", "p95", "p99":
+		li.sortBy = sortBy
+		return nil
+	}
+	return errors.New("unsupported sort key " + sortBy)
+}
+
+// SetWorkers sets number of parser workers, lines are parsed sequentially if 1
+func (li *LogInfo) SetWorkers(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	li.workers = workers
+}
+
 // SetSilent -
 func (li *LogInfo) SetSilent(silent bool) {
 	li.silent = silent
@@ -154,6 +270,12 @@ func (li *LogInfo) AnalyzeFile(filename string) error {
 		if err = bson.Unmarshal(data, &li); err != nil {
 			return err
 		}
+		if li.sortBy != "" {
+			sortOpPatterns(li.OpPatterns, li.sortBy)
+		}
+		if li.Connections != nil {
+			li.Connections.load()
+		}
 	} else {
 		li.LogType = ""
 		var file *os.File
@@ -166,15 +288,13 @@ func (li *LogInfo) AnalyzeFile(filename string) error {
 		if reader, err = gox.NewReader(file); err != nil {
 			return err
 		}
-		var lineCounts int
-		if li.silent == false {
-			lineCounts, _ = gox.CountLines(reader)
-		}
-		file.Seek(0, 0)
-		if reader, err = gox.NewReader(file); err != nil {
+		var info os.FileInfo
+		if info, err = file.Stat(); err != nil {
 			return err
 		}
-		if err = li.Parse(reader, lineCounts); err != nil {
+		li.progress = &fileProgress{file: file, size: info.Size()}
+		defer func() { li.progress = nil }()
+		if err = li.Parse(reader); err != nil {
 			return err
 		}
 	}
@@ -184,116 +304,252 @@ func (li *LogInfo) AnalyzeFile(filename string) error {
 // Parse parse text or json
 func (li *LogInfo) Parse(reader *bufio.Reader, counts ...int) error {
 	var err error
-	var buf []byte
-	var isPrefix bool
-	var opsMap map[string]OpPattern
-	var stat LogStats
-	opsMap = make(map[string]OpPattern)
 	lineCounts := 0
 	if len(counts) > 0 {
 		lineCounts = counts[0]
 	}
 	index := 0
-	var ts string
-	var hist = Histogram{Ops: map[string]int{}}
-	for {
-		if lineCounts > 0 && li.silent == false && index%50 == 0 {
-			fmt.Fprintf(os.Stderr, "\r%3d%% \r", (100*index)/lineCounts)
-		}
-		if buf, isPrefix, err = reader.ReadLine(); err != nil { // 0x0A separator = newline
-			break
-		}
-		index++
-		if len(buf) == 0 {
-			continue
-		}
-		str := string(buf)
-		for isPrefix == true {
-			var bbuf []byte
-			if bbuf, isPrefix, err = reader.ReadLine(); err != nil {
-				break
-			}
-			str += string(bbuf)
+	li.reset()
+	if err = li.checkTimestampFormat(reader); err != nil {
+		return err
+	}
+	if data, _ := reader.Peek(5); isBSONDocument(data) == true { // BSON audit logs
+		if err = li.parseBSONDocuments(reader); err != nil {
+			return err
 		}
-		if li.LogType == "" { //examine the log logType
-			if regexp.MustCompile("^{.*}$").MatchString(str) == true {
-				li.LogType = "logv2"
-				if stat, err = li.ParseLogv2(str); err != nil {
-					continue
-				}
-			} else {
-				li.LogType = "text"
-				if stat, err = li.ParseLog(str); err != nil {
-					continue
-				}
+	} else if li.workers > 1 {
+		li.parseParallel(reader, lineCounts)
+	} else {
+		for {
+			if index%50 == 0 {
+				li.printProgress(index, lineCounts)
 			}
-		} else if li.LogType == "logv2" || str[0:1] == "{" {
-			if stat, err = li.ParseLogv2(str); err != nil {
-				continue
+			var str string
+			if str, err = readLogLine(reader); err != nil {
+				break
 			}
-			li.LogType = "logv2"
-		} else {
-			if stat, err = li.ParseLog(str); err != nil {
+			index++
+			if len(str) == 0 {
 				continue
 			}
+			li.parseLine(str)
 		}
-		if stat.op == "" {
-			if li.verbose {
-				fmt.Println(str)
-			}
-			continue
-		} else if stat.op == dollarCmd {
-			continue
+	}
+	li.Histogram = append(li.Histogram, li.hist)
+	li.OpPatterns = li.getOpPatterns()
+	li.Connections.finalize()
+	if li.silent == false {
+		fmt.Fprintf(os.Stderr, "\r                         \r")
+	}
+	return nil
+}
+
+// checkTimestampFormat returns an error if a time window is set and the first line of text logs
+// is not of an ISO-8601 timestamp, e.g. of --timeStampFormat ctime, instead of dropping every line
+func (li *LogInfo) checkTimestampFormat(reader *bufio.Reader) error {
+	if li.from.IsZero() == true && li.to.IsZero() == true {
+		return nil
+	}
+	data, _ := reader.Peek(256)
+	if len(data) == 0 || data[0] == '{' || isBSONDocument(data) == true {
+		return nil
+	}
+	str := string(data)
+	if idx := strings.Index(str, "\n"); idx >= 0 {
+		str = str[:idx]
+	}
+	ts := getLogTimestamp(strings.TrimSpace(str))
+	if _, err := parseLogTime(ts); err != nil {
+		return fmt.Errorf("unsupported timestamp format for --from/--to, %v, use ISO-8601 timestamps, e.g. 2020-05-12T12:48:14.398-0400", ts)
+	}
+	return nil
+}
+
+// reset clears the in-progress aggregation states
+func (li *LogInfo) reset() {
+	li.opsMap = map[string]OpPattern{}
+	li.hist = Histogram{Ops: map[string]int{}}
+	li.ts = ""
+	li.Connections = NewConnectionsInfo()
+}
+
+// parseLine parses a log line and adds its stats
+func (li *LogInfo) parseLine(str string) {
+	li.apply(li.parse(str))
+}
+
+// parsedLine is a parsed log line to be aggregated
+type parsedLine struct {
+	isStats   bool // has stats to add
+	isUnknown bool // no op found, printed in verbose mode
+	logType   string
+	stat      LogStats
+	str       string
+}
+
+// parse parses a log line without aggregating stats, connection events are added to li.Connections
+func (li *LogInfo) parse(str string) parsedLine {
+	var err error
+	line := parsedLine{str: str}
+	if li.Connections != nil && li.parseConnection(str) == true {
+		return line
+	}
+	parser := GetLogParser(str)
+	if parser == nil {
+		return line
+	}
+	line.logType = parser.Name()
+	if line.stat, err = parser.Parse(li, str); err != nil {
+		return line
+	}
+	if line.stat.op == "" {
+		line.isUnknown = true
+	} else if line.stat.op != dollarCmd {
+		line.isStats = true
+	}
+	return line
+}
+
+// apply aggregates a parsed log line
+func (li *LogInfo) apply(line parsedLine) {
+	if li.LogType == "" { //examine the log logType
+		li.LogType = line.logType
+	}
+	if line.isUnknown == true && li.verbose == true {
+		fmt.Println(line.str)
+	}
+	if line.isStats == true {
+		li.add(line.stat, line.str)
+	}
+}
+
+// add aggregates stats of a log line into histogram, slow ops, and op patterns
+func (li *LogInfo) add(stat LogStats, str string) {
+	if stat.utc != li.ts { //	push
+		fmt.Fprintf(os.Stderr, "\r     %v\r", stat.utc)
+		if li.ts != "" {
+			li.Histogram = append(li.Histogram, li.hist)
 		}
-		if stat.utc != ts { //	push
-			fmt.Fprintf(os.Stderr, "\r     %v\r", stat.utc)
-			if ts != "" {
-				li.Histogram = append(li.Histogram, hist)
-			}
-			ts = stat.utc
-			hist = Histogram{UTC: ts, Ops: map[string]int{}}
+		li.ts = stat.utc
+		li.hist = Histogram{UTC: li.ts, Ops: map[string]int{}}
+	}
+	cnt := li.hist.Ops[stat.op]
+	cnt++
+	li.hist.Ops[stat.op] = cnt
+	key := stat.op + "." + stat.ns + "." + stat.filter + "." + stat.scan
+	if li.groupBy == "queryHash" && stat.queryHash != "" {
+		key = stat.op + "." + stat.ns + "." + stat.queryHash
+	}
+	opsMap := li.opsMap
+	if stat.op != "insert" && (len(li.SlowOps) < topN || stat.milli > li.SlowOps[topN-1].Milli) {
+		li.SlowOps = append(li.SlowOps, SlowOps{Milli: stat.milli, Log: str})
+		sort.Slice(li.SlowOps, func(i, j int) bool {
+			return li.SlowOps[i].Milli > li.SlowOps[j].Milli
+		})
+		if len(li.SlowOps) > topN {
+			li.SlowOps = li.SlowOps[:topN]
 		}
-		cnt := hist.Ops[stat.op]
-		cnt++
-		hist.Ops[stat.op] = cnt
-		key := stat.op + "." + stat.ns + "." + stat.filter + "." + stat.scan
-		_, ok := opsMap[key]
-		if stat.op != "insert" && (len(li.SlowOps) < topN || stat.milli > li.SlowOps[topN-1].Milli) {
-			li.SlowOps = append(li.SlowOps, SlowOps{Milli: stat.milli, Log: str})
-			sort.Slice(li.SlowOps, func(i, j int) bool {
-				return li.SlowOps[i].Milli > li.SlowOps[j].Milli
-			})
-			if len(li.SlowOps) > topN {
-				li.SlowOps = li.SlowOps[:topN]
-			}
+	}
+
+	op, ok := opsMap[key]
+	if !ok {
+		op = OpPattern{Command: stat.op, Filter: stat.filter, Latency: NewLatencySketch()}
+	}
+	op.Namespace = stat.ns
+	op.Scan = stat.scan
+	op.Index = stat.index
+	if stat.milli > op.MaxMilli {
+		op.MaxMilli = stat.milli
+	}
+	op.TotalMilli += stat.milli
+	op.Count++
+	op.Latency.Add(stat.milli)
+	op.BytesRead += stat.bytesRead
+	op.DocsExamined += stat.docsExamined
+	op.KeysExamined += stat.keysExamined
+	op.LockWaitMicros += stat.lockWaitMicros
+	op.NReturned += stat.nreturned
+	op.NumYields += stat.numYields
+	op.ReadMicros += stat.readMicros
+	if stat.planCacheKey != "" {
+		op.PlanCacheKey = stat.planCacheKey
+	}
+	if stat.queryHash != "" {
+		op.QueryHash = stat.queryHash
+	}
+	op.addPlan(stat)
+	if stat.merger != "" {
+		op.Merger = stat.merger
+	}
+	if stat.nShards > op.NShards {
+		op.NShards = stat.nShards
+	}
+	op.RemoteOpWaitMillis += stat.remoteOpWaitMillis
+	opsMap[key] = op
+}
+
+// addPlan counts the plan chosen by an op
+func (op *OpPattern) addPlan(stat LogStats) {
+	summary := stat.scan
+	if summary == "" {
+		summary = stat.index
+	}
+	if summary == "" && stat.planCacheKey == "" {
+		return
+	}
+	for i, plan := range op.Plans {
+		if plan.PlanSummary == summary && plan.PlanCacheKey == stat.planCacheKey {
+			op.Plans[i].Count++
+			return
 		}
+	}
+	op.Plans = append(op.Plans, PlanUsage{Count: 1, PlanCacheKey: stat.planCacheKey, PlanSummary: summary})
+}
 
-		if ok {
-			max := opsMap[key].MaxMilli
-			if stat.milli > max {
-				max = stat.milli
+// getOpPatterns returns sorted op patterns with latency percentiles
+func (li *LogInfo) getOpPatterns() []OpPattern {
+	opPatterns := make([]OpPattern, 0, len(li.opsMap))
+	for _, value := range li.opsMap {
+		if value.Latency == nil { // loaded from an analysis without latency sketch
+			opPatterns = append(opPatterns, value)
+			continue
+		}
+		value.P50 = value.Latency.Quantile(.5)
+		value.P95 = value.Latency.Quantile(.95)
+		value.P99 = value.Latency.Quantile(.99)
+		for _, p := range []*int{&value.P50, &value.P95, &value.P99} {
+			if *p > value.MaxMilli { // bucket estimates can exceed the actual max
+				*p = value.MaxMilli
 			}
-			x := opsMap[key].TotalMilli + stat.milli
-			y := opsMap[key].Count + 1
-			opsMap[key] = OpPattern{Command: opsMap[key].Command, Namespace: stat.ns, Filter: opsMap[key].Filter,
-				MaxMilli: max, TotalMilli: x, Count: y, Scan: stat.scan, Index: stat.index}
-		} else {
-			opsMap[key] = OpPattern{Command: stat.op, Namespace: stat.ns, Filter: stat.filter, TotalMilli: stat.milli,
-				MaxMilli: stat.milli, Count: 1, Scan: stat.scan, Index: stat.index}
 		}
+		opPatterns = append(opPatterns, value)
 	}
-	li.Histogram = append(li.Histogram, hist)
-	li.OpPatterns = make([]OpPattern, 0, len(opsMap))
-	for _, value := range opsMap {
-		li.OpPatterns = append(li.OpPatterns, value)
+	sortOpPatterns(opPatterns, li.sortBy)
+	return opPatterns
+}
+
+// sortOpPatterns sorts op patterns in descending order, by average milliseconds by default
+func sortOpPatterns(opPatterns []OpPattern, sortBy string) {
+	value := func(op OpPattern) float64 {
+		switch sortBy {
+		case "count":
+			return float64(op.Count)
+		case "max":
+			return float64(op.MaxMilli)
+		case "total":
+			return float64(op.TotalMilli)
+		case "p50":
+			return float64(op.P50)
+		case "p95":
+			return float64(op.P95)
+		case "p99":
+			return float64(op.P99)
+		}
+		return float64(op.TotalMilli) / float64(op.Count)
 	}
-	sort.Slice(li.OpPatterns, func(i, j int) bool {
-		return float64(li.OpPatterns[i].TotalMilli)/float64(li.OpPatterns[i].Count) > float64(li.OpPatterns[j].TotalMilli)/float64(li.OpPatterns[j].Count)
+	sort.Slice(opPatterns, func(i, j int) bool {
+		return value(opPatterns[i]) > value(opPatterns[j])
 	})
-	if li.silent == false {
-		fmt.Fprintf(os.Stderr, "\r                         \r")
-	}
-	return nil
 }
 
 // OutputBSON writes loginfo bson data
@@ -349,6 +605,39 @@ func (li *LogInfo) OutputJSON() error {
 // Print prints indexes
 func (li *LogInfo) Print() {
 	fmt.Println(li.printLogsSummary())
+	if li.Connections.isEmpty() == false {
+		fmt.Println(li.Connections.printConnectionsSummary(li.silent))
+	}
+	if li.Connections != nil && len(li.Connections.AuditEvents) > 0 {
+		fmt.Println(li.Connections.printAuditSummary(li.silent))
+	}
+}
+
+// highExaminedRatio flags shapes examining many more docs or keys than returned
+const highExaminedRatio = 100
+
+// getExaminedSummary returns examined-to-returned ratios and per op averages
+func getExaminedSummary(op OpPattern) (string, bool) {
+	if op.Count == 0 || op.KeysExamined+op.DocsExamined == 0 {
+		return "", false
+	}
+	var ratios string
+	isHigh := false
+	if op.NReturned > 0 {
+		keys := float64(op.KeysExamined) / float64(op.NReturned)
+		docs := float64(op.DocsExamined) / float64(op.NReturned)
+		isHigh = keys >= highExaminedRatio || docs >= highExaminedRatio
+		ratios = fmt.Sprintf("keys/returned %.1f, docs/returned %.1f", keys, docs)
+	} else {
+		isHigh = op.DocsExamined/op.Count >= highExaminedRatio || op.KeysExamined/op.Count >= highExaminedRatio
+		ratios = "none returned"
+	}
+	n := float64(op.Count)
+	str := fmt.Sprintf("%v; avg keys %.0f, docs %.0f, returned %.1f, yields %.1f, lock wait %.1fms, read %v in %.1fms",
+		ratios, float64(op.KeysExamined)/n, float64(op.DocsExamined)/n, float64(op.NReturned)/n,
+		float64(op.NumYields)/n, float64(op.LockWaitMicros)/n/1000,
+		gox.GetStorageSize(op.BytesRead/op.Count), float64(op.ReadMicros)/n/1000)
+	return str, isHigh
 }
 
 // printLogsSummary prints loginfo summary
@@ -364,9 +653,9 @@ func (li *LogInfo) printLogsSummary() string {
 	}
 	summaries := []string{}
 	var buffer bytes.Buffer
-	buffer.WriteString("\r+----------+--------+------+--------+------+---------------------------------+--------------------------------------------------------------+\n")
-	buffer.WriteString(fmt.Sprintf("| Command  |COLLSCAN|avg ms| max ms | Count| %-32s| %-60s |\n", "Namespace", "Query Pattern"))
-	buffer.WriteString("|----------+--------+------+--------+------+---------------------------------+--------------------------------------------------------------|\n")
+	buffer.WriteString("\r+----------+--------+------+------+------+------+--------+------+---------------------------------+--------------------------------------------------------------+\n")
+	buffer.WriteString(fmt.Sprintf("| Command  |COLLSCAN|avg ms|p50 ms|p95 ms|p99 ms| max ms | Count| %-32s| %-60s |\n", "Namespace", "Query Pattern"))
+	buffer.WriteString("|----------+--------+------+------+------+------+--------+------+---------------------------------+--------------------------------------------------------------|\n")
 	count := 0
 	for _, value := range li.OpPatterns {
 		count++
@@ -391,12 +680,15 @@ func (li *LogInfo) printLogsSummary() string {
 		output := ""
 		avg := float64(value.TotalMilli) / float64(value.Count)
 		avgstr := gox.MilliToTimeString(avg)
+		p50str := gox.MilliToTimeString(float64(value.P50))
+		p95str := gox.MilliToTimeString(float64(value.P95))
+		p99str := gox.MilliToTimeString(float64(value.P99))
 		if value.Scan == COLLSCAN {
-			output = fmt.Sprintf("|%-10s %v%8s%v %6s %8d %6d %-33s %v%-62s%v|\n", value.Command, red, value.Scan, tail,
-				avgstr, value.MaxMilli, value.Count, value.Namespace, red, str, tail)
+			output = fmt.Sprintf("|%-10s %v%8s%v %6s %6s %6s %6s %8d %6d %-33s %v%-62s%v|\n", value.Command, red, value.Scan, tail,
+				avgstr, p50str, p95str, p99str, value.MaxMilli, value.Count, value.Namespace, red, str, tail)
 		} else {
-			output = fmt.Sprintf("|%-10s %8s %6s %8d %6d %-33s %-62s|\n", value.Command, value.Scan,
-				avgstr, value.MaxMilli, value.Count, value.Namespace, str)
+			output = fmt.Sprintf("|%-10s %8s %6s %6s %6s %6s %8d %6d %-33s %-62s|\n", value.Command, value.Scan,
+				avgstr, p50str, p95str, p99str, value.MaxMilli, value.Count, value.Namespace, str)
 		}
 		buffer.WriteString(output)
 		if len(value.Filter) > 60 {
@@ -419,20 +711,44 @@ func (li *LogInfo) printLogsSummary() string {
 					}
 				}
 				if value.Scan == COLLSCAN {
-					output = fmt.Sprintf("|%74s   %v%-62s%v|\n", " ", red, pstr, tail)
+					output = fmt.Sprintf("|%95s   %v%-62s%v|\n", " ", red, pstr, tail)
 					buffer.WriteString(output)
 				} else {
-					output = fmt.Sprintf("|%74s   %-62s|\n", " ", pstr)
+					output = fmt.Sprintf("|%95s   %-62s|\n", " ", pstr)
 					buffer.WriteString(output)
 				}
 			}
 		}
 		if value.Index != "" {
-			output = fmt.Sprintf("|...index:  %v%-128s%v|\n", green, value.Index, tail)
+			output = fmt.Sprintf("|...index:  %v%-149s%v|\n", green, value.Index, tail)
+			buffer.WriteString(output)
+		}
+		if len(value.Plans) > 1 { // flipping between plans
+			plans := []string{}
+			for _, plan := range value.Plans {
+				plans = append(plans, fmt.Sprintf("%v x %d", strings.ReplaceAll(plan.PlanSummary, "\n", " "), plan.Count))
+			}
+			output = fmt.Sprintf("|...plans:  %v%-149s%v|\n", red, strings.Join(plans, "; "), tail)
+			buffer.WriteString(output)
+		}
+		if examined, isHigh := getExaminedSummary(value); examined != "" {
+			color := ""
+			if isHigh {
+				color = red
+			}
+			output = fmt.Sprintf("|...scan:   %v%-149s%v|\n", color, examined, tail)
+			buffer.WriteString(output)
+		}
+		if sharded, isHigh := getShardedSummary(value); sharded != "" {
+			color := ""
+			if isHigh {
+				color = red
+			}
+			output = fmt.Sprintf("|...shards: %v%-149s%v|\n", color, sharded, tail)
 			buffer.WriteString(output)
 		}
 	}
-	buffer.WriteString("+----------+--------+------+--------+------+---------------------------------+--------------------------------------------------------------+\n")
+	buffer.WriteString("+----------+--------+------+------+------+------+--------+------+---------------------------------+--------------------------------------------------------------+\n")
 	summaries = append(summaries, buffer.String())
 	summaries = append(summaries, fmt.Sprintf(`top %d of %v lines displayed; see HTML report for details.`, count, len(li.OpPatterns)))
 	return strings.Join(summaries, "\n")
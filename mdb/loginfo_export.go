@@ -0,0 +1,225 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SetFormat sets the format of exported reports, csv, md, or html
+func (li *LogInfo) SetFormat(format string) error {
+	if format != "" && format != "csv" && format != "md" && format != "html" {
+		return errors.New("unsupported format " + format + ", use csv, md, or html")
+	}
+	li.format = format
+	return nil
+}
+
+// OutputReport writes op patterns, slow ops, and histogram in the format set by SetFormat
+func (li *LogInfo) OutputReport() error {
+	var err error
+	if li.format == "" || len(li.OpPatterns) == 0 {
+		return err
+	}
+	outdir := "./out/"
+	os.Mkdir(outdir, 0755)
+	basename := outdir + li.getOutputBasename()
+	files := map[string][]byte{}
+	switch li.format {
+	case "csv":
+		for name, records := range map[string][][]string{"ops": li.getOpPatternsRecords(),
+			"slowops": li.getSlowOpsRecords(), "histogram": li.getHistogramRecords()} {
+			var buffer bytes.Buffer
+			writer := csv.NewWriter(&buffer)
+			if err = writer.WriteAll(records); err != nil {
+				return err
+			}
+			files[basename+"-"+name+".csv"] = buffer.Bytes()
+		}
+	case "md":
+		files[basename+"-log.md"] = []byte(li.getMarkdown())
+	case "html":
+		var data []byte
+		if data, err = li.getHTML(); err != nil {
+			return err
+		}
+		files[basename+"-log.html"] = data
+	}
+	ofiles := []string{}
+	for ofile := range files {
+		ofiles = append(ofiles, ofile)
+	}
+	sort.Strings(ofiles)
+	for _, ofile := range ofiles {
+		if err = ioutil.WriteFile(ofile, files[ofile], 0644); err != nil {
+			return err
+		}
+		fmt.Println(li.format, "report written to", ofile)
+	}
+	return err
+}
+
+// getOutputBasename returns the log file name without extensions
+func (li *LogInfo) getOutputBasename() string {
+	ofile := filepath.Base(li.filename)
+	for _, ext := range []string{".gz", "-log.bson", ".log"} {
+		ofile = strings.TrimSuffix(ofile, ext)
+	}
+	return ofile
+}
+
+func (li *LogInfo) getOpPatternsRecords() [][]string {
+	records := [][]string{{"command", "namespace", "filter", "index", "scan", "count", "avg_ms", "p50_ms",
+		"p95_ms", "p99_ms", "max_ms", "total_ms", "docsExamined", "keysExamined", "nreturned", "queryHash"}}
+	for _, op := range li.OpPatterns {
+		records = append(records, []string{op.Command, op.Namespace, op.Filter, op.Index, op.Scan,
+			strconv.Itoa(op.Count), fmt.Sprintf("%.1f", float64(op.TotalMilli)/float64(op.Count)),
+			strconv.Itoa(op.P50), strconv.Itoa(op.P95), strconv.Itoa(op.P99), strconv.Itoa(op.MaxMilli),
+			strconv.Itoa(op.TotalMilli), strconv.Itoa(op.DocsExamined), strconv.Itoa(op.KeysExamined),
+			strconv.Itoa(op.NReturned), op.QueryHash})
+	}
+	return records
+}
+
+func (li *LogInfo) getSlowOpsRecords() [][]string {
+	records := [][]string{{"milli", "log"}}
+	for _, op := range li.SlowOps {
+		records = append(records, []string{strconv.Itoa(op.Milli), op.Log})
+	}
+	return records
+}
+
+// getHistogramRecords returns counts of each command per 10 minutes and ops per minute
+func (li *LogInfo) getHistogramRecords() [][]string {
+	commands := li.getHistogramCommands()
+	records := [][]string{append(append([]string{"utc"}, commands...), "total", "ops_per_minute")}
+	for _, hist := range li.Histogram {
+		if hist.UTC == "" {
+			continue
+		}
+		record := []string{hist.UTC}
+		total := 0
+		for _, command := range commands {
+			record = append(record, strconv.Itoa(hist.Ops[command]))
+			total += hist.Ops[command]
+		}
+		records = append(records, append(record, strconv.Itoa(total), fmt.Sprintf("%.1f", float64(total)/10)))
+	}
+	return records
+}
+
+func (li *LogInfo) getHistogramCommands() []string {
+	keys := map[string]bool{}
+	for _, hist := range li.Histogram {
+		for k := range hist.Ops {
+			keys[k] = true
+		}
+	}
+	commands := []string{}
+	for k := range keys {
+		commands = append(commands, k)
+	}
+	sort.Strings(commands)
+	return commands
+}
+
+// getMarkdown returns op patterns, slow ops, and histogram as markdown tables
+func (li *LogInfo) getMarkdown() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("# Log Analysis: %v\n\n", filepath.Base(li.filename)))
+	sections := []struct {
+		title   string
+		records [][]string
+	}{{"Op Patterns", li.getOpPatternsRecords()}, {"Slow Ops", li.getSlowOpsRecords()},
+		{"Histogram", li.getHistogramRecords()}}
+	for _, section := range sections {
+		buffer.WriteString(fmt.Sprintf("## %v\n\n", section.title))
+		for i, record := range section.records {
+			cells := []string{}
+			for _, cell := range record {
+				cell = strings.ReplaceAll(strings.ReplaceAll(cell, "|", `\|`), "\n", " ")
+				if i > 0 && (strings.HasPrefix(cell, "{") || len(cell) > 80) {
+					cell = "`" + strings.ReplaceAll(cell, "`", "'") + "`"
+				}
+				cells = append(cells, cell)
+			}
+			buffer.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+			if i == 0 {
+				buffer.WriteString(strings.Repeat("|---", len(record)) + "|\n")
+			}
+		}
+		buffer.WriteString("\n")
+	}
+	return buffer.String()
+}
+
+// svgBar is a bar of the ops per minute chart
+type svgBar struct {
+	Height float64
+	Label  string
+	Width  float64
+	X      float64
+	Y      float64
+}
+
+const svgChartWidth = 960
+const svgChartHeight = 240
+
+// getOpsPerMinuteBars returns bars of ops per minute from histogram
+func (li *LogInfo) getOpsPerMinuteBars() ([]svgBar, float64) {
+	records := li.getHistogramRecords()[1:]
+	bars := []svgBar{}
+	if len(records) == 0 {
+		return bars, 0
+	}
+	max := 0.0
+	values := []float64{}
+	for _, record := range records {
+		value, _ := strconv.ParseFloat(record[len(record)-1], 64)
+		values = append(values, value)
+		if value > max {
+			max = value
+		}
+	}
+	width := float64(svgChartWidth) / float64(len(records))
+	for i, value := range values {
+		height := 0.0
+		if max > 0 {
+			height = value / max * svgChartHeight
+		}
+		bars = append(bars, svgBar{Height: height, Label: fmt.Sprintf("%v: %.1f ops/min", records[i][0], value),
+			Width: width * .9, X: float64(i) * width, Y: svgChartHeight - height})
+	}
+	return bars, max
+}
+
+// getHTML returns a self-contained HTML report with sortable tables and an ops per minute chart
+func (li *LogInfo) getHTML() ([]byte, error) {
+	var err error
+	var buffer bytes.Buffer
+	bars, max := li.getOpsPerMinuteBars()
+	tables := []struct {
+		Title   string
+		Records [][]string
+	}{{"Op Patterns", li.getOpPatternsRecords()}, {"Slow Ops", li.getSlowOpsRecords()},
+		{"Histogram", li.getHistogramRecords()}}
+	doc := map[string]interface{}{"Bars": bars, "ChartHeight": svgChartHeight, "ChartWidth": svgChartWidth,
+		"Filename": filepath.Base(li.filename), "Generated": time.Now().Format(time.RFC3339),
+		"Max": fmt.Sprintf("%.1f", max), "Tables": tables}
+	tmpl := template.Must(template.New("loginfo").Parse(loginfoHTML))
+	if err = tmpl.Execute(&buffer, doc); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), err
+}
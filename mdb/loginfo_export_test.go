@@ -0,0 +1,44 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogInfoSetFormat(t *testing.T) {
+	loginfo := NewLogInfo("utest-xxxxxx")
+	for _, format := range []string{"", "csv", "md", "html"} {
+		if err := loginfo.SetFormat(format); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := loginfo.SetFormat("xml"); err == nil {
+		t.Fatal("expected unsupported format error")
+	}
+}
+
+func TestLogInfoExport(t *testing.T) {
+	loginfo := getTestLogInfo(t, map[string]int{"a|b": 100, "c": 200})
+	records := loginfo.getHistogramRecords()
+	if len(records) != 2 || strings.Join(records[0], ",") != "utc,find,total,ops_per_minute" ||
+		strings.Join(records[1], ",") != "2020-09-28T11:10:00Z,2,2,0.2" {
+		t.Fatal(records)
+	}
+	if records = loginfo.getOpPatternsRecords(); len(records) != 3 || records[1][0] != "find" {
+		t.Fatal(records)
+	}
+	if md := loginfo.getMarkdown(); strings.Contains(md, "`{\"a\\|b\":1}`") == false {
+		t.Fatal(md)
+	}
+	data, err := loginfo.getHTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	str := string(data)
+	if strings.Contains(str, `<table class="sortable">`) == false || strings.Count(str, "<rect ") != 1 ||
+		strings.Contains(str, "0.2 ops/min") == false || strings.Contains(str, "<script src") == true {
+		t.Fatal(str)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+const loginfoHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8" />
+<title>Keyhole Log Analysis - {{.Filename}}</title>
+<style>
+  body {
+    font-family: Arial, Helvetica, sans-serif;
+    font-size: 12px;
+  }
+  table {
+    border-collapse: collapse;
+    margin-bottom: 20px;
+  }
+  th, td {
+    border: 1px solid #ccc;
+    padding: 3px 6px;
+    text-align: left;
+    vertical-align: top;
+  }
+  th {
+    background-color: #2e4053;
+    color: #fff;
+    cursor: pointer;
+  }
+  tr:nth-child(even) {
+    background-color: #f2f2f2;
+  }
+  td {
+    font-family: Consolas, monaco, monospace;
+    max-width: 800px;
+    word-break: break-all;
+  }
+  rect {
+    fill: #2e86c1;
+  }
+  rect:hover {
+    fill: #e74c3c;
+  }
+</style>
+</head>
+<body>
+<h1>Log Analysis: {{.Filename}}</h1>
+<p>Generated at {{.Generated}}</p>
+
+<h2>Ops per Minute</h2>
+<svg width="{{.ChartWidth}}" height="{{.ChartHeight}}" style="border-bottom: 1px solid #000;">
+{{- range .Bars}}
+  <rect x="{{.X}}" y="{{.Y}}" width="{{.Width}}" height="{{.Height}}"><title>{{.Label}}</title></rect>
+{{- end}}
+</svg>
+<p>max {{.Max}} ops/min, hover over bars for details</p>
+{{range .Tables}}
+<h2>{{.Title}}</h2>
+<table class="sortable">
+{{- range $i, $record := .Records}}
+  <tr>{{range $record}}{{if eq $i 0}}<th>{{.}}</th>{{else}}<td>{{.}}</td>{{end}}{{end}}</tr>
+{{- end}}
+</table>
+{{end}}
+<script>
+  // sorts a table by the clicked column, numerically if both cells are numbers
+  document.querySelectorAll('table.sortable th').forEach(function(th) {
+    th.addEventListener('click', function() {
+      var table = th.closest('table');
+      var rows = Array.prototype.slice.call(table.rows, 1);
+      var col = th.cellIndex;
+      var asc = th.getAttribute('data-order') !== 'asc';
+      rows.sort(function(a, b) {
+        var x = a.cells[col].textContent, y = b.cells[col].textContent;
+        var nx = parseFloat(x), ny = parseFloat(y);
+        var cmp = (!isNaN(nx) && !isNaN(ny)) ? nx - ny : x.localeCompare(y);
+        return asc ? cmp : -cmp;
+      });
+      rows.forEach(function(row) { table.tBodies[0].appendChild(row); });
+      th.setAttribute('data-order', asc ? 'asc' : 'desc');
+    });
+  });
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,255 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffThreshold is the change of average milliseconds to report a shape as slower or faster
+const diffThreshold = .2
+
+// OpPatternDiff stores changes of a query shape between two analyses
+type OpPatternDiff struct {
+	After  *OpPattern `bson:"after"`
+	Before *OpPattern `bson:"before"`
+	Change float64    `bson:"change"` // ratio of average milliseconds, after/before
+	Status string     `bson:"status"` // new, gone, slower, faster, or unchanged
+}
+
+// getKey returns the key of a query shape
+func (op OpPattern) getKey(groupBy string) string {
+	if groupBy == "queryHash" && op.QueryHash != "" {
+		return op.Command + "." + op.Namespace + "." + op.QueryHash
+	}
+	return op.Command + "." + op.Namespace + "." + op.Filter + "." + op.Scan
+}
+
+// merge merges stats of the same query shape
+func (op *OpPattern) merge(other OpPattern) {
+	if other.MaxMilli > op.MaxMilli {
+		op.MaxMilli = other.MaxMilli
+	}
+	op.Count += other.Count
+	op.TotalMilli += other.TotalMilli
+	if op.Latency != nil && other.Latency != nil {
+		op.Latency.Merge(other.Latency)
+	} else { // percentiles cannot be merged without sketches
+		op.Latency = nil
+		for _, p := range [][]*int{{&op.P50, &other.P50}, {&op.P95, &other.P95}, {&op.P99, &other.P99}} {
+			if *p[1] > *p[0] {
+				*p[0] = *p[1]
+			}
+		}
+	}
+	op.BytesRead += other.BytesRead
+	op.DocsExamined += other.DocsExamined
+	op.KeysExamined += other.KeysExamined
+	op.LockWaitMicros += other.LockWaitMicros
+	op.NReturned += other.NReturned
+	op.NumYields += other.NumYields
+	op.ReadMicros += other.ReadMicros
+	if other.Merger != "" {
+		op.Merger = other.Merger
+	}
+	if other.NShards > op.NShards {
+		op.NShards = other.NShards
+	}
+	op.RemoteOpWaitMillis += other.RemoteOpWaitMillis
+	for _, plan := range other.Plans {
+		found := false
+		for i, p := range op.Plans {
+			if p.PlanSummary == plan.PlanSummary && p.PlanCacheKey == plan.PlanCacheKey {
+				op.Plans[i].Count += plan.Count
+				found = true
+				break
+			}
+		}
+		if !found {
+			op.Plans = append(op.Plans, plan)
+		}
+	}
+}
+
+// Merge merges another analysis, e.g. from other nodes of a replica set or from other days
+func (li *LogInfo) Merge(other *LogInfo) {
+	if li.opsMap == nil {
+		li.opsMap = map[string]OpPattern{}
+		for _, op := range li.OpPatterns {
+			li.opsMap[op.getKey(li.groupBy)] = op
+		}
+	}
+	for _, op := range other.OpPatterns {
+		key := op.getKey(li.groupBy)
+		if value, ok := li.opsMap[key]; ok {
+			value.merge(op)
+			li.opsMap[key] = value
+		} else {
+			if op.Latency != nil { // don't share sketches with the other analysis
+				latency := NewLatencySketch()
+				latency.Merge(op.Latency)
+				op.Latency = latency
+			}
+			op.Plans = append([]PlanUsage{}, op.Plans...)
+			li.opsMap[key] = op
+		}
+	}
+	li.OpPatterns = li.getOpPatterns()
+
+	li.SlowOps = append(li.SlowOps, other.SlowOps...)
+	sort.Slice(li.SlowOps, func(i, j int) bool {
+		return li.SlowOps[i].Milli > li.SlowOps[j].Milli
+	})
+	if len(li.SlowOps) > topN {
+		li.SlowOps = li.SlowOps[:topN]
+	}
+
+	hists := map[string]Histogram{}
+	for _, hist := range append(append([]Histogram{}, li.Histogram...), other.Histogram...) {
+		if hist.UTC == "" {
+			continue
+		}
+		merged, ok := hists[hist.UTC]
+		if !ok {
+			merged = Histogram{UTC: hist.UTC, Ops: map[string]int{}}
+		}
+		for k, v := range hist.Ops {
+			merged.Ops[k] += v
+		}
+		hists[hist.UTC] = merged
+	}
+	li.Histogram = []Histogram{}
+	for _, hist := range hists {
+		li.Histogram = append(li.Histogram, hist)
+	}
+	sort.Slice(li.Histogram, func(i, j int) bool {
+		return li.Histogram[i].UTC < li.Histogram[j].UTC
+	})
+	if other.Connections != nil {
+		if li.Connections == nil {
+			li.Connections = NewConnectionsInfo()
+		}
+		li.Connections.merge(other.Connections)
+	}
+	if li.LogType == "" {
+		li.LogType = other.LogType
+	} else if other.LogType != "" && other.LogType != li.LogType {
+		li.LogType = "mixed"
+	}
+	li.filename = "merged"
+}
+
+// Diff compares query shapes of a before and an after analysis
+func (li *LogInfo) Diff(before *LogInfo) []OpPatternDiff {
+	diffs := []OpPatternDiff{}
+	prev := map[string]OpPattern{}
+	for _, op := range before.OpPatterns {
+		prev[op.getKey(li.groupBy)] = op
+	}
+	for i, op := range li.OpPatterns {
+		key := op.getKey(li.groupBy)
+		b, ok := prev[key]
+		if !ok {
+			diffs = append(diffs, OpPatternDiff{After: &li.OpPatterns[i], Status: "new"})
+			continue
+		}
+		delete(prev, key)
+		diff := OpPatternDiff{After: &li.OpPatterns[i], Before: &b, Status: "unchanged"}
+		avgBefore := float64(b.TotalMilli) / float64(b.Count)
+		avgAfter := float64(op.TotalMilli) / float64(op.Count)
+		if avgBefore > 0 {
+			diff.Change = avgAfter / avgBefore
+		}
+		if diff.Change > 1+diffThreshold {
+			diff.Status = "slower"
+		} else if avgBefore > 0 && diff.Change < 1-diffThreshold {
+			diff.Status = "faster"
+		}
+		diffs = append(diffs, diff)
+	}
+	for _, op := range before.OpPatterns {
+		if _, ok := prev[op.getKey(li.groupBy)]; ok {
+			op := op
+			diffs = append(diffs, OpPatternDiff{Before: &op, Status: "gone"})
+		}
+	}
+	order := map[string]int{"slower": 0, "new": 1, "faster": 2, "gone": 3, "unchanged": 4}
+	sort.SliceStable(diffs, func(i, j int) bool {
+		if order[diffs[i].Status] != order[diffs[j].Status] {
+			return order[diffs[i].Status] < order[diffs[j].Status]
+		}
+		return diffs[i].getTotalMilli() > diffs[j].getTotalMilli()
+	})
+	return diffs
+}
+
+func (diff OpPatternDiff) getTotalMilli() int {
+	if diff.After != nil {
+		return diff.After.TotalMilli
+	}
+	return diff.Before.TotalMilli
+}
+
+// PrintDiff prints changes of query shapes, unchanged shapes are printed in verbose mode
+func (li *LogInfo) PrintDiff(diffs []OpPatternDiff) string {
+	red := codeRed
+	green := codeGreen
+	tail := codeDefault
+	if li.silent == true {
+		red = ""
+		green = ""
+		tail = ""
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString("\r+---------+----------+--------+--------+-------+-------+-------+---------------------------------+--------------------------------------------------------------+\n")
+	buffer.WriteString(fmt.Sprintf("| Status  | Command  | before | after  |change |before | after | %-32s| %-60s |\n", "Namespace", "Query Pattern"))
+	buffer.WriteString(fmt.Sprintf("|         |          | avg ms | avg ms |       | count | count | %-32s| %-60s |\n", "", ""))
+	buffer.WriteString("|---------+----------+--------+--------+-------+-------+-------+---------------------------------+--------------------------------------------------------------|\n")
+	for _, diff := range diffs {
+		if diff.Status == "unchanged" && li.verbose == false {
+			continue
+		}
+		op := diff.After
+		if op == nil {
+			op = diff.Before
+		}
+		before, after, change := "", "", ""
+		beforeCount, afterCount := 0, 0
+		if diff.Before != nil {
+			before = fmt.Sprintf("%.0f", float64(diff.Before.TotalMilli)/float64(diff.Before.Count))
+			beforeCount = diff.Before.Count
+		}
+		if diff.After != nil {
+			after = fmt.Sprintf("%.0f", float64(diff.After.TotalMilli)/float64(diff.After.Count))
+			afterCount = diff.After.Count
+		}
+		if diff.Before != nil && diff.After != nil && diff.Change > 0 {
+			change = fmt.Sprintf("%+.0f%%", 100*(diff.Change-1))
+		}
+		color := ""
+		if diff.Status == "slower" || diff.Status == "new" {
+			color = red
+		} else if diff.Status == "faster" || diff.Status == "gone" {
+			color = green
+		}
+		command := op.Command
+		if len(command) > 10 {
+			command = command[:10]
+		}
+		ns := op.Namespace
+		if len(ns) > 33 {
+			ns = ns[:1] + "*" + ns[(len(ns)-31):]
+		}
+		filter := strings.ReplaceAll(op.Filter, "\n", " ")
+		if len(filter) > 60 {
+			filter = filter[:57] + "..."
+		}
+		buffer.WriteString(fmt.Sprintf("|%v%-9s%v %-10s %8s %8s %7s %7d %7d %-33s %-62s|\n", color, diff.Status, tail,
+			command, before, after, change, beforeCount, afterCount, ns, filter))
+	}
+	buffer.WriteString("+---------+----------+--------+--------+-------+-------+-------+---------------------------------+--------------------------------------------------------------+\n")
+	return buffer.String()
+}
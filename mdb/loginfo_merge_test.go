@@ -0,0 +1,55 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func getTestLogInfo(t *testing.T, milli map[string]int) *LogInfo {
+	lines := []string{}
+	for field, ms := range milli {
+		lines = append(lines, fmt.Sprintf(`{"t":{"$date":"2020-09-28T11:13:09.234+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"db.c","command":{"find":"c","filter":{"%v":1}},"planSummary":"COLLSCAN","durationMillis":%d}}`, field, ms))
+	}
+	loginfo := NewLogInfo("utest-xxxxxx")
+	loginfo.SetSilent(true)
+	if err := loginfo.Parse(bufio.NewReader(strings.NewReader(strings.Join(lines, "\n")))); err != nil {
+		t.Fatal(err)
+	}
+	return loginfo
+}
+
+func TestLogInfoMerge(t *testing.T) {
+	loginfo := NewLogInfo("utest-xxxxxx")
+	loginfo.Merge(getTestLogInfo(t, map[string]int{"a": 100, "b": 200}))
+	loginfo.Merge(getTestLogInfo(t, map[string]int{"a": 300}))
+	if len(loginfo.OpPatterns) != 2 || len(loginfo.Histogram) != 1 || loginfo.Histogram[0].Ops["find"] != 3 {
+		t.Fatal(loginfo.OpPatterns, loginfo.Histogram)
+	}
+	op := loginfo.OpPatterns[0]
+	if op.Filter != `{"a":1}` {
+		op = loginfo.OpPatterns[1]
+	}
+	if op.Count != 2 || op.TotalMilli != 400 || op.MaxMilli != 300 || op.Latency.Count != 2 {
+		t.Fatal(op)
+	}
+}
+
+func TestLogInfoDiff(t *testing.T) {
+	before := getTestLogInfo(t, map[string]int{"a": 100, "b": 200, "c": 300})
+	after := getTestLogInfo(t, map[string]int{"a": 300, "b": 100, "d": 50})
+	status := map[string]string{}
+	for _, diff := range after.Diff(before) {
+		op := diff.After
+		if op == nil {
+			op = diff.Before
+		}
+		status[op.Filter] = diff.Status
+	}
+	if status[`{"a":1}`] != "slower" || status[`{"b":1}`] != "faster" || status[`{"c":1}`] != "gone" || status[`{"d":1}`] != "new" {
+		t.Fatal(status)
+	}
+}
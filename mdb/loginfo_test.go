@@ -33,3 +33,46 @@ func TestLoginfoParse(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestLogInfoTimeRange(t *testing.T) {
+	lines := []string{
+		`{"t":{"$date":"2020-09-28T11:13:09.234+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"db.c","command":{"find":"c","filter":{"a":1}},"planSummary":"COLLSCAN","durationMillis":150}}`,
+		`{"t":{"$date":"2020-09-29T11:13:09.234+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"db.c","command":{"find":"c","filter":{"b":1}},"planSummary":"COLLSCAN","durationMillis":150}}`,
+	}
+	loginfo := NewLogInfo("utest-xxxxxx")
+	loginfo.SetSilent(true)
+	if err := loginfo.SetTimeRange("2020-09-29", ""); err != nil {
+		t.Fatal(err)
+	}
+	rd := bufio.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	if err := loginfo.Parse(rd); err != nil {
+		t.Fatal(err)
+	}
+	if len(loginfo.OpPatterns) != 1 || loginfo.OpPatterns[0].Filter != `{"b":1}` {
+		t.Fatal(loginfo.OpPatterns)
+	}
+	if err := loginfo.SetTimeRange("2020-09-29T00:00:00Z", "2020-09-28"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLogInfoGroupByQueryHash(t *testing.T) {
+	lines := []string{
+		`{"t":{"$date":"2020-09-28T11:13:09.234+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"db.c","command":{"find":"c","filter":{"a":1,"b":2}},"planSummary":"IXSCAN { a: 1 }","queryHash":"16FE1304","planCacheKey":"ADE4584D","durationMillis":150}}`,
+		`{"t":{"$date":"2020-09-28T11:13:10.234+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"db.c","command":{"find":"c","filter":{"b":2,"a":1}},"planSummary":"IXSCAN { b: 1 }","queryHash":"16FE1304","planCacheKey":"ADE4584D","durationMillis":250}}`,
+		`{"t":{"$date":"2020-09-28T11:13:11.234+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"db.c","command":{"find":"c","filter":{"a":1,"b":2}},"planSummary":"IXSCAN { a: 1 }","queryHash":"16FE1304","planCacheKey":"ADE4584D","durationMillis":150}}`,
+	}
+	loginfo := NewLogInfo("utest-xxxxxx")
+	loginfo.SetSilent(true)
+	loginfo.SetGroupByQueryHash(true)
+	rd := bufio.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	if err := loginfo.Parse(rd); err != nil {
+		t.Fatal(err)
+	}
+	if len(loginfo.OpPatterns) != 1 || loginfo.OpPatterns[0].Count != 3 || len(loginfo.OpPatterns[0].Plans) != 2 {
+		t.Fatal(loginfo.OpPatterns)
+	}
+	if plan := loginfo.OpPatterns[0].Plans[0]; plan.PlanSummary != "{ a: 1 }" || plan.Count != 2 {
+		t.Fatal(plan)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/simagix/gox"
 )
@@ -16,11 +17,26 @@ import (
 type Logv2 struct {
 	Attributes struct {
 		Command            map[string]interface{} `json:"command" bson:"command"`
+		DocsExamined       int                    `json:"docsExamined" bson:"docsExamined"`
+		KeysExamined       int                    `json:"keysExamined" bson:"keysExamined"`
+		Locks              map[string]interface{} `json:"locks" bson:"locks"`
 		Milli              int                    `json:"durationMillis" bson:"durationMillis"`
+		NDeleted           int                    `json:"ndeleted" bson:"ndeleted"`
+		NMatched           int                    `json:"nMatched" bson:"nMatched"`
+		NReturned          int                    `json:"nreturned" bson:"nreturned"`
 		NS                 string                 `json:"ns" bson:"ns"`
+		NumYields          int                    `json:"numYields" bson:"numYields"`
 		OriginatingCommand map[string]interface{} `json:"originatingCommand" bson:"originatingCommand"`
+		PlanCacheKey       string                 `json:"planCacheKey" bson:"planCacheKey"`
 		PlanSummary        string                 `json:"planSummary" bson:"planSummary"`
-		Type               string                 `json:"type" bson:"type"`
+		QueryHash          string                 `json:"queryHash" bson:"queryHash"`
+		Storage            struct {
+			Data struct {
+				BytesRead         int `json:"bytesRead" bson:"bytesRead"`
+				TimeReadingMicros int `json:"timeReadingMicros" bson:"timeReadingMicros"`
+			} `json:"data" bson:"data"`
+		} `json:"storage" bson:"storage"`
+		Type string `json:"type" bson:"type"`
 	} `json:"attr" bson:"attr"`
 	Component string            `json:"c" bson:"c"`
 	ID        int               `json:"id" bson:"id"`
@@ -56,6 +72,14 @@ func (li *LogInfo) ParseLogv2(str string) (LogStats, error) {
 		return stat, err
 	}
 	// bson.UnmarshalExtJSON([]byte(str), false, &doc)
+	if li.from.IsZero() == false || li.to.IsZero() == false {
+		var t time.Time
+		if t, err = time.Parse(time.RFC3339, doc.Timestamp["$date"]); err != nil {
+			return stat, err
+		} else if li.isInTimeRange(t) == false {
+			return stat, errors.New("out of time range")
+		}
+	}
 	c := doc.Component
 	if c != "COMMAND" && c != "QUERY" && c != "WRITE" {
 		return stat, errors.New("unsupported command")
@@ -167,43 +191,80 @@ func (li *LogInfo) ParseLogv2(str string) (LogStats, error) {
 		} else {
 			return stat, errors.New("no filter found")
 		}
-		if isRegex(fmap) == false {
-			walker := gox.NewMapWalker(cb)
-			doc := walker.Walk(fmap)
-			var data []byte
-			if data, err = json.Marshal(doc); err != nil {
-				return stat, err
-			}
-			stat.filter = string(data)
-			if stat.filter == `{"":null}` {
-				stat.filter = "{}"
-			}
-		} else {
-			buf, _ := json.Marshal(fmap)
-			str := string(buf)
-			re := regexp.MustCompile(`{(.*):{"\$regularExpression":{"options":"(\S+)?","pattern":"(\^)?(\S+)"}}}`)
-			stat.filter = re.ReplaceAllString(str, "{$1:/$3.../$2}")
+		if stat.filter, err = getQueryPattern(fmap); err != nil {
+			return stat, err
 		}
 	}
 	if stat.op == "" {
 		return stat, nil
 	}
-	re := regexp.MustCompile(`\[(1,)*1\]`)
-	stat.filter = re.ReplaceAllString(stat.filter, `[...]`)
-	re = regexp.MustCompile(`^{("\$match"|"\$sort"):(\S+)}$`)
-	stat.filter = re.ReplaceAllString(stat.filter, `$2`)
-	re = regexp.MustCompile(`^{("(\$facet")):\S+}$`)
-	stat.filter = re.ReplaceAllString(stat.filter, `{$1:...}`)
-	re = regexp.MustCompile(`{"\$oid":1}`)
-	stat.filter = re.ReplaceAllString(stat.filter, `1`)
+	stat.filter = tidyQueryPattern(stat.filter)
 	if isGetMore {
 		stat.op = cmdGetMore
 	}
 	utc := doc.Timestamp["$date"][:15] + `0:00Z` // todo doc.Timestamp.Format(time.RFC3339)[:15]
 	stat.utc = utc
+	attr := doc.Attributes
+	stat.bytesRead = attr.Storage.Data.BytesRead
+	stat.docsExamined = attr.DocsExamined
+	stat.keysExamined = attr.KeysExamined
+	stat.lockWaitMicros = getLockWaitMicros(attr.Locks)
+	stat.nreturned = attr.NReturned + attr.NMatched + attr.NDeleted // at most one is logged
+	stat.numYields = attr.NumYields
+	stat.planCacheKey = attr.PlanCacheKey
+	stat.queryHash = attr.QueryHash
+	stat.readMicros = attr.Storage.Data.TimeReadingMicros
 	return stat, nil
 }
 
+// getQueryPattern returns the query pattern of a filter, values are replaced with 1
+func getQueryPattern(fmap map[string]interface{}) (string, error) {
+	if isRegex(fmap) == true {
+		buf, _ := json.Marshal(fmap)
+		re := regexp.MustCompile(`{(.*):{"\$regularExpression":{"options":"(\S+)?","pattern":"(\^)?(\S+)"}}}`)
+		return re.ReplaceAllString(string(buf), "{$1:/$3.../$2}"), nil
+	}
+	walker := gox.NewMapWalker(cb)
+	doc := walker.Walk(fmap)
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	if string(data) == `{"":null}` {
+		return "{}", nil
+	}
+	return string(data), nil
+}
+
+// tidyQueryPattern shortens arrays, stages, and object ids of a query pattern
+func tidyQueryPattern(filter string) string {
+	re := regexp.MustCompile(`\[(1,)*1\]`)
+	filter = re.ReplaceAllString(filter, `[...]`)
+	re = regexp.MustCompile(`^{("\$match"|"\$sort"):(\S+)}$`)
+	filter = re.ReplaceAllString(filter, `$2`)
+	re = regexp.MustCompile(`^{("(\$facet")):\S+}$`)
+	filter = re.ReplaceAllString(filter, `{$1:...}`)
+	re = regexp.MustCompile(`{"\$oid":1}`)
+	return re.ReplaceAllString(filter, `1`)
+}
+
+// getLockWaitMicros sums timeAcquiringMicros of all lock resources
+func getLockWaitMicros(locks map[string]interface{}) int {
+	micros := 0
+	for _, v := range locks {
+		resource, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if wait, ok := resource["timeAcquiringMicros"].(map[string]interface{}); ok {
+			for _, t := range wait {
+				micros += toInt(t)
+			}
+		}
+	}
+	return micros
+}
+
 func isRegex(doc map[string]interface{}) bool {
 	if buf, err := json.Marshal(doc); err != nil {
 		return false
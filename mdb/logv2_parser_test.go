@@ -62,3 +62,16 @@ func TestParseLogv2BSON(t *testing.T) {
 		t.Fatal(`expected COMMAND but got`, doc.Component)
 	}
 }
+
+func TestParseLogv2Metrics(t *testing.T) {
+	str := `{"t":{"$date":"2020-09-28T11:13:09.234+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"db.c","command":{"find":"c","filter":{"a":1}},"planSummary":"COLLSCAN","keysExamined":0,"docsExamined":5000,"nreturned":5,"numYields":7,"queryHash":"16FE1304","planCacheKey":"ADE4584D","locks":{"Global":{"acquireCount":{"r":1}},"Collection":{"acquireCount":{"r":1},"timeAcquiringMicros":{"r":100,"w":50}}},"storage":{"data":{"bytesRead":24428,"timeReadingMicros":23}},"durationMillis":150}}`
+	loginfo := NewLogInfo("utest-xxxxxx")
+	stat, err := loginfo.ParseLogv2(str)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.docsExamined != 5000 || stat.nreturned != 5 || stat.numYields != 7 || stat.lockWaitMicros != 150 ||
+		stat.bytesRead != 24428 || stat.readMicros != 23 || stat.queryHash != "16FE1304" || stat.planCacheKey != "ADE4584D" {
+		t.Fatal(stat)
+	}
+}
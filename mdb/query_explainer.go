@@ -30,17 +30,25 @@ type QueryExplainer struct {
 	client     *mongo.Client
 	document   bson.D
 	isSharded  bool
+	lookups    []ExplainCommand // of $lookup sub-pipelines
+	pushdown   []string         // notes of $match and $sort pushdown of an aggregate
 	shardUsed  int
 	verbose    bool
 }
 
 // ExplainCommand stores explain document
 type ExplainCommand struct {
-	Collection string `bson:"find"`
-	Filter     bson.D `bson:"filter"`
-	Sort       bson.D `bson:"sort,omitempty"`
-	Hint       bson.D `bson:"hint,omitempty"`
-	Group      string `bson:"group,omitempty"`
+	Collection string      `bson:"find"`
+	Filter     bson.D      `bson:"filter"`
+	Sort       bson.D      `bson:"sort,omitempty"`
+	Hint       bson.D      `bson:"hint,omitempty"`
+	Group      string      `bson:"group,omitempty"`
+	Command    string      `bson:"command,omitempty"` // find if empty, aggregate, update, delete, findandmodify, count, or distinct
+	Key        string      `bson:"key,omitempty"`     // of distinct
+	Multi      bool        `bson:"multi,omitempty"`
+	Pipeline   []bson.D    `bson:"pipeline,omitempty"`
+	Remove     bool        `bson:"remove,omitempty"`
+	Update     interface{} `bson:"update,omitempty"`
 }
 
 type inputStagesLevel struct {
@@ -68,6 +76,9 @@ type ExplainSummary struct {
 	ShardName              string       `json:"shardName"`
 	ExecutionStats         StageStats   `json:"executionStats"`
 	AllPlansExecutionStats []StageStats `json:"allPlansExecution"`
+	Shards                 []ShardStats `json:"shards,omitempty"`
+	Merge                  MergeStats   `json:"merge"`
+	Targeting              string       `json:"targeting,omitempty"`
 }
 
 // IndexScore keeps index score
@@ -94,26 +105,48 @@ func (qe *QueryExplainer) GetFilter() bson.D {
 // Explain explains query plans
 func (qe *QueryExplainer) Explain() (ExplainSummary, error) {
 	var err error
-	var command bson.D
-	o := QueryExplainer{}
-	b, _ := bson.Marshal(qe)
-	bson.Unmarshal(b, &o)
-	o.ExplainCmd.Group = "" // remove group from index evaluation
-	b, _ = bson.Marshal(o)
-	bson.Unmarshal(b, &command)
+	var raw bson.D
+	command := qe.ExplainCmd.getCommand()
 	db := strings.Split(qe.NameSpace, ".")[0]
-	if err = qe.client.Database(db).RunCommand(context.Background(), command).Decode(&qe.document); err != nil {
+	if err = qe.client.Database(db).RunCommand(context.Background(), command).Decode(&raw); err != nil {
 		return ExplainSummary{}, err
 	}
+	return qe.getExplainSummary(raw)
+}
+
+// getExplainSummary returns summary of an explain output, or an error if it has no query planner, e.g. of
+// $collStats, $indexStats, or $currentOp
+func (qe *QueryExplainer) getExplainSummary(raw bson.D) (ExplainSummary, error) {
+	var err error
+	var shardName string
+	qe.document, shardName = getCursorExplain(raw)
 	doc := qe.document.Map()
-	winStage := doc["queryPlanner"].(bson.D).Map()["winningPlan"].(bson.D).Map()["stage"].(string)
+	queryPlanner, ok := doc["queryPlanner"].(bson.D)
+	if ok == false {
+		return ExplainSummary{}, errors.New("no query planner in explain output")
+	}
+	winningPlan, ok := queryPlanner.Map()["winningPlan"].(bson.D)
+	if ok == false {
+		return ExplainSummary{}, errors.New("no winning plan in explain output")
+	}
+	if _, ok = doc["executionStats"].(bson.D); ok == false {
+		return ExplainSummary{}, errors.New("no execution stats in explain output")
+	}
+	winStage, _ := winningPlan.Map()["stage"].(string)
 	if winStage == "EOF" {
 		return ExplainSummary{}, errors.New("no data found to be explained")
 	} else if winStage == "COLLSCAN" {
 		return ExplainSummary{}, errors.New("no index selected (COLLSCAN)")
 	}
-
-	return qe.GetExplainDetails(doc), err
+	summary := qe.GetExplainDetails(doc)
+	if shardName != "" { // an aggregate across shards
+		summary.ShardName = shardName
+		summary.Shards, summary.Merge = getShardsBreakdown(raw.Map())
+	}
+	if len(summary.Shards) > 0 {
+		qe.setTargeting(&summary)
+	}
+	return summary, err
 }
 
 // GetExplainDetails returns summary from a doc
@@ -123,10 +156,13 @@ func (qe *QueryExplainer) GetExplainDetails(doc bson.M) ExplainSummary {
 	if winningPlan["shards"] != nil {
 		qe.isSharded = true
 	}
+	if qe.isSharded {
+		summary.Shards, summary.Merge = getShardsBreakdown(doc)
+	}
 	summary.ExecutionStats = qe.getStageStats(doc["executionStats"].(bson.D))
 	summary.AllPlansExecutionStats = []StageStats{}
 
-	allPlansExecution := doc["executionStats"].(bson.D).Map()["allPlansExecution"].(primitive.A)
+	allPlansExecution, _ := doc["executionStats"].(bson.D).Map()["allPlansExecution"].(primitive.A)
 	// pick a shard to evaluate if a sharded cluster
 	if qe.isSharded == true && len(allPlansExecution) > 0 {
 		maxReturned := int32(0)
@@ -166,6 +202,16 @@ func (qe *QueryExplainer) GetSummary(summary ExplainSummary) string {
 	bson.Unmarshal(b, &qshape)
 	delete(qshape, "find")
 	buffer.WriteString("Query Shape:\n" + gox.Stringify(qshape, "", "  ") + "\n")
+	if len(summary.Shards) > 0 {
+		buffer.WriteString(getShardsSummaryString(summary))
+	}
+	if len(qe.pushdown) > 0 {
+		buffer.WriteString("\n=> Pipeline Pushdown\n")
+		buffer.WriteString("=========================================\n")
+		for _, note := range qe.pushdown {
+			buffer.WriteString(note + "\n")
+		}
+	}
 	buffer.WriteString("\n=> Execution Stats\n")
 	buffer.WriteString("=========================================\n")
 	buffer.WriteString("Winning Plan:\n")
@@ -333,25 +379,23 @@ func (qe *QueryExplainer) GetIndexesScores(keys []string) []IndexScore {
 	for i := 0; i < len(keys); i++ {
 		keyMap[keys[i]] = "v"
 	}
+	if qe.ExplainCmd.Command == cmdDistinct { // hint is not supported
+		return scores
+	}
 	// Execute explain on all indexes
 	for _, index := range indexes {
 		bson.UnmarshalExtJSON([]byte(index), true, &qe.ExplainCmd.Hint)
-		o := QueryExplainer{}
-		b, _ := bson.Marshal(qe)
-		bson.Unmarshal(b, &o)
-		o.ExplainCmd.Group = "" // remove group from index evaluation
-		b, _ = bson.Marshal(o)
-		var cmd bson.D
-		bson.Unmarshal(b, &cmd)
-		filter := cmd.Map()["explain"].(bson.D).Map()["hint"].(bson.D)
-		if len(filter) == 0 || keyMap[filter[0].Key] == "" {
+		hint := qe.ExplainCmd.Hint
+		if len(hint) == 0 || keyMap[hint[0].Key] == "" {
 			continue
 		}
+		cmd := qe.ExplainCmd.getCommand()
 		var document = bson.D{}
 		if err = collection.Database().RunCommand(ctx, cmd).Decode(&document); err != nil {
 			fmt.Println(err.Error())
 			continue
 		}
+		document, _ = getCursorExplain(document)
 		summary := qe.GetExplainDetails(document.Map())
 		stages := []string{}
 		for _, elem := range summary.ExecutionStats.InputStages {
@@ -381,6 +425,13 @@ func (qe *QueryExplainer) ReadQueryShape(buffer []byte) error {
 	var doc bson.D
 	var ns string
 	explainCmd := ExplainCommand{}
+	qe.pushdown, qe.lookups = nil, nil
+	if err = bson.UnmarshalExtJSON(buffer, false, &doc); err == nil { // logv2 of relaxed dates
+		if attr, ok := doc.Map()["attr"].(bson.D); ok {
+			return qe.readLogv2QueryShape(attr.Map())
+		}
+	}
+	doc = nil
 	if err = bson.UnmarshalExtJSON(buffer, true, &doc); err == nil {
 		if doc.Map()["filter"] != nil {
 			explainCmd.Filter = doc.Map()["filter"].(bson.D)
@@ -42,3 +42,16 @@ func TestGetExplainSummaryShard(t *testing.T) {
 	bson.Unmarshal(data, &v)
 	t.Log(qa.GetExplainDetails(v["explain"].(bson.M)))
 }
+
+func TestGetExplainSummaryNoQueryPlanner(t *testing.T) {
+	qe := NewQueryExplainer(nil)
+	raw := bson.D{{Key: "stages", Value: bson.A{bson.D{{Key: "$collStats", Value: bson.D{{Key: "latencyStats", Value: bson.D{}}}}}}},
+		{Key: "ok", Value: float64(1)}}
+	if _, err := qe.getExplainSummary(raw); err == nil || err.Error() != "no query planner in explain output" {
+		t.Fatal(err)
+	}
+	raw = bson.D{{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "IXSCAN"}}}}}}
+	if _, err := qe.getExplainSummary(raw); err == nil || err.Error() != "no execution stats in explain output" {
+		t.Fatal(err)
+	}
+}
@@ -94,3 +94,32 @@ func GetAllServerURIs(shards []Shard, connString connstring.ConnString) ([]strin
 	}
 	return list, nil
 }
+
+// GetMemberURIs returns URIs of all data bearing members of a replica set, or of all shards if connected
+// to a mongos, and none of a standalone
+func GetMemberURIs(client *mongo.Client, connString connstring.ConnString) ([]string, error) {
+	var err error
+	var shards []Shard
+	var ss ServerStatus
+	if ss, err = GetServerStatus(client); err != nil {
+		return nil, err
+	}
+	if ss.Process == "mongos" {
+		if shards, err = GetShards(client); err != nil {
+			return nil, err
+		}
+	} else if ss.Repl.SetName != "" {
+		var rs ReplSetGetStatus
+		if rs, err = GetReplSetGetStatus(client); err != nil {
+			return nil, err
+		}
+		hosts := []string{}
+		for _, member := range rs.Members {
+			if member.StateStr != "ARBITER" {
+				hosts = append(hosts, member.Name)
+			}
+		}
+		shards = []Shard{{ID: rs.Set, Host: rs.Set + "/" + strings.Join(hosts, ",")}}
+	}
+	return GetAllServerURIs(shards, connString)
+}
@@ -3,9 +3,12 @@
 package mdb
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -120,3 +123,27 @@ func emptyBinData(firstDoc bson.M) bson.M {
 	}
 	return firstDoc
 }
+
+// ParseTimeBound parses a RFC3339 timestamp, a date (2006-01-02), or a duration relative
+// to now (e.g. 2h). Same as Atlas endDate, a date as an end bound includes the whole day.
+func ParseTimeBound(str string, isEnd bool) (time.Time, error) {
+	var err error
+	var t time.Time
+	var d time.Duration
+	if str == "" {
+		return t, err
+	}
+	if t, err = time.Parse(time.RFC3339, str); err == nil {
+		return t, err
+	}
+	if t, err = time.Parse("2006-01-02", str); err == nil {
+		if isEnd {
+			t = t.Add(time.Hour*24 - time.Nanosecond)
+		}
+		return t, err
+	}
+	if d, err = time.ParseDuration(strings.TrimPrefix(str, "-")); err == nil {
+		return time.Now().Add(-d), err
+	}
+	return t, errors.New("invalid time " + str + ", use RFC3339, 2006-01-02, or a duration, e.g. 2h")
+}
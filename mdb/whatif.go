@@ -0,0 +1,392 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/simagix/gox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const whatIfPrefix = "keyhole_whatif_"
+
+// DefaultWhatIfSample is number of documents of a scratch copy of a collection
+const DefaultWhatIfSample = 1000
+
+// WhatIf evaluates a candidate index by explaining query shapes of a log file before and after
+// creating it on a sampled scratch copy of a collection with its indexes, and drops the copy afterwards.
+// The candidate is created on the collection itself only if modifyProduction is set.
+type WhatIf struct {
+	client           *mongo.Client
+	hidden           bool // build the candidate hidden (4.4+), it is unhidden to explain and production queries may use it
+	keepIndex        bool // keep the candidate on the collection, with modifyProduction
+	key              bson.D
+	modifyProduction bool // create the candidate on the collection instead of a scratch copy
+	sample           int  // documents of a scratch copy
+	verbose          bool
+}
+
+// WhatIfPlan is a winning plan and documents examined
+type WhatIfPlan struct {
+	DocsExamined int
+	KeysExamined int
+	Plan         string
+}
+
+// WhatIfResult is a query shape explained before and after creating a candidate index
+type WhatIfResult struct {
+	After     WhatIfPlan
+	Before    WhatIfPlan
+	Namespace string
+	Shape     string
+}
+
+// NewWhatIf returns WhatIf of a candidate index key, e.g. {a:1,b:-1}
+func NewWhatIf(client *mongo.Client, key string) (*WhatIf, error) {
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON([]byte(toShapeJSON(key)), false, &doc); err != nil {
+		return nil, fmt.Errorf("invalid index key %v: %v", key, err)
+	} else if len(doc) == 0 {
+		return nil, errors.New("empty index key")
+	}
+	return &WhatIf{client: client, key: doc, sample: DefaultWhatIfSample}, nil
+}
+
+// SetHidden sets to build the candidate index hidden on the collection (with modifyProduction)
+func (w *WhatIf) SetHidden(hidden bool) {
+	w.hidden = hidden
+}
+
+// SetKeepIndex sets to keep the candidate index on the collection (with modifyProduction)
+func (w *WhatIf) SetKeepIndex(keepIndex bool) {
+	w.keepIndex = keepIndex
+}
+
+// SetModifyProduction sets to create the candidate index on the collection instead of a scratch copy
+func (w *WhatIf) SetModifyProduction(modifyProduction bool) {
+	w.modifyProduction = modifyProduction
+}
+
+// SetSample sets number of documents of a scratch copy of a collection
+func (w *WhatIf) SetSample(sample int) {
+	if sample > 0 {
+		w.sample = sample
+	}
+}
+
+// SetVerbose sets verbosity
+func (w *WhatIf) SetVerbose(verbose bool) {
+	w.verbose = verbose
+}
+
+// getIndexName returns name of the candidate index
+func (w *WhatIf) getIndexName() string {
+	fields := []string{}
+	for _, e := range w.key {
+		fields = append(fields, fmt.Sprintf("%v_%v", e.Key, e.Value))
+	}
+	return whatIfPrefix + strings.Join(fields, "_")
+}
+
+// Evaluate explains query shapes of a log file, on collections of shapes referencing the leading
+// field of the candidate index, before and after creating the candidate
+func (w *WhatIf) Evaluate(filename string) ([]WhatIfResult, error) {
+	var err error
+	var reader *bufio.Reader
+	if reader, err = gox.NewFileReader(filename); err != nil {
+		return nil, err
+	}
+	if w.hidden && w.modifyProduction {
+		var buildInfo BuildInfo
+		if buildInfo, err = GetBuildInfo(w.client); err != nil {
+			return nil, err
+		} else if isVersionAtLeast(buildInfo.Version, 4, 4) == false {
+			return nil, fmt.Errorf("hidden indexes require 4.4+, server version %v", buildInfo.Version)
+		}
+	}
+	shapes := map[string][]ExplainCommand{}
+	namespaces := []string{}
+	existed := map[string]bool{}
+	qe := NewQueryExplainer(w.client)
+	for {
+		buffer, _, rerr := reader.ReadLine()
+		if rerr != nil {
+			break
+		} else if isSlowOpLog(string(buffer)) == false {
+			continue
+		}
+		if err = qe.ReadQueryShape(buffer); err != nil {
+			continue
+		}
+		keys := append(GetKeys(qe.ExplainCmd.Filter), GetKeys(qe.ExplainCmd.Sort)...)
+		if contains(keys, w.key[0].Key) == false {
+			continue
+		}
+		id := qe.NameSpace + gox.Stringify(qe.ExplainCmd.Filter) + gox.Stringify(qe.ExplainCmd.Sort)
+		if existed[id] {
+			continue
+		}
+		existed[id] = true
+		if shapes[qe.NameSpace] == nil {
+			namespaces = append(namespaces, qe.NameSpace)
+		}
+		shapes[qe.NameSpace] = append(shapes[qe.NameSpace], qe.ExplainCmd)
+	}
+	results := []WhatIfResult{}
+	for _, ns := range namespaces {
+		list, err := w.evaluateNamespace(ns, shapes[ns])
+		if err != nil {
+			return results, err
+		}
+		results = append(results, list...)
+	}
+	return results, nil
+}
+
+// evaluateNamespace explains shapes of a namespace before and after creating the candidate index, on a
+// scratch copy with indexes of the collection unless modifyProduction is set
+func (w *WhatIf) evaluateNamespace(ns string, cmds []ExplainCommand) ([]WhatIfResult, error) {
+	var err error
+	ctx := context.Background()
+	pos := strings.Index(ns, ".")
+	if pos < 0 {
+		return nil, errors.New("invalid namespace " + ns)
+	}
+	db := w.client.Database(ns[:pos])
+	collName := ns[pos+1:]
+	if w.modifyProduction == false {
+		collName = getScratchName(collName)
+		// create fails if the collection exists, only a collection created by this run is dropped
+		if err = db.RunCommand(ctx, bson.D{{Key: "create", Value: collName}}).Err(); err != nil {
+			return nil, fmt.Errorf("scratch collection %v not created, %v", collName, err)
+		}
+		defer db.Collection(collName).Drop(ctx)
+		if err = w.copySample(db, ns[pos+1:], collName); err != nil {
+			return nil, err
+		}
+	}
+	results := []WhatIfResult{}
+	for _, cmd := range cmds {
+		result := WhatIfResult{Namespace: ns, Shape: getWhatIfShape(cmd)}
+		if result.Before, err = w.explain(db, collName, cmd); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	name := w.getIndexName()
+	coll := db.Collection(collName)
+	hidden := w.hidden && w.modifyProduction
+	if hidden { // no plan uses the candidate while building, until unhidden to explain
+		cmd := bson.D{{Key: "createIndexes", Value: collName}, {Key: "indexes", Value: bson.A{
+			bson.D{{Key: "key", Value: w.key}, {Key: "name", Value: name}, {Key: "hidden", Value: true}}}}}
+		if err = db.RunCommand(ctx, cmd).Err(); err != nil {
+			return nil, err
+		}
+	} else if _, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: w.key,
+		Options: options.Index().SetName(name)}); err != nil {
+		return nil, err
+	}
+	if w.modifyProduction && w.keepIndex {
+		if hidden {
+			defer setIndexHidden(db, collName, name, true)
+		}
+	} else if w.modifyProduction {
+		defer coll.Indexes().DropOne(ctx, name)
+	}
+	if hidden {
+		if err = setIndexHidden(db, collName, name, false); err != nil {
+			return nil, err
+		}
+	}
+	for i, cmd := range cmds {
+		if results[i].After, err = w.explain(db, collName, cmd); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// getScratchName returns a unique name of a scratch copy of a collection
+func getScratchName(collName string) string {
+	return fmt.Sprintf("%v%v_%v", whatIfPrefix, collName, primitive.NewObjectID().Hex())
+}
+
+// copySample copies sampled documents of a collection to a scratch collection and creates indexes of the
+// collection on it, $out does not copy indexes
+func (w *WhatIf) copySample(db *mongo.Database, source string, scratch string) error {
+	var err error
+	var cur *mongo.Cursor
+	ctx := context.Background()
+	pipeline := mongo.Pipeline{{{Key: "$sample", Value: bson.D{{Key: "size", Value: w.sample}}}},
+		{{Key: "$out", Value: scratch}}}
+	if cur, err = db.Collection(source).Aggregate(ctx, pipeline); err != nil {
+		return err
+	}
+	cur.Close(ctx)
+	if cur, err = db.Collection(source).Indexes().List(ctx); err != nil {
+		return err
+	}
+	var indexes []bson.D
+	if err = cur.All(ctx, &indexes); err != nil {
+		return err
+	}
+	if specs := getScratchIndexSpecs(indexes); len(specs) > 0 {
+		cmd := bson.D{{Key: "createIndexes", Value: scratch}, {Key: "indexes", Value: specs}}
+		if err = db.RunCommand(ctx, cmd).Err(); err != nil {
+			return err
+		}
+	}
+	if w.verbose {
+		log.Printf("sampled %d documents of %v to %v with %d index(es)\n", w.sample, source, scratch, len(indexes))
+	}
+	return nil
+}
+
+// getScratchIndexSpecs returns index specs of a collection to create on a scratch copy, other than _id and
+// without version, namespace, and TTL
+func getScratchIndexSpecs(indexes []bson.D) bson.A {
+	specs := bson.A{}
+	for _, index := range indexes {
+		if index.Map()["name"] == "_id_" {
+			continue
+		}
+		spec := bson.D{}
+		for _, e := range index {
+			if e.Key != "v" && e.Key != "ns" && e.Key != "expireAfterSeconds" {
+				spec = append(spec, e)
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// setIndexHidden hides or unhides an index by collMod
+func setIndexHidden(db *mongo.Database, collName string, name string, hidden bool) error {
+	cmd := bson.D{{Key: "collMod", Value: collName},
+		{Key: "index", Value: bson.D{{Key: "name", Value: name}, {Key: "hidden", Value: hidden}}}}
+	return db.RunCommand(context.Background(), cmd).Err()
+}
+
+// explain returns the winning plan and documents examined of a query shape
+func (w *WhatIf) explain(db *mongo.Database, collName string, cmd ExplainCommand) (WhatIfPlan, error) {
+	cmd.Collection = collName
+	cmd.Hint = nil
+	command := append(cmd.getCommand(), bson.E{Key: "verbosity", Value: "executionStats"})
+	var doc bson.D
+	if err := db.RunCommand(context.Background(), command).Decode(&doc); err != nil {
+		return WhatIfPlan{}, err
+	}
+	doc, _ = getCursorExplain(doc)
+	return getWhatIfPlan(doc), nil
+}
+
+// getWhatIfPlan returns the winning plan and documents examined of an explain output
+func getWhatIfPlan(doc bson.D) WhatIfPlan {
+	plan := WhatIfPlan{}
+	m := doc.Map()
+	if queryPlanner, ok := m["queryPlanner"].(bson.D); ok {
+		if winningPlan, ok := queryPlanner.Map()["winningPlan"].(bson.D); ok {
+			if shards, ok := winningPlan.Map()["shards"].(primitive.A); ok && len(shards) > 0 {
+				if shard, ok := shards[0].(bson.D); ok {
+					winningPlan, _ = shard.Map()["winningPlan"].(bson.D)
+				}
+			}
+			plan.Plan = getPlanSummary(winningPlan)
+		}
+	}
+	if executionStats, ok := m["executionStats"].(bson.D); ok {
+		plan.DocsExamined = toInt(executionStats.Map()["totalDocsExamined"])
+		plan.KeysExamined = toInt(executionStats.Map()["totalKeysExamined"])
+	}
+	return plan
+}
+
+// getPlanSummary returns stages of a plan, e.g. FETCH > IXSCAN { a: 1, b: -1 }
+func getPlanSummary(plan bson.D) string {
+	stages := []string{}
+	for len(plan) > 0 {
+		m := plan.Map()
+		stage, _ := m["stage"].(string)
+		if keyPattern, ok := m["keyPattern"].(bson.D); ok {
+			stage += " " + getKeyString(keyPattern)
+		}
+		stages = append(stages, stage)
+		if inputStage, ok := m["inputStage"].(bson.D); ok {
+			plan = inputStage
+		} else if inputStages, ok := m["inputStages"].(primitive.A); ok && len(inputStages) > 0 {
+			plan, _ = inputStages[0].(bson.D)
+		} else {
+			plan = nil
+		}
+	}
+	return strings.Join(stages, " > ")
+}
+
+// getWhatIfShape returns a string of the command, filter, and sort of a query shape
+func getWhatIfShape(cmd ExplainCommand) string {
+	filter, _ := bson.MarshalExtJSON(cmd.Filter, false, false)
+	shape := string(filter)
+	if len(cmd.Sort) > 0 {
+		sort, _ := bson.MarshalExtJSON(cmd.Sort, false, false)
+		shape = fmt.Sprintf("%v, sort: %v", shape, string(sort))
+	}
+	if cmd.Command != "" && cmd.Command != cmdFind {
+		shape = cmd.Command + " " + shape
+	}
+	return shape
+}
+
+// isVersionAtLeast returns true if a version, e.g. 4.4.1, is at least of a major and a minor
+func isVersionAtLeast(version string, major int, minor int) bool {
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	vmajor, _ := strconv.Atoi(parts[0])
+	vminor, _ := strconv.Atoi(parts[1])
+	return vmajor > major || (vmajor == major && vminor >= minor)
+}
+
+// GetReport returns plan and documents examined changes of query shapes
+func (w *WhatIf) GetReport(results []WhatIfResult) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("\n--- What-If Index %v ---\n", getKeyString(w.key)))
+	if len(results) == 0 {
+		buffer.WriteString("No query shape references " + w.key[0].Key + "\n")
+	}
+	changed := 0
+	for _, r := range results {
+		if r.After.DocsExamined < r.Before.DocsExamined || r.After.Plan != r.Before.Plan {
+			changed++
+		}
+		buffer.WriteString(fmt.Sprintf("%v %v\n", r.Namespace, r.Shape))
+		buffer.WriteString(fmt.Sprintf("\tplan: %v => %v\n", r.Before.Plan, r.After.Plan))
+		buffer.WriteString(fmt.Sprintf("\ttotalDocsExamined: %d => %d (%v)\n", r.Before.DocsExamined, r.After.DocsExamined,
+			formatDocsChange(r.Before.DocsExamined, r.After.DocsExamined)))
+		buffer.WriteString(fmt.Sprintf("\ttotalKeysExamined: %d => %d\n", r.Before.KeysExamined, r.After.KeysExamined))
+	}
+	buffer.WriteString(fmt.Sprintf("%d of %d shape(s) changed with the candidate index\n", changed, len(results)))
+	return buffer.String()
+}
+
+func formatDocsChange(before int, after int) string {
+	if before == 0 {
+		if after == 0 {
+			return "no change"
+		}
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%%", 100*float64(after-before)/float64(before))
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestNewWhatIf(t *testing.T) {
+	w, err := NewWhatIf(nil, "{a:1,b:-1}")
+	if err != nil || getKeyString(w.key) != "{ a: 1, b: -1 }" || w.getIndexName() != "keyhole_whatif_a_1_b_-1" {
+		t.Fatal(w, err)
+	}
+	for _, key := range []string{"{}", "a:1"} {
+		if _, err = NewWhatIf(nil, key); err == nil {
+			t.Fatal("expected error", key)
+		}
+	}
+}
+
+func TestGetWhatIfPlan(t *testing.T) {
+	ixscan := bson.D{{Key: "stage", Value: "IXSCAN"},
+		{Key: "keyPattern", Value: bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(-1)}}}}
+	doc := bson.D{
+		{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "FETCH"},
+			{Key: "inputStage", Value: ixscan}}}}},
+		{Key: "executionStats", Value: bson.D{{Key: "totalDocsExamined", Value: int32(12)},
+			{Key: "totalKeysExamined", Value: int64(15)}}},
+	}
+	plan := getWhatIfPlan(doc)
+	if plan.Plan != "FETCH > IXSCAN { a: 1, b: -1 }" || plan.DocsExamined != 12 || plan.KeysExamined != 15 {
+		t.Fatal(plan)
+	}
+	sharded := bson.D{{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "SHARD_MERGE"},
+		{Key: "shards", Value: bson.A{bson.D{{Key: "winningPlan", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}}}}}}}}}}
+	if plan = getWhatIfPlan(sharded); plan.Plan != "COLLSCAN" {
+		t.Fatal(plan)
+	}
+}
+
+func TestIsVersionAtLeast(t *testing.T) {
+	for version, expected := range map[string]bool{"4.4.1": true, "5.0.0": true, "4.2.10": false, "3.6": false, "": false} {
+		if isVersionAtLeast(version, 4, 4) != expected {
+			t.Fatal(version)
+		}
+	}
+}
+
+func TestWhatIfReport(t *testing.T) {
+	w, _ := NewWhatIf(nil, "{a:1}")
+	results := []WhatIfResult{{Namespace: "db.c", Shape: `{"a":1}`,
+		Before: WhatIfPlan{DocsExamined: 1000, Plan: "COLLSCAN"},
+		After:  WhatIfPlan{DocsExamined: 10, KeysExamined: 10, Plan: "FETCH > IXSCAN { a: 1 }"}}}
+	str := w.GetReport(results)
+	if strings.Contains(str, "plan: COLLSCAN => FETCH > IXSCAN { a: 1 }") == false ||
+		strings.Contains(str, "totalDocsExamined: 1000 => 10 (-99.0%)") == false ||
+		strings.Contains(str, "1 of 1 shape(s) changed") == false {
+		t.Fatal(str)
+	}
+}
+
+func TestGetScratchIndexSpecs(t *testing.T) {
+	indexes := []bson.D{
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}},
+		{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "a", Value: int32(1)}}}, {Key: "name", Value: "a_1"},
+			{Key: "ns", Value: "db.c"}, {Key: "expireAfterSeconds", Value: int32(60)}},
+		{{Key: "v", Value: int32(2)}, {Key: "unique", Value: true}, {Key: "key", Value: bson.D{{Key: "b", Value: int32(1)}}},
+			{Key: "name", Value: "b_1"}},
+	}
+	specs := getScratchIndexSpecs(indexes)
+	b, _ := bson.MarshalExtJSON(bson.D{{Key: "specs", Value: specs}}, false, false)
+	if string(b) != `{"specs":[{"key":{"a":1},"name":"a_1"},{"unique":true,"key":{"b":1},"name":"b_1"}]}` {
+		t.Fatal(string(b))
+	}
+}
+
+func TestGetScratchName(t *testing.T) {
+	name := getScratchName("orders")
+	if strings.HasPrefix(name, "keyhole_whatif_orders_") == false || name == getScratchName("orders") {
+		t.Fatal(name)
+	}
+}
+
+func TestWhatIfScratchCopyIndexes(t *testing.T) {
+	client := getMongoClient()
+	defer client.Disconnect(context.Background())
+	ctx := context.Background()
+	coll := client.Database("_KEYHOLE_WHATIF").Collection("orders")
+	defer coll.Drop(ctx)
+	docs := []interface{}{}
+	for i := 0; i < 100; i++ {
+		docs = append(docs, bson.D{{Key: "status", Value: i % 10}, {Key: "date", Value: i}})
+	}
+	if _, err := coll.InsertMany(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	dir, _ := ioutil.TempDir("", "whatif")
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "mongod.log")
+	line := getTestLogv2Line(`{"type":"command","ns":"_KEYHOLE_WHATIF.orders","command":{"find":"orders",` +
+		`"filter":{"status":1},"sort":{"date":-1}},"durationMillis":150}`)
+	ioutil.WriteFile(filename, []byte(line+"\n"), 0644)
+	w, _ := NewWhatIf(client, "{status:1,date:-1}")
+	w.SetSample(100)
+	results, err := w.Evaluate(filename)
+	if err != nil || len(results) != 1 {
+		t.Fatal(results, err)
+	}
+	if strings.Contains(results[0].Before.Plan, "IXSCAN { status: 1 }") == false ||
+		strings.Contains(results[0].After.Plan, "IXSCAN { status: 1, date: -1 }") == false {
+		t.Fatal(results[0])
+	}
+	names, _ := coll.Indexes().List(ctx)
+	var indexes []bson.D
+	names.All(ctx, &indexes)
+	if len(indexes) != 2 { // the collection is untouched
+		t.Fatal(indexes)
+	}
+	if names, _ := coll.Database().ListCollectionNames(ctx, bson.D{}); len(names) != 1 { // scratch copy dropped
+		t.Fatal(names)
+	}
+}
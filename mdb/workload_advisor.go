@@ -0,0 +1,433 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// QueryShape is a query shape of a log analysis with fields of equality, sort, and range
+type QueryShape struct {
+	Command    string
+	Count      int
+	Equality   []string
+	Filter     string
+	Namespace  string
+	Range      []string
+	Sort       bson.D
+	TotalMilli int
+}
+
+// IndexRecommendation is an index of a collection serving query shapes, an existing index or one to create
+type IndexRecommendation struct {
+	Existing   string // name of the existing index, empty if to create
+	Key        bson.D
+	Namespace  string
+	Redundant  []Index // existing indexes redundant with this one
+	Shapes     []QueryShape
+	TotalMilli int
+}
+
+// SkippedShape is an op pattern not supported by index recommendations
+type SkippedShape struct {
+	OpPattern OpPattern
+	Reason    string
+}
+
+// WorkloadAdvisor recommends a minimal index set per collection from query shapes of a log analysis,
+// weighted by total milliseconds, with one compound index in ESR (equality, sort, range) order serving
+// as many shapes as possible
+type WorkloadAdvisor struct {
+	indexes map[string][]Index // existing indexes by namespace
+	shapes  []QueryShape
+	skipped []SkippedShape
+}
+
+var workloadCommands = []string{"aggregate", "count", "delete", "distinct", "find", "findAndModify", "getMore",
+	"getmore", "remove", "update"}
+var equalityOperators = []string{"$all", "$elemMatch", "$eq", "$in"}
+var rangeOperators = []string{"$exists", "$gt", "$gte", "$lt", "$lte", "$mod", "$ne", "$nin", "$not", "$options",
+	"$regex", "$size", "$type"}
+
+// NewWorkloadAdvisor returns WorkloadAdvisor of op patterns
+func NewWorkloadAdvisor(opPatterns []OpPattern) *WorkloadAdvisor {
+	a := WorkloadAdvisor{indexes: map[string][]Index{}, shapes: []QueryShape{}, skipped: []SkippedShape{}}
+	for _, op := range opPatterns {
+		if contains(workloadCommands, op.Command) == false {
+			continue
+		}
+		shape, err := getQueryShape(op)
+		if err != nil {
+			a.skipped = append(a.skipped, SkippedShape{OpPattern: op, Reason: err.Error()})
+			continue
+		} else if len(shape.Equality)+len(shape.Sort)+len(shape.Range) == 0 {
+			continue
+		}
+		a.shapes = append(a.shapes, shape)
+	}
+	sort.SliceStable(a.shapes, func(i int, j int) bool { return a.shapes[i].TotalMilli > a.shapes[j].TotalMilli })
+	return &a
+}
+
+// GetNamespaces returns namespaces of query shapes
+func (a *WorkloadAdvisor) GetNamespaces() []string {
+	namespaces := []string{}
+	for _, shape := range a.shapes {
+		if contains(namespaces, shape.Namespace) == false {
+			namespaces = append(namespaces, shape.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// SetIndexes sets existing indexes of a namespace
+func (a *WorkloadAdvisor) SetIndexes(ns string, indexes []Index) {
+	a.indexes[ns] = indexes
+}
+
+// LoadIndexes gets existing indexes of namespaces of query shapes
+func (a *WorkloadAdvisor) LoadIndexes(client *mongo.Client, ix *IndexStats) error {
+	for _, ns := range a.GetNamespaces() {
+		n := strings.Index(ns, ".")
+		if n < 0 {
+			continue
+		}
+		collection := client.Database(ns[:n]).Collection(ns[n+1:])
+		indexes, err := ix.GetIndexesFromCollection(client, collection)
+		if err != nil {
+			log.Println(ns, err)
+			continue
+		}
+		a.SetIndexes(ns, indexes)
+	}
+	return nil
+}
+
+// getQueryShape returns the shape of an op pattern, fields of equality, sort, and range
+func getQueryShape(op OpPattern) (QueryShape, error) {
+	shape := QueryShape{Command: op.Command, Count: op.Count, Filter: op.Filter, Namespace: op.Namespace,
+		TotalMilli: op.TotalMilli}
+	filter, sortDoc, err := parseQueryPattern(op.Filter)
+	if err != nil {
+		return shape, err
+	}
+	equality, ranges := map[string]bool{}, map[string]bool{}
+	if err = getShapeFields(filter, equality, ranges); err != nil {
+		return shape, err
+	}
+	for _, e := range sortDoc {
+		if equality[e.Key] { // equality fields do not affect the sort order
+			continue
+		}
+		delete(ranges, e.Key)
+		shape.Sort = append(shape.Sort, bson.E{Key: e.Key, Value: getDirection(e.Value)})
+	}
+	for field := range equality {
+		delete(ranges, field)
+		shape.Equality = append(shape.Equality, field)
+	}
+	for field := range ranges {
+		shape.Range = append(shape.Range, field)
+	}
+	sort.Strings(shape.Equality)
+	sort.Strings(shape.Range)
+	return shape, nil
+}
+
+var reShapeRegex = regexp.MustCompile(`:\s*/[^,}]*`)
+var reShapeKey = regexp.MustCompile(`([{,]\s*)([\w$.]+)\s*:`)
+
+// parseQueryPattern parses a query pattern of a text or a JSON log, followed by a sort of text logs,
+// e.g. {a:1, b:{$gt:1}}, sort: {c: -1}
+func parseQueryPattern(filter string) (map[string]interface{}, bson.D, error) {
+	var sortDoc bson.D
+	fmap := map[string]interface{}{}
+	if n := strings.Index(filter, ", sort: "); n > 0 {
+		if err := bson.UnmarshalExtJSON([]byte(toShapeJSON(filter[n+len(", sort: "):])), false, &sortDoc); err != nil {
+			return fmap, sortDoc, errors.New("unsupported sort")
+		}
+		filter = filter[:n]
+	}
+	if n := strings.Index(filter, ", group: "); n > 0 {
+		filter = filter[:n]
+	}
+	for _, operator := range []string{"$facet", "$text", "$where", "$expr", "$or", "$nor", "$near", "$geo", "$indexStats"} {
+		if strings.Contains(filter, operator) {
+			return fmap, sortDoc, errors.New(operator + " not supported")
+		}
+	}
+	if err := json.Unmarshal([]byte(toShapeJSON(filter)), &fmap); err != nil {
+		return fmap, sortDoc, errors.New("unsupported query pattern")
+	}
+	return fmap, sortDoc, nil
+}
+
+// toShapeJSON converts a query pattern to JSON, quotes keys and replaces regular expressions and arrays
+func toShapeJSON(filter string) string {
+	filter = reShapeRegex.ReplaceAllString(filter, `:{"$$regex":1}`)
+	filter = strings.Replace(filter, "[...]", "[1]", -1)
+	return reShapeKey.ReplaceAllString(filter, `$1"$2":`)
+}
+
+// getShapeFields adds fields of a filter to equality or range fields
+func getShapeFields(filter map[string]interface{}, equality map[string]bool, ranges map[string]bool) error {
+	for key, value := range filter {
+		if key == "$and" {
+			list, ok := value.([]interface{})
+			if !ok {
+				return errors.New("unsupported $and")
+			}
+			for _, elem := range list {
+				if doc, ok := elem.(map[string]interface{}); ok {
+					if err := getShapeFields(doc, equality, ranges); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		} else if strings.HasPrefix(key, "$") {
+			return errors.New(key + " not supported")
+		}
+		doc, ok := value.(map[string]interface{})
+		if !ok { // a value
+			equality[key] = true
+			continue
+		}
+		isRange := false
+		for op := range doc {
+			if contains(rangeOperators, op) {
+				isRange = true
+			} else if strings.HasPrefix(op, "$") && contains(equalityOperators, op) == false {
+				return errors.New(op + " not supported")
+			}
+		}
+		if isRange {
+			ranges[key] = true
+		} else { // equality operators or an embedded document
+			equality[key] = true
+		}
+	}
+	return nil
+}
+
+// getDirection returns 1 or -1 of a sort direction
+func getDirection(value interface{}) int32 {
+	if toInt(value) < 0 {
+		return -1
+	}
+	return 1
+}
+
+// getShapeIndexKey returns an index key in ESR order, equality fields in the order of the leading fields
+// of an index if possible
+func getShapeIndexKey(shape QueryShape, leading bson.D) bson.D {
+	key := bson.D{}
+	equality := append([]string{}, shape.Equality...)
+	n := len(leading)
+	if n > len(equality) {
+		n = len(equality)
+	}
+	aligned := n > 0
+	for _, e := range leading[:n] {
+		aligned = aligned && contains(equality, e.Key)
+	}
+	if aligned {
+		for _, e := range leading[:n] {
+			key = append(key, bson.E{Key: e.Key, Value: int32(1)})
+		}
+	}
+	for _, field := range equality {
+		if hasKey(key, field) == false {
+			key = append(key, bson.E{Key: field, Value: int32(1)})
+		}
+	}
+	key = append(key, shape.Sort...)
+	for _, field := range shape.Range {
+		key = append(key, bson.E{Key: field, Value: int32(1)})
+	}
+	return key
+}
+
+func hasKey(key bson.D, field string) bool {
+	for _, e := range key {
+		if e.Key == field {
+			return true
+		}
+	}
+	return false
+}
+
+// isServedBy returns true if an index serves a shape in ESR order, equality fields lead the index, followed
+// by sort fields in the same or all reversed directions, then range fields
+func isServedBy(shape QueryShape, key bson.D) bool {
+	ne, ns, nr := len(shape.Equality), len(shape.Sort), len(shape.Range)
+	if len(key) < ne+ns+nr {
+		return false
+	}
+	for _, e := range key[:ne] {
+		if contains(shape.Equality, e.Key) == false {
+			return false
+		}
+	}
+	same, reversed := true, true
+	for i, e := range shape.Sort {
+		if key[ne+i].Key != e.Key {
+			return false
+		}
+		same = same && toInt(key[ne+i].Value) == toInt(e.Value)
+		reversed = reversed && toInt(key[ne+i].Value) == -toInt(e.Value)
+	}
+	if same == false && reversed == false {
+		return false
+	}
+	for _, e := range key[ne+ns : ne+ns+nr] {
+		if contains(shape.Range, e.Key) == false {
+			return false
+		}
+	}
+	return true
+}
+
+// isKeyPrefixOf returns true if a key is a prefix of the other in the same directions
+func isKeyPrefixOf(key bson.D, other bson.D) bool {
+	if len(key) > len(other) {
+		return false
+	}
+	for i, e := range key {
+		if e.Key != other[i].Key || toInt(e.Value) != toInt(other[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetRecommendations returns indexes of collections, each serving shapes merged in the order of total
+// milliseconds, checked against existing indexes
+func (a *WorkloadAdvisor) GetRecommendations() []IndexRecommendation {
+	recommendations := []IndexRecommendation{}
+	for _, ns := range a.GetNamespaces() {
+		list := []IndexRecommendation{}
+		for _, shape := range a.shapes {
+			if shape.Namespace != ns {
+				continue
+			}
+			list = mergeShape(list, shape)
+		}
+		for i := range list {
+			a.checkExistingIndexes(&list[i])
+		}
+		sort.SliceStable(list, func(i int, j int) bool { return list[i].TotalMilli > list[j].TotalMilli })
+		recommendations = append(recommendations, list...)
+	}
+	return recommendations
+}
+
+// mergeShape adds a shape to an index serving it, to an index extended to serve it, or to a new index
+func mergeShape(list []IndexRecommendation, shape QueryShape) []IndexRecommendation {
+	for i := range list {
+		if isServedBy(shape, list[i].Key) {
+			list[i].Shapes = append(list[i].Shapes, shape)
+			list[i].TotalMilli += shape.TotalMilli
+			return list
+		}
+	}
+	for i := range list {
+		key := getShapeIndexKey(shape, list[i].Key)
+		if isKeyPrefixOf(list[i].Key, key) == false {
+			continue
+		}
+		servesAll := true
+		for _, s := range list[i].Shapes {
+			servesAll = servesAll && isServedBy(s, key)
+		}
+		if servesAll {
+			list[i].Key = key
+			list[i].Shapes = append(list[i].Shapes, shape)
+			list[i].TotalMilli += shape.TotalMilli
+			return list
+		}
+	}
+	return append(list, IndexRecommendation{Key: getShapeIndexKey(shape, nil), Namespace: shape.Namespace,
+		Shapes: []QueryShape{shape}, TotalMilli: shape.TotalMilli})
+}
+
+// checkExistingIndexes sets an existing index serving all shapes, and existing indexes redundant with it,
+// prefixes of it, other than _id, unique, TTL, and shard key indexes
+func (a *WorkloadAdvisor) checkExistingIndexes(rec *IndexRecommendation) {
+	indexes := a.indexes[rec.Namespace]
+	for _, o := range indexes {
+		servesAll := len(rec.Shapes) > 0
+		for _, s := range rec.Shapes {
+			servesAll = servesAll && isServedBy(s, o.Key)
+		}
+		if servesAll && (rec.Existing == "" || len(o.Key) < len(rec.Key)) {
+			rec.Existing = o.Name
+			rec.Key = o.Key
+		}
+	}
+	for _, o := range indexes {
+		if o.Name == rec.Existing || o.Name == "_id_" || len(getKeepReasons(o)) > 0 || isSpecialIndex(o) ||
+			len(o.PartialFilterExpression) > 0 || o.Sparse {
+			continue
+		}
+		if len(o.Key) < len(rec.Key) && isPrefixOf(o, Index{Key: rec.Key}) {
+			rec.Redundant = append(rec.Redundant, o)
+		}
+	}
+}
+
+// getKeyString returns a string of an index key, e.g. { a: 1, b: -1 }
+func getKeyString(key bson.D) string {
+	fields := []string{}
+	for _, e := range key {
+		fields = append(fields, fmt.Sprintf("%v: %v", e.Key, e.Value))
+	}
+	return "{ " + strings.Join(fields, ", ") + " }"
+}
+
+// GetAdvice returns a report of indexes recommended, shapes each index covers, and existing indexes redundant
+func (a *WorkloadAdvisor) GetAdvice() string {
+	var buffer bytes.Buffer
+	buffer.WriteString("\n--- Index Recommendations ---\n")
+	ns := ""
+	for _, rec := range a.GetRecommendations() {
+		if rec.Namespace != ns {
+			ns = rec.Namespace
+			buffer.WriteString(ns + ":\n")
+		}
+		if rec.Existing != "" {
+			buffer.WriteString(fmt.Sprintf("  keep %v (%v), %d shape(s), %d ms\n", getKeyString(rec.Key), rec.Existing,
+				len(rec.Shapes), rec.TotalMilli))
+		} else {
+			buffer.WriteString(fmt.Sprintf("  create %v, %d shape(s), %d ms\n", getKeyString(rec.Key), len(rec.Shapes),
+				rec.TotalMilli))
+		}
+		for _, s := range rec.Shapes {
+			buffer.WriteString(fmt.Sprintf("\tcovers %v %v, count: %d, %d ms\n", s.Command, s.Filter, s.Count, s.TotalMilli))
+		}
+		for _, o := range rec.Redundant {
+			buffer.WriteString(fmt.Sprintf("\tredundant %v (%v), a prefix of %v\n", o.KeyString, o.Name, getKeyString(rec.Key)))
+		}
+	}
+	if len(a.shapes) == 0 {
+		buffer.WriteString("No query shape to index\n")
+	}
+	if len(a.skipped) > 0 {
+		buffer.WriteString("\n--- Query Shapes Skipped ---\n")
+	}
+	for _, s := range a.skipped {
+		buffer.WriteString(fmt.Sprintf("%v %v %v: %v\n", s.OpPattern.Command, s.OpPattern.Namespace, s.OpPattern.Filter, s.Reason))
+	}
+	return buffer.String()
+}
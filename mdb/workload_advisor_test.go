@@ -0,0 +1,64 @@
+// Copyright 2020 Kuei-chun Chen. All rights reserved.
+
+package mdb
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestGetQueryShape(t *testing.T) {
+	shape, err := getQueryShape(OpPattern{Command: "find", Namespace: "db.orders",
+		Filter: `{status:1, qty:{$gt:1}, sku:/regex/}, sort: {date: -1}`})
+	if err != nil || strings.Join(shape.Equality, ",") != "status" || strings.Join(shape.Range, ",") != "qty,sku" ||
+		len(shape.Sort) != 1 || shape.Sort[0].Key != "date" || shape.Sort[0].Value != int32(-1) {
+		t.Fatal(shape, err)
+	}
+	shape, err = getQueryShape(OpPattern{Command: "find", Namespace: "db.orders",
+		Filter: `{"$and":[{"status":1},{"tags":{"$in":[...]}}],"date":{"$gte":1}}`})
+	if err != nil || strings.Join(shape.Equality, ",") != "status,tags" || strings.Join(shape.Range, ",") != "date" {
+		t.Fatal(shape, err)
+	}
+	for _, filter := range []string{`{"$or":[{"a":1},{"b":1}]}`, `{"$text":{"$search":1}}`, `{"a":{"$geoWithin":1}}`} {
+		if _, err = getQueryShape(OpPattern{Command: "find", Filter: filter}); err == nil {
+			t.Fatal("expected error", filter)
+		}
+	}
+}
+
+func TestWorkloadAdvisor(t *testing.T) {
+	ns := "db.orders"
+	opPatterns := []OpPattern{
+		{Command: "find", Count: 10, Namespace: ns, Filter: `{status:1}`, TotalMilli: 1000},
+		{Command: "find", Count: 5, Namespace: ns, Filter: `{status:1}, sort: {date: -1}`, TotalMilli: 800},
+		{Command: "find", Count: 5, Namespace: ns, Filter: `{"status":1,"date":{"$gt":1}}`, TotalMilli: 500},
+		{Command: "update", Count: 3, Namespace: ns, Filter: `{"sku":1}`, TotalMilli: 300},
+		{Command: "insert", Count: 3, Namespace: ns, Filter: "N/A", TotalMilli: 100},
+		{Command: "find", Count: 1, Namespace: ns, Filter: `{"$or":[{"a":1},{"b":1}]}`, TotalMilli: 50},
+	}
+	a := NewWorkloadAdvisor(opPatterns)
+	a.SetIndexes(ns, []Index{
+		{Name: "_id_", Key: bson.D{{Key: "_id", Value: int32(1)}}, KeyString: "{ _id: 1 }", ExpireAfterSeconds: -1},
+		{Name: "status_1", Key: bson.D{{Key: "status", Value: int32(1)}}, KeyString: "{ status: 1 }", ExpireAfterSeconds: -1},
+		{Name: "sku_1", Key: bson.D{{Key: "sku", Value: int32(1)}}, KeyString: "{ sku: 1 }", ExpireAfterSeconds: -1},
+	})
+	list := a.GetRecommendations()
+	if len(list) != 2 {
+		t.Fatal(list)
+	}
+	if getKeyString(list[0].Key) != "{ status: 1, date: -1 }" || list[0].Existing != "" || len(list[0].Shapes) != 3 ||
+		list[0].TotalMilli != 2300 || len(list[0].Redundant) != 1 || list[0].Redundant[0].Name != "status_1" {
+		t.Fatal(list[0])
+	}
+	if list[1].Existing != "sku_1" || len(list[1].Redundant) != 0 {
+		t.Fatal(list[1])
+	}
+	str := a.GetAdvice()
+	if strings.Contains(str, "create { status: 1, date: -1 }, 3 shape(s), 2300 ms") == false ||
+		strings.Contains(str, "keep { sku: 1 } (sku_1)") == false || strings.Contains(str, "$or not supported") == false {
+		t.Fatal(str)
+	}
+	t.Log(str)
+}